@@ -0,0 +1,41 @@
+// issue-token 運用担当者向けに API のアクセストークンを発行する
+//
+//	go run ./cmd/issue-token -sub u-001 -name "山田" -role dispatcher -ttl 8h
+//
+// 署名鍵はサーバーと同じ AUTH_TOKEN_SECRET（.env も読み込む）を使う
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	auth "nissyo/internal/auth"
+	config "nissyo/internal/config"
+)
+
+func main() {
+	sub := flag.String("sub", "", "利用者ID（必須）")
+	name := flag.String("name", "", "利用者名")
+	role := flag.String("role", "", "役割（admin / manager / dispatcher / staff）")
+	ttl := flag.Duration("ttl", 8*time.Hour, "有効期間")
+	flag.Parse()
+
+	if err := config.LoadEnvIfPresent(); err != nil {
+		log.Printf("init: .env load warning: %v", err)
+	}
+	roles := []string{auth.RoleAdmin, auth.RoleManager, auth.RoleDispatcher, auth.RoleStaff}
+	if *sub == "" || !slices.Contains(roles, *role) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	token, err := auth.Sign(auth.Principal{Subject: *sub, Name: *name, Role: *role}, *ttl)
+	if err != nil {
+		log.Fatalf("issue-token: %v", err)
+	}
+	fmt.Println(token)
+}
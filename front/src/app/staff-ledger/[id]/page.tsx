@@ -59,6 +59,8 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
   const [phoneNumber, setPhoneNumber] = useState<string>('');
   const [mobileEmail, setMobileEmail] = useState<string>('');
   const [pcEmail, setPcEmail] = useState<string>('');
+  // 詳細 API は連絡先を伏せて返すため、読み込んだ値（伏せた表記）から変更した項目だけを送る
+  const [loadedContacts, setLoadedContacts] = useState<{ phoneNumber: string; mobileEmail: string; pcEmail: string }>({ phoneNumber: '', mobileEmail: '', pcEmail: '' });
   const [vehicleId, setVehicleId] = useState<string>('');
   const [carType, setCarType] = useState<string>('');
   const [carColor, setCarColor] = useState<string>('');
@@ -67,6 +69,8 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
   const [bathTowel, setBathTowel] = useState<string>('');
   const [equipment, setEquipment] = useState<string>('');
   const [remarks, setRemarks] = useState<string>('');
+  // 楽観的排他制御用。取得時のバージョンを保存時に If-Match で送る
+  const [version, setVersion] = useState<string>('');
 
   const [employmentStatus, setEmploymentStatus] = useState<'active' | 'inactive'>('active');
   const [employmentDate, setEmploymentDate] = useState<string>('');
@@ -129,6 +133,7 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
         number: z.number().nullable().optional(),
       }).nullable().optional(),
       schedule: z.record(DayScheduleSchema).optional().default({}),
+      version: z.string().optional(),
     });
 
     const fetchDetail = async () => {
@@ -159,9 +164,11 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
         setPhoneNumber(data.phoneNumber ?? '');
         setMobileEmail(data.mobileEmail ?? '');
         setPcEmail(data.pcEmail ?? '');
+        setLoadedContacts({ phoneNumber: data.phoneNumber ?? '', mobileEmail: data.mobileEmail ?? '', pcEmail: data.pcEmail ?? '' });
         setBathTowel(data.bathTowel != null ? String(data.bathTowel) : '');
         setEquipment(data.equipment != null ? String(data.equipment) : '');
         setRemarks(data.remarks ?? '');
+        setVersion(data.version ?? '');
         if (data.car) {
           setCarType(data.car.carType ?? '');
           setCarColor(data.car.color ?? '');
@@ -216,9 +223,6 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
         jobDriver,
         jobOffice,
         role,
-        phoneNumber,
-        mobileEmail,
-        pcEmail,
         vehicleId: vehicleId ?? '',
         remarks: remarks.trim() !== '' ? remarks : null,
         schedule: {
@@ -231,6 +235,9 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
           sun: schedule.sun,
         },
       };
+      if (phoneNumber !== loadedContacts.phoneNumber) payload.phoneNumber = phoneNumber;
+      if (mobileEmail !== loadedContacts.mobileEmail) payload.mobileEmail = mobileEmail;
+      if (pcEmail !== loadedContacts.pcEmail) payload.pcEmail = pcEmail;
       if (bathTowel.trim() !== '') {
         const n = Number(bathTowel);
         if (!Number.isNaN(n)) payload.bathTowel = n;
@@ -251,9 +258,13 @@ export default function StaffDetailPage({ params }: StaffDetailPageProps) {
 
       const res = await fetch(`http://localhost:8080/api/staff/${id}`, {
         method: 'PATCH',
-        headers: { 'Content-Type': 'application/json' },
+        headers: { 'Content-Type': 'application/json', 'If-Match': `"${version}"` },
         body: JSON.stringify(payload),
       });
+      if (res.status === 412) {
+        toast({ title: '保存エラー', description: '他のユーザーが先に更新しました。画面を再読み込みして最新の内容を確認してください。', variant: 'destructive' as any });
+        return;
+      }
       if (!res.ok) {
         toast({ title: '保存エラー', description: `保存に失敗しました（${res.status}）`, variant: 'destructive' as any });
         return;
@@ -264,5 +264,6 @@ export const accessTypeOptions = [
   { value: 'admin', label: '管理者' },
   { value: 'manager', label: '責任者' },
   { value: 'accounting_manager', label: '会計責任者' },
+  { value: 'dispatcher', label: '配車係' },
   { value: 'staff', label: 'スタッフ' }
 ];
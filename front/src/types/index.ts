@@ -127,8 +127,8 @@ import type { EmploymentType } from './staff-attendance';
 export type JobType = 'driver' | 'office';
 export type RoleType = 'chairman' | 'advisor' | 'president' | 'general_manager' | 'manager' | 'admin_manager' | 'office_manager' | 'female_manager' | 'office_staff' | 'pr';
 export type EmploymentStatusType = 'active' | '';
-export type AccessType = 'admin' | 'manager' | 'accounting_manager' | 'staff';
-export type AccessStatusType = 'active' | 'inactive';
+export type AccessType = 'admin' | 'manager' | 'accounting_manager' | 'dispatcher' | 'staff';
+export type AccessStatusType = 'active' | 'suspended';
 
 export interface StaffLedgerRecord {
   id: string;
@@ -184,13 +184,14 @@ export const ACCESS_TYPE_LABELS: Record<AccessType, string> = {
   admin: '管理者',
   manager: '責任者',
   accounting_manager: '会計責任者',
+  dispatcher: '配車係',
   staff: 'スタッフ'
 };
 
 // アクセス権ステータスの表示ラベル
 export const ACCESS_STATUS_LABELS: Record<AccessStatusType, string> = {
   active: '有効',
-  inactive: '無効'
+  suspended: '停止'
 };
 
 // 店舗台帳関連の型定義
@@ -0,0 +1,79 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	auth "nissyo/internal/auth"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 表示対象の種類
+const (
+	ResourceStaff = "staff"
+	ResourceShop  = "shop"
+)
+
+// Reveal マスク前の連絡先を表示した記録
+type Reveal struct {
+	ResourceType string
+	ResourceID   string
+	Fields       []string
+	Reason       string
+}
+
+// RevealRequest 表示理由
+type RevealRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// BindReason 本文から表示理由を読み取る（前後の空白は除く）
+// 不正な場合はエラー応答を返して ok=false
+func BindReason(c *gin.Context) (string, bool) {
+	var req RevealRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return "", false
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len(fieldErrs) == 0 && reason == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(apierror.Lang(c), "reason", "required", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return "", false
+	}
+	return reason, true
+}
+
+// RecordReveal 表示の記録を contact_reveal_log に書き込む
+// 記録できなかった場合は値を返さないこと（呼び出し側でエラーにする）
+func RecordReveal(ctx context.Context, client *supa.Client, c *gin.Context, r Reveal) error {
+	p, ok := auth.Current(c)
+	if !ok {
+		return fmt.Errorf("reveal without principal")
+	}
+	row := map[string]any{
+		"resource_type": r.ResourceType,
+		"resource_id":   r.ResourceID,
+		"fields":        r.Fields,
+		"reason":        r.Reason,
+		"actor_subject": p.Subject,
+		"actor_name":    p.Name,
+		"actor_role":    p.Role,
+		"request_id":    apierror.RequestID(c),
+		"client_ip":     c.ClientIP(),
+	}
+	if _, _, err := client.Post(ctx, "/rest/v1/contact_reveal_log", nil, row); err != nil {
+		return fmt.Errorf("insert contact_reveal_log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package apierror
+
+import "net/http"
+
+// Entry エラーコード1件分の定義（HTTPステータスと日英メッセージ）
+type Entry struct {
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+	JA     string `json:"ja"`
+	EN     string `json:"en"`
+}
+
+// Message 言語に応じたメッセージを返す（未知の言語は日本語）
+func (e Entry) Message(lang string) string {
+	if lang == LangEN {
+		return e.EN
+	}
+	return e.JA
+}
+
+// エラーコード一覧
+// フロントエンドはコードで分岐するため、既存コードの意味は変更しないこと
+const (
+	CodeDBInit     = "DB_001"
+	CodeDBDecode   = "DB_002"
+	CodeDBUpdate   = "DB_003"
+	CodeNotFound   = "DB_404"
+	CodeDuplicate  = "DB_409"
+	CodeInUse      = "REF_409"
+	CodeMissingID  = "VAL_001"
+	CodeValidation = "VAL_002"
+	CodeInternal   = "SYS_500"
+
+	CodePreconditionFailed   = "CONC_412"
+	CodePreconditionRequired = "CONC_428"
+
+	CodeIdempotencyKeyInvalid = "IDEM_400"
+	CodeIdempotencyInFlight   = "IDEM_409"
+	CodeIdempotencyMismatch   = "IDEM_422"
+	CodeIdempotencyTooLarge   = "IDEM_413"
+
+	CodeImportInvalidFile = "IMP_400"
+	CodeImportTooLarge    = "IMP_413"
+	CodeImportRowErrors   = "IMP_422"
+
+	CodeVehicleAssigned = "VEH_409"
+
+	CodeUnauthorized = "AUTH_401"
+	CodeForbidden    = "AUTH_403"
+	CodeRateLimited  = "RATE_429"
+
+	CodeLoginFailed            = "AUTH_001"
+	CodeAccountDisabled        = "AUTH_002"
+	CodePasswordChangeRequired = "AUTH_003"
+	CodeTOTPRequired           = "AUTH_004"
+	CodeTOTPSetupRequired      = "AUTH_005"
+	CodeAccountLocked          = "AUTH_423"
+)
+
+var catalog = []Entry{
+	{Code: CodeDBInit, Status: http.StatusInternalServerError, JA: "データベースへのアクセスに失敗しました", EN: "database access error"},
+	{Code: CodeDBDecode, Status: http.StatusInternalServerError, JA: "データベースの応答を解析できませんでした", EN: "response decode error"},
+	{Code: CodeDBUpdate, Status: http.StatusInternalServerError, JA: "データベースの更新に失敗しました", EN: "database update error"},
+	{Code: CodeNotFound, Status: http.StatusNotFound, JA: "対象のデータが見つかりません", EN: "resource not found"},
+	{Code: CodeDuplicate, Status: http.StatusConflict, JA: "同じ値が既に登録されています", EN: "a record with the same value already exists"},
+	{Code: CodeInUse, Status: http.StatusConflict, JA: "他のデータから参照されているため削除できません", EN: "the record is still referenced by other data and cannot be deleted"},
+	{Code: CodeMissingID, Status: http.StatusBadRequest, JA: "IDが指定されていません", EN: "missing id"},
+	{Code: CodeValidation, Status: http.StatusBadRequest, JA: "入力内容に誤りがあります", EN: "invalid request body"},
+	{Code: CodePreconditionFailed, Status: http.StatusPreconditionFailed, JA: "他のユーザーが先に更新しました。最新の内容を確認してください", EN: "the record was modified by someone else; review the current values"},
+	{Code: CodePreconditionRequired, Status: http.StatusPreconditionRequired, JA: "If-Match ヘッダーまたは version を指定してください", EN: "If-Match header or version is required"},
+	{Code: CodeIdempotencyKeyInvalid, Status: http.StatusBadRequest, JA: "Idempotency-Key の形式が正しくありません（英数字と ._:- の8〜128文字）", EN: "invalid Idempotency-Key (8-128 characters of letters, digits and ._:-)"},
+	{Code: CodeIdempotencyInFlight, Status: http.StatusConflict, JA: "同じ Idempotency-Key のリクエストを処理中です", EN: "a request with the same Idempotency-Key is still in progress"},
+	{Code: CodeIdempotencyMismatch, Status: http.StatusUnprocessableEntity, JA: "Idempotency-Key が別の内容のリクエストで使用済みです", EN: "Idempotency-Key was already used with a different request"},
+	{Code: CodeIdempotencyTooLarge, Status: http.StatusRequestEntityTooLarge, JA: "Idempotency-Key を指定したリクエストの本文が大きすぎます（6MBまで）", EN: "the request body is too large for an Idempotency-Key request (up to 6 MB)"},
+	{Code: CodeImportInvalidFile, Status: http.StatusBadRequest, JA: "CSV ファイルを読み込めませんでした", EN: "the CSV file could not be read"},
+	{Code: CodeImportTooLarge, Status: http.StatusRequestEntityTooLarge, JA: "ファイルが大きすぎます（5MB・2000行まで）", EN: "the file is too large (up to 5 MB and 2000 rows)"},
+	{Code: CodeImportRowErrors, Status: http.StatusUnprocessableEntity, JA: "取り込めない行があります。dryRun で内容を確認してください", EN: "some rows are invalid; review them with dryRun first"},
+	{Code: CodeVehicleAssigned, Status: http.StatusConflict, JA: "この車両は他の在職中のスタッフに割り当てられています", EN: "the vehicle is already assigned to another active staff member"},
+	{Code: CodeUnauthorized, Status: http.StatusUnauthorized, JA: "ログインしてください", EN: "authentication required"},
+	{Code: CodeForbidden, Status: http.StatusForbidden, JA: "この操作を行う権限がありません", EN: "you do not have permission to perform this operation"},
+	{Code: CodeLoginFailed, Status: http.StatusUnauthorized, JA: "ログインIDまたはパスワードが正しくありません", EN: "invalid login ID or password"},
+	{Code: CodeAccountDisabled, Status: http.StatusForbidden, JA: "このアカウントは利用できません。管理者に連絡してください", EN: "this account is disabled; contact an administrator"},
+	{Code: CodePasswordChangeRequired, Status: http.StatusForbidden, JA: "初期パスワードを変更してください", EN: "you must change your initial password first"},
+	{Code: CodeTOTPRequired, Status: http.StatusForbidden, JA: "2段階認証の確認コードを入力してください", EN: "enter the two-factor authentication code first"},
+	{Code: CodeTOTPSetupRequired, Status: http.StatusForbidden, JA: "この役職では2段階認証の設定が必要です", EN: "two-factor authentication must be set up for your role"},
+	{Code: CodeAccountLocked, Status: http.StatusLocked, JA: "ログインの失敗が続いたため、しばらくログインできません", EN: "too many failed sign-in attempts; try again later"},
+	{Code: CodeRateLimited, Status: http.StatusTooManyRequests, JA: "操作の回数が上限に達しました。しばらくしてから再度お試しください", EN: "too many requests; try again later"},
+	{Code: CodeInternal, Status: http.StatusInternalServerError, JA: "サーバー内部でエラーが発生しました", EN: "internal server error"},
+}
+
+var catalogByCode = func() map[string]Entry {
+	m := make(map[string]Entry, len(catalog))
+	for _, e := range catalog {
+		m[e.Code] = e
+	}
+	return m
+}()
+
+// Lookup コードに対応する定義を返す。未登録のコードは SYS_500 として扱う
+func Lookup(code string) Entry {
+	if e, ok := catalogByCode[code]; ok {
+		return e
+	}
+	e := catalogByCode[CodeInternal]
+	e.Code = code
+	return e
+}
+
+// Catalog 登録済みのエラー定義をすべて返す（コピー）
+func Catalog() []Entry {
+	out := make([]Entry, len(catalog))
+	copy(out, catalog)
+	return out
+}
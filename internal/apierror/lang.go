@@ -0,0 +1,59 @@
+package apierror
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LangJA = "ja"
+	LangEN = "en"
+)
+
+// Lang Accept-Language から応答言語（ja / en）を決める
+// 対応言語が含まれない場合や未指定の場合は日本語
+func Lang(c *gin.Context) string {
+	return parseAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+func parseAcceptLanguage(header string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if v, ok := strings.CutPrefix(f, "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		prefs = append(prefs, pref{tag: tag, q: q})
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		if p.q <= 0 {
+			continue
+		}
+		primary := strings.SplitN(p.tag, "-", 2)[0]
+		switch primary {
+		case LangJA:
+			return LangJA
+		case LangEN:
+			return LangEN
+		}
+	}
+	return LangJA
+}
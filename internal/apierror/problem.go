@@ -0,0 +1,74 @@
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem RFC 7807 形式のエラー応答
+// code / message / details はフロントエンドの ApiError が参照する拡張メンバー
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// FieldError フロントの入力欄横に表示するためのフィールド単位のエラー
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Respond カタログに従ってエラーを返し、以降のハンドラーを中断する
+func Respond(c *gin.Context, code string) {
+	RespondWith(c, code, "", nil)
+}
+
+// RespondDetail 個別の説明文（detail）付きでエラーを返す
+func RespondDetail(c *gin.Context, code, detail string) {
+	RespondWith(c, code, detail, nil)
+}
+
+// RespondWith 説明文と詳細データ（フィールドエラー一覧など）付きでエラーを返す
+func RespondWith(c *gin.Context, code, detail string, details any) {
+	RespondStatus(c, 0, code, detail, details)
+}
+
+// RespondStatus カタログのステータスを上書きしてエラーを返す（status<=0 ならカタログ値）
+func RespondStatus(c *gin.Context, status int, code, detail string, details any) {
+	e := Lookup(code)
+	if status <= 0 {
+		status = e.Status
+	}
+	lang := Lang(c)
+	msg := e.Message(lang)
+	p := Problem{
+		Type:      "/api/meta/errors#" + e.Code,
+		Title:     msg,
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      e.Code,
+		Message:   msg,
+		RequestID: RequestID(c),
+		Details:   details,
+	}
+	c.Header("Content-Type", ProblemContentType)
+	c.Header("Content-Language", lang)
+	c.AbortWithStatusJSON(status, p)
+}
+
+// MetaErrorsHandler エラーカタログをフロントエンド向けに公開する
+func MetaErrorsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"errors": Catalog()})
+}
@@ -0,0 +1,93 @@
+package area
+
+import (
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// AreaDTO area の行
+type AreaDTO struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	NameEN    *string `json:"name_en"`
+	Rank      int     `json:"rank"`
+	CreatedAt *string `json:"created_at,omitempty"`
+	UpdatedAt *string `json:"updated_at,omitempty"`
+}
+
+// Areas 表示順の地域区分一覧（件数が少ないため検索は線形で行う）
+type Areas []AreaDTO
+
+// ByName 名前が一致する地域区分
+func (as Areas) ByName(name string) (AreaDTO, bool) {
+	for _, a := range as {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return AreaDTO{}, false
+}
+
+// Match 名前・英語名のいずれかと一致する地域区分（入力の表記揺れ用。全角半角・大文字小文字は区別しない）
+// 部分一致はしない
+func (as Areas) Match(label string) (AreaDTO, bool) {
+	v := normalizeLabel(label)
+	for _, a := range as {
+		if v == normalizeLabel(a.Name) || (a.NameEN != nil && v == normalizeLabel(*a.NameEN)) {
+			return a, true
+		}
+	}
+	return AreaDTO{}, false
+}
+
+// Names 名前の一覧（検証エラーの選択肢に使う）
+func (as Areas) Names() []string {
+	names := make([]string, 0, len(as))
+	for _, a := range as {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.TrimSpace(norm.NFKC.String(s)))
+}
+
+// Label 言語に応じた地域区分名（英語名が未設定なら名前）
+func (a AreaDTO) Label(lang string) string {
+	if lang == apierror.LangEN && a.NameEN != nil {
+		return *a.NameEN
+	}
+	return a.Name
+}
+
+// AreaResponse 地域区分マスタの API の形
+type AreaResponse struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`  // staff の areaDivision に指定する値
+	Label     string  `json:"label"` // Accept-Language に応じた表示名
+	NameEN    *string `json:"nameEn"`
+	Rank      int     `json:"rank"`
+	CreatedAt string  `json:"createdAt,omitempty"`
+	UpdatedAt string  `json:"updatedAt,omitempty"`
+	Version   string  `json:"version"` // 楽観的排他制御用（ETag と同じ値）
+}
+
+// CreateAreaRequest 地域区分の追加（rank 省略時は最後に追加）
+type CreateAreaRequest struct {
+	Name   string  `json:"name" binding:"required,max=50"`
+	NameEN *string `json:"nameEn" binding:"omitempty,max=50"`
+	Rank   *int    `json:"rank" binding:"omitempty,min=0,max=100000"`
+}
+
+// UpdateAreaRequest 地域区分の部分更新（指定した項目のみ。名前の変更はスタッフにも反映される）
+type UpdateAreaRequest struct {
+	Name   *string `json:"name" binding:"omitempty,max=50"`
+	NameEN *string `json:"nameEn" binding:"omitempty,max=50"` // 空文字で未設定に戻す
+	Rank   *int    `json:"rank" binding:"omitempty,min=0,max=100000"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
+}
@@ -0,0 +1,365 @@
+package area
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// areaSelect 地域区分マスタで取得する列
+const areaSelect = "id,name,name_en,rank,created_at,updated_at"
+
+// rank 省略時に最後の地域区分から空ける間隔（間に地域区分を追加できるようにする）
+const rankStep = 10
+
+// List 地域区分を表示順にすべて取得する。失敗時はエラーコードを返す（ログ出力済み）
+func List(ctx context.Context, client *supa.Client) (Areas, string) {
+	q := url.Values{}
+	q.Set("select", areaSelect)
+	q.Set("order", "rank.asc,name.asc")
+	body, _, getErr := client.Get(ctx, "/rest/v1/area", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get area error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows Areas
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (area): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	return rows, ""
+}
+
+// GetAreaListHandler 地域区分の一覧（GET /api/areas。表示順）
+func GetAreaListHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	rows, code := List(ctx, client)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	lang := apierror.Lang(c)
+	out := make([]AreaResponse, 0, len(rows))
+	for _, a := range rows {
+		out = append(out, toResponse(a, lang))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetAreaDetailHandler 地域区分1件（GET /api/areas/:id）
+func GetAreaDetailHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, found, code := fetchArea(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := toResponse(a, apierror.Lang(c))
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateAreaHandler 地域区分を追加する（POST /api/areas）
+// 名前が既に使われている場合は 409
+func CreateAreaHandler(c *gin.Context) {
+	var req CreateAreaRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if len(fieldErrs) == 0 && strings.TrimSpace(req.Name) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "name", "required", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	row := map[string]any{
+		"name":    strings.TrimSpace(req.Name),
+		"name_en": nameENValue(req.NameEN),
+	}
+	if req.Rank != nil {
+		row["rank"] = *req.Rank
+	} else {
+		rows, code := List(ctx, client)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		next := 0
+		if len(rows) > 0 {
+			next = rows[len(rows)-1].Rank + rankStep
+		}
+		row["rank"] = next
+	}
+
+	q := url.Values{}
+	q.Set("select", areaSelect)
+	respBody, status, postErr := client.Post(ctx, "/rest/v1/area", q, row)
+	if postErr != nil {
+		if status == http.StatusConflict {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", []apierror.FieldError{
+				validate.NewFieldError(lang, "name", "unique", ""),
+			})
+			return
+		}
+		log.Printf("DB_003: supabase insert area error: %v", postErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var created []AreaDTO
+	if err := json.Unmarshal(respBody, &created); err != nil || len(created) == 0 {
+		log.Printf("DB_002: json decode error (area insert): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	resp := toResponse(created[0], lang)
+	etag.Set(c, resp.Version)
+	c.Header("Location", "/api/areas/"+resp.ID)
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateAreaHandler 地域区分を部分更新する（PATCH /api/areas/:id）
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+// 名前の変更は staff.area_division の外部キー（on update cascade）でスタッフにも反映される
+func UpdateAreaHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req UpdateAreaRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "name", "required", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchArea(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, toResponse(current, lang)) {
+		return
+	}
+
+	patch := map[string]any{}
+	if req.Name != nil {
+		patch["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.NameEN != nil {
+		patch["name_en"] = nameENValue(req.NameEN)
+	}
+	if req.Rank != nil {
+		patch["rank"] = *req.Rank
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	q.Set("updated_at", "eq."+deref(current.UpdatedAt))
+	q.Set("select", areaSelect)
+	respBody, status, patchErr := client.Patch(ctx, "/rest/v1/area", q, patch)
+	if patchErr != nil {
+		if status == http.StatusConflict {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", []apierror.FieldError{
+				validate.NewFieldError(lang, "name", "unique", ""),
+			})
+			return
+		}
+		log.Printf("DB_003: supabase patch area error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var updated []AreaDTO
+	if err := json.Unmarshal(respBody, &updated); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(updated) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchArea(ctx, client, id); code == "" && found {
+			resp := toResponse(latest, lang)
+			etag.Conflict(c, resp.Version, resp)
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+	resp := toResponse(updated[0], lang)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteAreaHandler 地域区分を削除する（DELETE /api/areas/:id）
+// スタッフに割り当てられている地域区分は削除できない（409）
+func DeleteAreaHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	if _, found, code := fetchArea(ctx, client, id); code != "" || !found {
+		if code == "" {
+			code = apierror.CodeNotFound
+		}
+		apierror.Respond(c, code)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	if _, status, delErr := client.Delete(ctx, "/rest/v1/area", q); delErr != nil {
+		// staff.area_division の外部キー（on delete restrict）に反する場合
+		if status == http.StatusConflict {
+			apierror.Respond(c, apierror.CodeInUse)
+			return
+		}
+		log.Printf("DB_003: supabase delete area error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// fetchArea 地域区分1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchArea(ctx context.Context, client *supa.Client, id string) (AreaDTO, bool, string) {
+	q := url.Values{}
+	q.Set("select", areaSelect)
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/area", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get area error: %v", getErr)
+		return AreaDTO{}, false, apierror.CodeDBInit
+	}
+	var rows []AreaDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (area): %v", err)
+		return AreaDTO{}, false, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return AreaDTO{}, false, ""
+	}
+	return rows[0], true, ""
+}
+
+// nameENValue 英語名の保存値（未指定・空白は未設定）
+func nameENValue(p *string) any {
+	if p == nil || strings.TrimSpace(*p) == "" {
+		return nil
+	}
+	return strings.TrimSpace(*p)
+}
+
+func toResponse(a AreaDTO, lang string) AreaResponse {
+	return AreaResponse{
+		ID:        a.ID,
+		Name:      a.Name,
+		Label:     a.Label(lang),
+		NameEN:    a.NameEN,
+		Rank:      a.Rank,
+		CreatedAt: deref(a.CreatedAt),
+		UpdatedAt: deref(a.UpdatedAt),
+		Version:   etag.FromUpdatedAt(a.UpdatedAt),
+	}
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// clientCertSubjectPrefix mTLS の機械クライアントの Subject（"cert:" + 証明書の CN）
+const clientCertSubjectPrefix = "cert:"
+
+// ClientCertRolesFromEnv mTLS の機械クライアントの役割（TLS_CLIENT_ROLES）
+// "CN=役割" のカンマ区切り（例: billing-batch=dispatcher,monitor=staff）。登録の無い CN は匿名として扱う
+func ClientCertRolesFromEnv() map[string]string {
+	roles := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("TLS_CLIENT_ROLES"), ",") {
+		cn, role, ok := strings.Cut(pair, "=")
+		cn, role = strings.TrimSpace(cn), strings.ToLower(strings.TrimSpace(role))
+		if ok && cn != "" && role != "" {
+			roles[cn] = role
+		}
+	}
+	return roles
+}
+
+// clientCertPrincipal TLS で検証済みのクライアント証明書の CN を利用者にする（roles に登録された CN のみ）
+func clientCertPrincipal(r *http.Request, roles map[string]string) (Principal, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return Principal{}, false
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role, ok := roles[cn]
+	if cn == "" || !ok {
+		return Principal{}, false
+	}
+	return Principal{Subject: clientCertSubjectPrefix + cn, Name: cn, Role: role}, true
+}
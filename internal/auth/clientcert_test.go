@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCertRolesFromEnv(t *testing.T) {
+	t.Setenv("TLS_CLIENT_ROLES", " billing-batch = Dispatcher ,monitor=staff,broken,=admin,empty=")
+	got := ClientCertRolesFromEnv()
+	want := map[string]string{"billing-batch": "dispatcher", "monitor": "staff"}
+	if len(got) != len(want) {
+		t.Fatalf("roles = %v, want %v", got, want)
+	}
+	for cn, role := range want {
+		if got[cn] != role {
+			t.Errorf("roles[%q] = %q, want %q", cn, got[cn], role)
+		}
+	}
+}
+
+func TestClientCertPrincipal(t *testing.T) {
+	roles := map[string]string{"billing-batch": RoleDispatcher}
+	withCN := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+	}
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  Principal
+		ok    bool
+	}{
+		{"plaintext", nil, Principal{}, false},
+		{"no client certificate", &tls.ConnectionState{}, Principal{}, false},
+		{"unregistered CN", withCN("intruder"), Principal{}, false},
+		{"registered CN", withCN("billing-batch"), Principal{Subject: "cert:billing-batch", Name: "billing-batch", Role: RoleDispatcher}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/roster", nil)
+			r.TLS = tt.state
+			got, ok := clientCertPrincipal(r, roles)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("clientCertPrincipal = %+v, %v; want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginRequest struct {
+	LoginID  string `json:"loginId" binding:"required,max=64"`
+	Password string `json:"password" binding:"required,max=200"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required,max=200"`
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"omitempty,max=200"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required,max=200"`
+	NewPassword     string `json:"newPassword" binding:"required,password"`
+}
+
+type SetPasswordRequest struct {
+	Password string `json:"password" binding:"required,password"`
+}
+
+type LoginUser struct {
+	ID      string `json:"id"` // staff.id
+	LoginID string `json:"loginId"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+}
+
+// TokenResponse ログイン・更新・パスワード変更の応答
+// totpRequired / passwordChangeRequired / totpSetupRequired の場合はその手順にだけ使えるトークンを返し、refreshToken は返さない
+type TokenResponse struct {
+	AccessToken            string    `json:"accessToken"`
+	TokenType              string    `json:"tokenType"`
+	ExpiresIn              int       `json:"expiresIn"` // 秒
+	RefreshToken           string    `json:"refreshToken,omitempty"`
+	RefreshExpiresIn       int       `json:"refreshExpiresIn,omitempty"` // 秒
+	TOTPRequired           bool      `json:"totpRequired"`               // POST /api/auth/totp/verify で確認コードを入力する
+	PasswordChangeRequired bool      `json:"passwordChangeRequired"`     // POST /api/auth/password で初期パスワードを変更する
+	TOTPSetupRequired      bool      `json:"totpSetupRequired"`          // POST /api/auth/totp/enroll で認証アプリを登録する
+	User                   LoginUser `json:"user"`
+}
+
+// LoginHandler ログインIDとパスワードでログインする（POST /api/auth/login）
+// 失敗が続いたアカウントは一定時間ログインを止める（応答は失敗と同じ 401）。2段階認証・初期パスワードの変更が残っている場合は completeLogin を参照
+func LoginHandler(c *gin.Context) {
+	var req LoginRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, code := fetchAccountByLoginID(ctx, client, strings.TrimSpace(req.LoginID))
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if a == nil {
+		CheckPassword("", req.Password) // 存在しないログインIDでも同じだけ時間をかける
+		apierror.Respond(c, apierror.CodeLoginFailed)
+		return
+	}
+	// 停止中も存在しないログインIDと同じ応答にする（423 だとログインIDの有無が、パスワードの照合後に 423 だと正否が分かる）
+	if until, locked := a.lockedUntil(time.Now()); locked {
+		CheckPassword("", req.Password)
+		log.Printf("AUTH_423: login attempt for %s while locked until %s", a.LoginID, until.Format(time.RFC3339))
+		apierror.Respond(c, apierror.CodeLoginFailed)
+		return
+	}
+	if !CheckPassword(deref(a.PasswordHash), req.Password) {
+		if until, locked := recordLoginFailure(ctx, client, a.StaffID); locked {
+			log.Printf("AUTH_423: login locked for %s until %s", a.LoginID, until.Format(time.RFC3339))
+		}
+		apierror.Respond(c, apierror.CodeLoginFailed)
+		return
+	}
+	if !a.usable() {
+		apierror.Respond(c, apierror.CodeAccountDisabled)
+		return
+	}
+
+	if code := updateAccount(ctx, client, a.StaffID, map[string]any{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+		"last_login_at":      time.Now().UTC().Format(time.RFC3339),
+	}); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	completeLogin(ctx, client, c, a, false)
+}
+
+// completeLogin パスワードの確認後、残っている手順があればその手順にだけ使えるトークンを、なければトークンを発行する
+// totpDone は確認コードの入力が済んでいる（または不要な）場合に true
+func completeLogin(ctx context.Context, client *supa.Client, c *gin.Context, a *account, totpDone bool) {
+	resp, code := nextLoginStep(ctx, client, c, a, totpDone)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	respondTokens(c, resp)
+}
+
+// nextLoginStep ログインの残りの手順を判定してトークンを作る
+// 手順は 2段階認証の確認コード → 初期パスワードの変更 → 2段階認証の登録（役職により必須の場合）の順
+func nextLoginStep(ctx context.Context, client *supa.Client, c *gin.Context, a *account, totpDone bool) (TokenResponse, string) {
+	resp := TokenResponse{TokenType: "Bearer", User: loginUser(a)}
+	var scope string
+	switch {
+	case a.totpEnabled() && !totpDone:
+		scope, resp.TOTPRequired = ScopeTOTP, true
+	case a.MustChangePassword:
+		scope, resp.PasswordChangeRequired = ScopePasswordChange, true
+	case a.totpRequired() && !a.totpEnabled():
+		scope, resp.TOTPSetupRequired = ScopeTOTPSetup, true
+	default:
+		return issueTokens(ctx, client, c, a)
+	}
+	p := a.principal("")
+	p.Scope = scope
+	token, err := Sign(p, restrictedTokenTTL)
+	if err != nil {
+		log.Printf("AUTH_401: token sign error: %v", err)
+		return TokenResponse{}, apierror.CodeInternal
+	}
+	resp.AccessToken = token
+	resp.ExpiresIn = int(restrictedTokenTTL.Seconds())
+	return resp, ""
+}
+
+// RefreshHandler リフレッシュトークンで新しいトークンを発行する（POST /api/auth/refresh）
+// 使ったリフレッシュトークンは無効になる。無効にしたトークンが再び使われた場合は、そのスタッフの全セッションを無効にする
+func RefreshHandler(c *gin.Context) {
+	var req RefreshRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	s, code := findSession(ctx, client, req.RefreshToken)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if s == nil || s.expired(time.Now()) {
+		apierror.Respond(c, apierror.CodeUnauthorized)
+		return
+	}
+	// 同時に同じトークンで更新された場合も、無効にできた1件だけを通す
+	n, code := 0, ""
+	if s.RevokedAt == nil {
+		n, code = revokeSessionByID(ctx, client, s.ID)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+	}
+	if n == 0 {
+		log.Printf("AUTH_401: refresh token reuse for staff %s; revoking all sessions", s.StaffID)
+		_ = revokeStaffSessions(ctx, client, s.StaffID)
+		apierror.Respond(c, apierror.CodeUnauthorized)
+		return
+	}
+
+	a, code := fetchAccountByStaffID(ctx, client, s.StaffID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if a == nil || !a.usable() {
+		apierror.Respond(c, apierror.CodeAccountDisabled)
+		return
+	}
+	completeLogin(ctx, client, c, a, true)
+}
+
+// LogoutHandler ログアウトする（POST /api/auth/logout）
+// アクセストークンのセッションと、指定されたリフレッシュトークンのセッションを無効にする。該当がなくても 204
+func LogoutHandler(c *gin.Context) {
+	var req LogoutRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil && c.Request.ContentLength > 0 {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	if p, ok := Current(c); ok && p.Session != "" {
+		if _, code := revokeSessionByID(ctx, client, p.Session); code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+	}
+	if req.RefreshToken != "" {
+		s, code := findSession(ctx, client, req.RefreshToken)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		if s != nil {
+			if _, code := revokeSessionByID(ctx, client, s.ID); code != "" {
+				apierror.Respond(c, code)
+				return
+			}
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusNoContent)
+}
+
+// ChangePasswordHandler 自分のパスワードを変更する（POST /api/auth/password）
+// 初期パスワードでログインした場合のトークンでも呼べる。変更後は他のセッションを無効にし、新しいトークンを返す
+func ChangePasswordHandler(c *gin.Context) {
+	p, ok := currentInScope(c, ScopePasswordChange)
+	if !ok {
+		return
+	}
+	var req ChangePasswordRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	lang := apierror.Lang(c)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, code := fetchAccountByStaffID(ctx, client, p.Subject)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if a == nil || !a.usable() {
+		apierror.Respond(c, apierror.CodeAccountDisabled)
+		return
+	}
+	if until, locked := a.lockedUntil(time.Now()); locked {
+		respondLocked(c, until)
+		return
+	}
+	if !CheckPassword(deref(a.PasswordHash), req.CurrentPassword) {
+		if until, locked := recordLoginFailure(ctx, client, a.StaffID); locked {
+			respondLocked(c, until)
+			return
+		}
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(lang, "currentPassword", "password_mismatch", ""),
+		})
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(lang, "newPassword", "password_reuse", ""),
+		})
+		return
+	}
+
+	if code := setPassword(ctx, client, a.StaffID, req.NewPassword, false); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	a.MustChangePassword = false
+	completeLogin(ctx, client, c, a, true)
+}
+
+// SetPasswordHandler スタッフの初期パスワードを設定する（PUT /api/staff/:id/password。管理者向け）
+// 次回ログイン時に変更が必要になる。ログインの停止を解除し、既存のセッションは無効にする
+func SetPasswordHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req SetPasswordRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, code := fetchAccountByStaffID(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if a == nil {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if code := setPassword(ctx, client, a.StaffID, req.Password, true); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if admin, ok := Current(c); ok {
+		log.Printf("AUTH: initial password set for %s by %s", a.LoginID, admin.Subject)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusNoContent)
+}
+
+// setPassword パスワードを保存し、ログインの失敗回数・停止を解除して全セッションを無効にする
+func setPassword(ctx context.Context, client *supa.Client, staffID, password string, mustChange bool) string {
+	hash, err := HashPassword(password)
+	if err != nil {
+		log.Printf("SYS_500: password hash error: %v", err)
+		return apierror.CodeInternal
+	}
+	if code := updateAccount(ctx, client, staffID, map[string]any{
+		"password_hash":        hash,
+		"password_changed_at":  time.Now().UTC().Format(time.RFC3339),
+		"must_change_password": mustChange,
+		"failed_login_count":   0,
+		"locked_until":         nil,
+	}); code != "" {
+		return code
+	}
+	return revokeStaffSessions(ctx, client, staffID)
+}
+
+// issueTokens セッションを作成してアクセストークンとリフレッシュトークンを返す
+func issueTokens(ctx context.Context, client *supa.Client, c *gin.Context, a *account) (TokenResponse, string) {
+	sessionID, refresh, expires, code := createSession(ctx, client, c, a.StaffID)
+	if code != "" {
+		return TokenResponse{}, code
+	}
+	ttl := AccessTokenTTL()
+	token, err := Sign(a.principal(sessionID), ttl)
+	if err != nil {
+		log.Printf("AUTH_401: token sign error: %v", err)
+		return TokenResponse{}, apierror.CodeInternal
+	}
+	return TokenResponse{
+		AccessToken:      token,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(ttl.Seconds()),
+		RefreshToken:     refresh,
+		RefreshExpiresIn: int(time.Until(expires).Round(time.Second).Seconds()),
+		User:             loginUser(a),
+	}, ""
+}
+
+// respondTokens トークンは保存・再送させない（Idempotency-Key の保存対象からも外れる）
+func respondTokens(c *gin.Context, resp TokenResponse) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondLocked(c *gin.Context, until time.Time) {
+	secs := int(math.Ceil(time.Until(until).Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(secs))
+	apierror.Respond(c, apierror.CodeAccountLocked)
+}
+
+func loginUser(a *account) LoginUser {
+	return LoginUser{ID: a.StaffID, LoginID: a.LoginID, Name: a.displayName(), Role: a.AccessType}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+
+	"github.com/gin-gonic/gin"
+)
+
+const principalKey = "authPrincipal"
+
+// PermAccountAdmin スタッフのアカウントの項目（ログインID・権限・状態・調整率）を変更できる
+const PermAccountAdmin = "account_admin"
+
+// permitKey PermitIf で付けた権限のコンテキストのキー
+func permitKey(perm string) string { return "authPermit:" + perm }
+
+// Middleware Authorization: Bearer のトークンを検証し、利用者をコンテキストに載せる
+// トークンが無い場合は mTLS で検証済みのクライアント証明書（TLS_CLIENT_ROLES に登録した CN）を利用者にする
+// どちらも無い・検証できないリクエストも匿名として通し、拒否は RequireRole で行う
+// ログインで発行したトークンは、セッション（ログアウト・パスワードの変更・利用停止などで無効になる）も確かめる
+func Middleware() gin.HandlerFunc {
+	certRoles := ClientCertRolesFromEnv()
+	return func(c *gin.Context) {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") && strings.TrimSpace(token) != "" {
+			if p, err := Verify(strings.TrimSpace(token)); err == nil && sessionValid(c, p) {
+				c.Set(principalKey, p)
+			}
+		} else if p, ok := clientCertPrincipal(c.Request, certRoles); ok {
+			c.Set(principalKey, p)
+		}
+		c.Next()
+	}
+}
+
+// sessionValid トークンのセッションが有効か（セッションの無いトークンは署名と有効期限だけで判断する）
+// セッションを確かめられない場合も、無効として扱う
+func sessionValid(c *gin.Context, p Principal) bool {
+	if p.Session == "" {
+		return true
+	}
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		return false
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	active, err := sessionActive(ctx, client, p.Session)
+	if err != nil {
+		log.Printf("DB_001: supabase get staff_session error: %v", err)
+		return false
+	}
+	if !active {
+		log.Printf("AUTH_401: session %s of %s is revoked or expired", p.Session, p.Subject)
+	}
+	return active
+}
+
+// Current 認証済みの利用者を返す（匿名の場合は ok=false）
+func Current(c *gin.Context) (Principal, bool) {
+	v, ok := c.Get(principalKey)
+	if !ok {
+		return Principal{}, false
+	}
+	p, ok := v.(Principal)
+	return p, ok
+}
+
+// RequireRole 指定した役割の利用者だけを通す（未認証は 401、役割が無い場合は 403）
+// ログインの途中（2段階認証・初期パスワードの変更が済んでいない）の利用者も 403
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := Current(c)
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			apierror.Respond(c, apierror.CodeUnauthorized)
+			return
+		}
+		if p.Scope != "" {
+			apierror.Respond(c, scopeCode(p.Scope))
+			return
+		}
+		if !slices.Contains(roles, p.Role) {
+			log.Printf("AUTH_403: %s (%s) denied for %s %s", p.Subject, p.Role, c.Request.Method, c.FullPath())
+			apierror.Respond(c, apierror.CodeForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// PermitIf 指定した役割の利用者に権限 perm を付ける（拒否はせず、ハンドラーが Permitted で確かめる）
+// 一部の項目だけ変更できる役割を限る API で使う
+func PermitIf(perm string, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p, ok := Current(c); ok && p.Scope == "" && slices.Contains(roles, p.Role) {
+			c.Set(permitKey(perm), true)
+		}
+		c.Next()
+	}
+}
+
+// Permitted PermitIf で権限 perm が付いているか
+func Permitted(c *gin.Context, perm string) bool {
+	return c.GetBool(permitKey(perm))
+}
+
+// RespondDenied 権限の無い操作を拒否する（未認証は 401、ログインの途中・役割が無い場合は 403）
+func RespondDenied(c *gin.Context) {
+	p, ok := Current(c)
+	if !ok {
+		c.Header("WWW-Authenticate", `Bearer realm="api"`)
+		apierror.Respond(c, apierror.CodeUnauthorized)
+		return
+	}
+	if p.Scope != "" {
+		apierror.Respond(c, scopeCode(p.Scope))
+		return
+	}
+	log.Printf("AUTH_403: %s (%s) denied for %s %s", p.Subject, p.Role, c.Request.Method, c.FullPath())
+	apierror.Respond(c, apierror.CodeForbidden)
+}
+
+// currentInScope 認証済みで、制限なしまたは scopes のいずれかのトークンの利用者を返す
+// 該当しない場合は応答済みで ok=false
+func currentInScope(c *gin.Context, scopes ...string) (Principal, bool) {
+	p, ok := Current(c)
+	if !ok {
+		c.Header("WWW-Authenticate", `Bearer realm="api"`)
+		apierror.Respond(c, apierror.CodeUnauthorized)
+		return Principal{}, false
+	}
+	if p.Scope != "" && !slices.Contains(scopes, p.Scope) {
+		apierror.Respond(c, scopeCode(p.Scope))
+		return Principal{}, false
+	}
+	return p, true
+}
+
+// scopeCode 制限付きトークンで他の操作をしようとした場合のエラーコード（次に行う手順）
+func scopeCode(scope string) string {
+	switch scope {
+	case ScopeTOTP:
+		return apierror.CodeTOTPRequired
+	case ScopePasswordChange:
+		return apierror.CodePasswordChangeRequired
+	case ScopeTOTPSetup:
+		return apierror.CodeTOTPSetupRequired
+	default:
+		return apierror.CodeForbidden
+	}
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash ログインIDが存在しない場合も照合と同じ時間をかけるためのハッシュ
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password-0"), bcrypt.DefaultCost)
+
+// HashPassword パスワードを bcrypt でハッシュにする
+func HashPassword(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+// CheckPassword ハッシュとパスワードが一致するか（hash が空の場合もダミーで照合して false）
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,408 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 既定値（環境変数で変更できる）
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	restrictedTokenTTL     = 10 * time.Minute // ログインの途中の手順にだけ使えるトークン
+	defaultSessionCheckTTL = 30 * time.Second
+	defaultMaxFailures     = 5
+	defaultLockMinutes     = 15
+)
+
+// AccessTokenTTL アクセストークンの有効期間（AUTH_ACCESS_TOKEN_TTL。例: 15m）
+func AccessTokenTTL() time.Duration {
+	return envDuration("AUTH_ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL リフレッシュトークン（セッション）の有効期間（AUTH_REFRESH_TOKEN_TTL。例: 168h）
+func RefreshTokenTTL() time.Duration {
+	return envDuration("AUTH_REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+// SessionCheckTTL アクセストークンのセッションの確認結果を使い回す時間（AUTH_SESSION_CHECK_TTL。例: 30s）
+// 他のプロセスや DB のトリガーで無効にしたセッションのアクセストークンは、最大この時間だけ使え続ける
+func SessionCheckTTL() time.Duration {
+	return envDuration("AUTH_SESSION_CHECK_TTL", defaultSessionCheckTTL)
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(os.Getenv(name))); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv(name))); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// totpRequiredRoles 2段階認証を必須にする役職キー（TOTP_REQUIRED_ROLES。カンマ区切り）
+func totpRequiredRoles() []string {
+	return RolesFromEnv("TOTP_REQUIRED_ROLES", "chairman", "president", "general_manager", "admin_manager")
+}
+
+// account staff_account の行（ログインに使う列とスタッフの氏名・在職状態）
+type account struct {
+	StaffID            string  `json:"staff_id"`
+	LoginID            string  `json:"login_id"`
+	AccessType         string  `json:"access_type"`
+	AccessStatus       string  `json:"access_status"`
+	PasswordHash       *string `json:"password_hash"`
+	MustChangePassword bool    `json:"must_change_password"`
+	LockedUntil        *string `json:"locked_until"`
+	TOTPSecret         *string `json:"totp_secret"` // 暗号化済み
+	TOTPEnabledAt      *string `json:"totp_enabled_at"`
+	TOTPLastStep       *int64  `json:"totp_last_step"`
+	Staff              *struct {
+		LastName  *string `json:"last_name"`
+		FirstName *string `json:"first_name"`
+		Status    *bool   `json:"status"`
+		Position  *struct {
+			Key string `json:"key"`
+		} `json:"position"`
+	} `json:"staff"`
+}
+
+const accountSelect = "staff_id,login_id,access_type,access_status,password_hash,must_change_password,locked_until," +
+	"totp_secret,totp_enabled_at,totp_last_step,staff(last_name,first_name,status,position:position_id(key))"
+
+// fetchAccount staff_account を1件取得する（該当なしは nil）
+func fetchAccount(ctx context.Context, client *supa.Client, q url.Values) (*account, string) {
+	q.Set("select", accountSelect)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_account", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_account error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []account
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_account): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return nil, ""
+	}
+	return &rows[0], ""
+}
+
+// fetchAccountByLoginID ログインIDで探す（大文字小文字を区別しない）
+func fetchAccountByLoginID(ctx context.Context, client *supa.Client, loginID string) (*account, string) {
+	q := url.Values{}
+	q.Set("login_id", "ilike."+supa.EscapeLike(loginID))
+	return fetchAccount(ctx, client, q)
+}
+
+func fetchAccountByStaffID(ctx context.Context, client *supa.Client, staffID string) (*account, string) {
+	q := url.Values{}
+	q.Set("staff_id", "eq."+staffID)
+	return fetchAccount(ctx, client, q)
+}
+
+func (a *account) displayName() string {
+	if a.Staff == nil {
+		return a.LoginID
+	}
+	name := strings.TrimSpace(deref(a.Staff.LastName) + " " + deref(a.Staff.FirstName))
+	if name == "" {
+		return a.LoginID
+	}
+	return name
+}
+
+// usable アカウントが有効で、スタッフが退職していない
+func (a *account) usable() bool {
+	if a.AccessStatus != "active" {
+		return false
+	}
+	return a.Staff == nil || a.Staff.Status == nil || *a.Staff.Status
+}
+
+// lockedUntil ログインの停止期限（停止中でなければ ok=false）
+func (a *account) lockedUntil(now time.Time) (time.Time, bool) {
+	if a.LockedUntil == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, *a.LockedUntil)
+	if err != nil || !t.After(now) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// totpEnabled 2段階認証の登録が済んでいる
+func (a *account) totpEnabled() bool {
+	return a.TOTPEnabledAt != nil && a.TOTPSecret != nil
+}
+
+// totpRequired 役職により2段階認証が必須（TOTP_REQUIRED_ROLES）
+func (a *account) totpRequired() bool {
+	if a.Staff == nil || a.Staff.Position == nil {
+		return false
+	}
+	return slices.Contains(totpRequiredRoles(), a.Staff.Position.Key)
+}
+
+func (a *account) lastStep() int64 {
+	if a.TOTPLastStep == nil {
+		return 0
+	}
+	return *a.TOTPLastStep
+}
+
+func (a *account) principal(sessionID string) Principal {
+	return Principal{Subject: a.StaffID, Name: a.displayName(), Role: a.AccessType, Session: sessionID}
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// recordLoginFailure ログイン失敗を記録する。上限に達してログインを止めた場合は停止期限を返す
+func recordLoginFailure(ctx context.Context, client *supa.Client, staffID string) (time.Time, bool) {
+	body, _, rpcErr := client.RPC(ctx, "record_staff_login_failure", map[string]any{
+		"p_staff_id":     staffID,
+		"p_max_failures": envInt("LOGIN_MAX_FAILURES", defaultMaxFailures),
+		"p_lock_minutes": envInt("LOGIN_LOCK_MINUTES", defaultLockMinutes),
+	})
+	if rpcErr != nil {
+		log.Printf("DB_003: record_staff_login_failure error: %v body=%s", rpcErr, string(body))
+		return time.Time{}, false
+	}
+	var rows []struct {
+		LockedUntil *string `json:"locked_until"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) == 0 {
+		return time.Time{}, false
+	}
+	a := account{LockedUntil: rows[0].LockedUntil}
+	return a.lockedUntil(time.Now())
+}
+
+// updateAccount staff_account を更新する
+func updateAccount(ctx context.Context, client *supa.Client, staffID string, patch map[string]any) string {
+	q := url.Values{}
+	q.Set("staff_id", "eq."+staffID)
+	if _, _, err := client.Patch(ctx, "/rest/v1/staff_account", q, patch); err != nil {
+		log.Printf("DB_003: supabase patch staff_account error: %v", err)
+		return apierror.CodeDBUpdate
+	}
+	return ""
+}
+
+// session staff_session の行
+type session struct {
+	ID        string  `json:"id"`
+	StaffID   string  `json:"staff_id"`
+	ExpiresAt string  `json:"expires_at"`
+	RevokedAt *string `json:"revoked_at"`
+}
+
+func (s *session) expired(now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, s.ExpiresAt)
+	return err != nil || !t.After(now)
+}
+
+// hashRefreshToken DB にはリフレッシュトークンの SHA-256 だけを保存する
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createSession セッションを作成し、セッションID・リフレッシュトークン・有効期限を返す
+func createSession(ctx context.Context, client *supa.Client, c *gin.Context, staffID string) (string, string, time.Time, string) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("SYS_500: refresh token generation error: %v", err)
+		return "", "", time.Time{}, apierror.CodeInternal
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	expires := time.Now().Add(RefreshTokenTTL())
+	row := map[string]any{
+		"staff_id":           staffID,
+		"refresh_token_hash": hashRefreshToken(token),
+		"expires_at":         expires.UTC().Format(time.RFC3339),
+		"user_agent":         truncate(c.Request.UserAgent(), 500),
+		"client_ip":          c.ClientIP(),
+	}
+	q := url.Values{}
+	q.Set("select", "id")
+	body, _, postErr := client.Post(ctx, "/rest/v1/staff_session", q, row)
+	if postErr != nil {
+		log.Printf("DB_003: supabase insert staff_session error: %v", postErr)
+		return "", "", time.Time{}, apierror.CodeDBUpdate
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) == 0 {
+		log.Printf("DB_002: json decode error (staff_session insert): %v", err)
+		return "", "", time.Time{}, apierror.CodeDBDecode
+	}
+	return rows[0].ID, token, expires, ""
+}
+
+// findSession リフレッシュトークンのセッションを探す（該当なしは nil）
+func findSession(ctx context.Context, client *supa.Client, token string) (*session, string) {
+	q := url.Values{}
+	q.Set("select", "id,staff_id,expires_at,revoked_at")
+	q.Set("refresh_token_hash", "eq."+hashRefreshToken(token))
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_session", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_session error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []session
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_session): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return nil, ""
+	}
+	return &rows[0], ""
+}
+
+// revokeSessions 条件に合う有効なセッションを無効にし、無効にした件数を返す
+func revokeSessions(ctx context.Context, client *supa.Client, q url.Values) (int, string) {
+	q.Set("revoked_at", "is.null")
+	q.Set("select", "id")
+	body, _, patchErr := client.Patch(ctx, "/rest/v1/staff_session", q, map[string]any{
+		"revoked_at": time.Now().UTC().Format(time.RFC3339),
+	})
+	if patchErr != nil {
+		log.Printf("DB_003: supabase revoke staff_session error: %v", patchErr)
+		return 0, apierror.CodeDBUpdate
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	_ = json.Unmarshal(body, &rows)
+	for _, r := range rows {
+		sessionChecks.forget(r.ID)
+	}
+	return len(rows), ""
+}
+
+func revokeSessionByID(ctx context.Context, client *supa.Client, id string) (int, string) {
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	return revokeSessions(ctx, client, q)
+}
+
+func revokeStaffSessions(ctx context.Context, client *supa.Client, staffID string) string {
+	q := url.Values{}
+	q.Set("staff_id", "eq."+staffID)
+	_, code := revokeSessions(ctx, client, q)
+	return code
+}
+
+// sessionCheck アクセストークンのセッションの確認結果
+type sessionCheck struct {
+	active    bool
+	checkedAt time.Time
+}
+
+// sessionCheckCache セッションID → 確認結果
+type sessionCheckCache struct {
+	mu sync.Mutex
+	m  map[string]sessionCheck
+}
+
+var sessionChecks = &sessionCheckCache{m: map[string]sessionCheck{}}
+
+// maxSessionChecks これを超えたら古い確認結果を捨てる
+const maxSessionChecks = 10000
+
+func (c *sessionCheckCache) get(id string, now time.Time, ttl time.Duration) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.m[id]
+	if !ok || now.Sub(r.checkedAt) >= ttl {
+		return false, false
+	}
+	return r.active, true
+}
+
+func (c *sessionCheckCache) put(id string, active bool, now time.Time, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.m) >= maxSessionChecks {
+		for k, r := range c.m {
+			if now.Sub(r.checkedAt) >= ttl {
+				delete(c.m, k)
+			}
+		}
+	}
+	c.m[id] = sessionCheck{active: active, checkedAt: now}
+}
+
+// forget このプロセスで無効にしたセッションは、次の確認で DB を見直す
+func (c *sessionCheckCache) forget(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.m, id)
+}
+
+// sessionActive アクセストークンのセッションが無効化・期限切れになっていないか
+// 確認結果は SessionCheckTTL の間使い回す
+func sessionActive(ctx context.Context, client *supa.Client, id string) (bool, error) {
+	now := time.Now()
+	ttl := SessionCheckTTL()
+	if active, ok := sessionChecks.get(id, now, ttl); ok {
+		return active, nil
+	}
+	q := url.Values{}
+	q.Set("select", "id,staff_id,expires_at,revoked_at")
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, err := client.Get(ctx, "/rest/v1/staff_session", q)
+	if err != nil {
+		return false, err
+	}
+	var rows []session
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return false, err
+	}
+	active := len(rows) == 1 && rows[0].RevokedAt == nil && !rows[0].expired(now)
+	sessionChecks.put(id, active, now, ttl)
+	return active, nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+// 役割
+const (
+	RoleAdmin      = "admin"
+	RoleManager    = "manager"
+	RoleDispatcher = "dispatcher"
+	RoleStaff      = "staff"
+)
+
+var (
+	ErrNoSecret     = errors.New("AUTH_TOKEN_SECRET is not set")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Principal 認証済みの利用者
+type Principal struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Role    string `json:"role"`
+	Session string `json:"sid,omitempty"` // ログインで発行した場合のセッション（staff_session.id）
+	// ログインの途中で発行した制限付きのトークン（Scope*）。空なら制限なし
+	Scope string `json:"scope,omitempty"`
+}
+
+// 制限付きトークンの用途（ログインの途中の手順でだけ使える）
+const (
+	ScopeTOTP           = "totp"            // 2段階認証の確認コードの入力
+	ScopePasswordChange = "password_change" // 初期パスワードの変更
+	ScopeTOTPSetup      = "totp_setup"      // 2段階認証が必須の役職で、認証アプリの登録
+)
+
+type claims struct {
+	Principal
+	ExpiresAt int64 `json:"exp"`
+}
+
+// secret 署名鍵（AUTH_TOKEN_SECRET）。未設定の場合はトークンを発行・検証しない
+func secret() ([]byte, error) {
+	s := strings.TrimSpace(os.Getenv("AUTH_TOKEN_SECRET"))
+	if s == "" {
+		return nil, ErrNoSecret
+	}
+	return []byte(s), nil
+}
+
+// Sign 利用者を ttl の間有効なトークンにする
+// 形式は base64url(JSON).base64url(HMAC-SHA256)
+func Sign(p Principal, ttl time.Duration) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims{Principal: p, ExpiresAt: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac(key, body)), nil
+}
+
+// Verify トークンの署名と有効期限を確認して利用者を返す
+func Verify(token string) (Principal, error) {
+	key, err := secret()
+	if err != nil {
+		return Principal{}, err
+	}
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Principal{}, ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(key, body)) {
+		return Principal{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	var cl claims
+	if err := json.Unmarshal(payload, &cl); err != nil || cl.Subject == "" || cl.Role == "" {
+		return Principal{}, ErrInvalidToken
+	}
+	if time.Now().Unix() >= cl.ExpiresAt {
+		return Principal{}, ErrExpiredToken
+	}
+	return cl.Principal, nil
+}
+
+func mac(key []byte, body string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(body))
+	return h.Sum(nil)
+}
+
+// RolesFromEnv カンマ区切りの役割一覧を環境変数から読み込む（未設定時は defaults）
+func RolesFromEnv(name string, defaults ...string) []string {
+	var roles []string
+	for _, r := range strings.Split(os.Getenv(name), ",") {
+		if t := strings.ToLower(strings.TrimSpace(r)); t != "" {
+			roles = append(roles, t)
+		}
+	}
+	if len(roles) == 0 {
+		return defaults
+	}
+	return roles
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TOTP（RFC 6238）の設定。一般的な認証アプリの既定値（SHA-1・6桁・30秒）に合わせる
+const (
+	totpDigits     = 6
+	totpPeriod     = 30
+	totpSkew       = 1 // 前後1ステップ（±30秒）のずれを許す
+	totpSecretSize = 20
+)
+
+var (
+	ErrNoTOTPKey     = errors.New("TOTP_ENCRYPTION_KEY is not set")
+	ErrInvalidSecret = errors.New("invalid TOTP secret")
+
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// newTOTPSecret 認証アプリに登録する秘密鍵（Base32）を作る
+func newTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(buf), nil
+}
+
+// totpCode step（Unix 時刻 / 30秒）の確認コード
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	h := hmac.New(sha1.New, key)
+	h.Write(msg[:])
+	sum := h.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	n := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, n%mod)
+}
+
+// verifyTOTP 確認コードを照合し、一致したステップを返す
+// lastStep 以前のステップは使用済みとして受け付けない（同じコードの再利用を防ぐ）
+func verifyTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	cur := now.Unix() / totpPeriod
+	for d := -totpSkew; d <= totpSkew; d++ {
+		step := cur + int64(d)
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// totpIssuer 認証アプリに表示するサービス名（TOTP_ISSUER。未設定時は nissyo）
+func totpIssuer() string {
+	if v := strings.TrimSpace(os.Getenv("TOTP_ISSUER")); v != "" {
+		return v
+	}
+	return "nissyo"
+}
+
+// provisioningURI 認証アプリ登録用の otpauth URI（QR コードにして表示する）
+func provisioningURI(secret, account string) string {
+	issuer := totpIssuer()
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpAEAD 秘密鍵の暗号化に使う AES-256-GCM（鍵は TOTP_ENCRYPTION_KEY の SHA-256）
+func totpAEAD() (cipher.AEAD, error) {
+	k := strings.TrimSpace(os.Getenv("TOTP_ENCRYPTION_KEY"))
+	if k == "" {
+		return nil, ErrNoTOTPKey
+	}
+	sum := sha256.Sum256([]byte(k))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealTOTPSecret 秘密鍵を暗号化して DB に保存する形（base64(nonce || 暗号文)）にする
+func sealTOTPSecret(secret string) (string, error) {
+	aead, err := totpAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// openTOTPSecret sealTOTPSecret で保存した秘密鍵を戻す
+func openTOTPSecret(sealed string) (string, error) {
+	aead, err := totpAEAD()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", ErrInvalidSecret
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,562 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// リカバリーコードは 10 件（英小文字と数字 10 文字。紛らわしい文字は使わない）
+const (
+	recoveryCodeCount    = 10
+	recoveryCodeLength   = 10
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// VerifyTOTPRequest 確認コードまたはリカバリーコードのどちらかを指定する
+type VerifyTOTPRequest struct {
+	Code         string `json:"code" binding:"omitempty,max=10"`
+	RecoveryCode string `json:"recoveryCode" binding:"omitempty,max=20"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" binding:"required,max=10"`
+}
+
+type DisableTOTPRequest struct {
+	Password string `json:"password" binding:"required,max=200"`
+}
+
+// TOTPEnrollResponse 認証アプリの登録情報（otpauthUri を QR コードにして読み取らせる）
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"` // 手入力用（Base32）
+	OtpauthURI string `json:"otpauthUri"`
+	Issuer     string `json:"issuer"`
+	Account    string `json:"account"`
+	Digits     int    `json:"digits"`
+	Period     int    `json:"period"` // 秒
+}
+
+// TOTPConfirmResponse 登録の確認結果。リカバリーコードはこの応答でしか表示しない
+// ログインの途中（登録が必須の役職）で確認した場合は login にトークンを返す
+type TOTPConfirmResponse struct {
+	RecoveryCodes []string       `json:"recoveryCodes"`
+	Login         *TokenResponse `json:"login,omitempty"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// VerifyTOTPHandler ログイン時に2段階認証の確認コードを確認する（POST /api/auth/totp/verify）
+// ログインで totpRequired が返った場合のトークンで呼ぶ。失敗はパスワードの失敗と同じく回数に数える
+func VerifyTOTPHandler(c *gin.Context) {
+	p, ok := Current(c)
+	if !ok || p.Scope != ScopeTOTP {
+		c.Header("WWW-Authenticate", `Bearer realm="api"`)
+		apierror.Respond(c, apierror.CodeUnauthorized)
+		return
+	}
+	var req VerifyTOTPRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if len(fieldErrs) == 0 && strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "code", "required", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, ok := usableAccount(ctx, client, c, p.Subject)
+	if !ok {
+		return
+	}
+	if !a.totpEnabled() {
+		// ログインの途中で管理者が2段階認証を解除した場合
+		completeLogin(ctx, client, c, a, true)
+		return
+	}
+
+	field := "code"
+	var verified bool
+	if strings.TrimSpace(req.RecoveryCode) != "" {
+		field = "recoveryCode"
+		verified, ok = useRecoveryCode(ctx, client, c, a.StaffID, req.RecoveryCode)
+	} else {
+		verified, ok = checkTOTP(ctx, client, c, a, req.Code)
+	}
+	if !ok {
+		return
+	}
+	if !verified {
+		if until, locked := recordLoginFailure(ctx, client, a.StaffID); locked {
+			log.Printf("AUTH_423: login locked for %s until %s", a.LoginID, until.Format(time.RFC3339))
+			respondLocked(c, until)
+			return
+		}
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(lang, field, "totp", ""),
+		})
+		return
+	}
+	if code := updateAccount(ctx, client, a.StaffID, map[string]any{"failed_login_count": 0}); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	completeLogin(ctx, client, c, a, true)
+}
+
+// EnrollTOTPHandler 2段階認証の登録を始める（POST /api/auth/totp/enroll）
+// 秘密鍵を作り直して返す。confirm で確認コードを確かめるまでは有効にならない
+func EnrollTOTPHandler(c *gin.Context) {
+	p, ok := currentInScope(c, ScopeTOTPSetup)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, ok := usableAccount(ctx, client, c, p.Subject)
+	if !ok {
+		return
+	}
+	if a.totpEnabled() {
+		apierror.Respond(c, apierror.CodeDuplicate)
+		return
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		log.Printf("SYS_500: totp secret generation error: %v", err)
+		apierror.Respond(c, apierror.CodeInternal)
+		return
+	}
+	sealed, err := sealTOTPSecret(secret)
+	if err != nil {
+		log.Printf("SYS_500: totp secret encryption error: %v", err)
+		apierror.Respond(c, apierror.CodeInternal)
+		return
+	}
+	if code := updateAccount(ctx, client, a.StaffID, map[string]any{
+		"totp_secret":     sealed,
+		"totp_enabled_at": nil,
+		"totp_last_step":  nil,
+	}); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, TOTPEnrollResponse{
+		Secret:     secret,
+		OtpauthURI: provisioningURI(secret, a.LoginID),
+		Issuer:     totpIssuer(),
+		Account:    a.LoginID,
+		Digits:     totpDigits,
+		Period:     totpPeriod,
+	})
+}
+
+// ConfirmTOTPHandler 認証アプリに表示された確認コードで登録を確定する（POST /api/auth/totp/confirm）
+// リカバリーコードを作り直して返す
+func ConfirmTOTPHandler(c *gin.Context) {
+	p, ok := currentInScope(c, ScopeTOTPSetup)
+	if !ok {
+		return
+	}
+	var req TOTPCodeRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, ok := usableAccount(ctx, client, c, p.Subject)
+	if !ok {
+		return
+	}
+	if a.totpEnabled() {
+		apierror.Respond(c, apierror.CodeDuplicate)
+		return
+	}
+	if a.TOTPSecret == nil {
+		// enroll を先に呼ぶ
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	verified, ok := checkTOTP(ctx, client, c, a, req.Code)
+	if !ok {
+		return
+	}
+	if !verified {
+		if until, locked := recordLoginFailure(ctx, client, a.StaffID); locked {
+			respondLocked(c, until)
+			return
+		}
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "code", "totp", ""),
+		})
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if code := updateAccount(ctx, client, a.StaffID, map[string]any{"totp_enabled_at": now, "failed_login_count": 0}); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	a.TOTPEnabledAt = &now
+	codes, code := replaceRecoveryCodes(ctx, client, a.StaffID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	resp := TOTPConfirmResponse{RecoveryCodes: codes}
+	if p.Scope == ScopeTOTPSetup {
+		login, code := nextLoginStep(ctx, client, c, a, true)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		resp.Login = &login
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+// RegenerateRecoveryCodesHandler リカバリーコードを作り直す（POST /api/auth/totp/recovery-codes）
+// 現在の確認コードが必要。以前のコードは使えなくなる
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	p, ok := currentInScope(c)
+	if !ok {
+		return
+	}
+	var req TOTPCodeRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, ok := usableAccount(ctx, client, c, p.Subject)
+	if !ok {
+		return
+	}
+	if !a.totpEnabled() {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	verified, ok := checkTOTP(ctx, client, c, a, req.Code)
+	if !ok {
+		return
+	}
+	if !verified {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "code", "totp", ""),
+		})
+		return
+	}
+	codes, code := replaceRecoveryCodes(ctx, client, a.StaffID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTPHandler 自分の2段階認証を解除する（POST /api/auth/totp/disable）
+// パスワードの確認が必要。役職により必須の場合は解除できない（403）
+func DisableTOTPHandler(c *gin.Context) {
+	p, ok := currentInScope(c)
+	if !ok {
+		return
+	}
+	var req DisableTOTPRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, ok := usableAccount(ctx, client, c, p.Subject)
+	if !ok {
+		return
+	}
+	if a.totpRequired() {
+		apierror.Respond(c, apierror.CodeForbidden)
+		return
+	}
+	if !CheckPassword(deref(a.PasswordHash), req.Password) {
+		if until, locked := recordLoginFailure(ctx, client, a.StaffID); locked {
+			respondLocked(c, until)
+			return
+		}
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "password", "password_mismatch", ""),
+		})
+		return
+	}
+	if code := clearTOTP(ctx, client, a.StaffID); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ResetTOTPHandler スタッフの2段階認証を解除する（DELETE /api/staff/:id/totp。管理者向け）
+// 認証アプリを紛失した場合に使う。既存のセッションは無効にし、必須の役職は次回ログイン時に再登録になる
+func ResetTOTPHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	a, code := fetchAccountByStaffID(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if a == nil {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if code := clearTOTP(ctx, client, a.StaffID); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if code := revokeStaffSessions(ctx, client, a.StaffID); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if admin, ok := Current(c); ok {
+		log.Printf("AUTH: totp reset for %s by %s", a.LoginID, admin.Subject)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// usableAccount 利用者のアカウントを取得する（無効・停止中は応答済みで ok=false）
+func usableAccount(ctx context.Context, client *supa.Client, c *gin.Context, staffID string) (*account, bool) {
+	a, code := fetchAccountByStaffID(ctx, client, staffID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return nil, false
+	}
+	if a == nil || !a.usable() {
+		apierror.Respond(c, apierror.CodeAccountDisabled)
+		return nil, false
+	}
+	if until, locked := a.lockedUntil(time.Now()); locked {
+		respondLocked(c, until)
+		return nil, false
+	}
+	return a, true
+}
+
+// checkTOTP 確認コードを照合し、使ったステップを記録する（同じコードの2回目は不一致）
+// DB の失敗時は応答済みで ok=false
+func checkTOTP(ctx context.Context, client *supa.Client, c *gin.Context, a *account, code string) (verified, ok bool) {
+	secret, err := openTOTPSecret(deref(a.TOTPSecret))
+	if err != nil {
+		log.Printf("SYS_500: totp secret decryption error for %s: %v", a.LoginID, err)
+		apierror.Respond(c, apierror.CodeInternal)
+		return false, false
+	}
+	step, matched := verifyTOTP(secret, code, time.Now(), a.lastStep())
+	if !matched {
+		return false, true
+	}
+	// 同時に同じコードが使われた場合も、記録できた1件だけを通す
+	q := url.Values{}
+	q.Set("staff_id", "eq."+a.StaffID)
+	q.Set("or", "(totp_last_step.is.null,totp_last_step.lt."+strconv.FormatInt(step, 10)+")")
+	q.Set("select", "staff_id")
+	body, _, patchErr := client.Patch(ctx, "/rest/v1/staff_account", q, map[string]any{"totp_last_step": step})
+	if patchErr != nil {
+		log.Printf("DB_003: supabase patch staff_account error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return false, false
+	}
+	var rows []map[string]any
+	_ = json.Unmarshal(body, &rows)
+	return len(rows) > 0, true
+}
+
+// useRecoveryCode 未使用のリカバリーコードを使用済みにする
+func useRecoveryCode(ctx context.Context, client *supa.Client, c *gin.Context, staffID, code string) (verified, ok bool) {
+	q := url.Values{}
+	q.Set("staff_id", "eq."+staffID)
+	q.Set("code_hash", "eq."+hashRecoveryCode(code))
+	q.Set("used_at", "is.null")
+	q.Set("select", "id")
+	body, _, patchErr := client.Patch(ctx, "/rest/v1/staff_recovery_code", q, map[string]any{
+		"used_at": time.Now().UTC().Format(time.RFC3339),
+	})
+	if patchErr != nil {
+		log.Printf("DB_003: supabase patch staff_recovery_code error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return false, false
+	}
+	var rows []map[string]any
+	_ = json.Unmarshal(body, &rows)
+	if len(rows) > 0 {
+		log.Printf("AUTH: recovery code used by staff %s", staffID)
+	}
+	return len(rows) > 0, true
+}
+
+// newRecoveryCode 表示用のリカバリーコード（xxxxx-xxxxx）を1つ作る
+// 文字は rand.Int で一様に選ぶ（バイトの剰余では 31 文字のうち先頭の文字が出やすくなる）
+func newRecoveryCode() (string, error) {
+	n := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	buf := make([]byte, recoveryCodeLength)
+	for i := range buf {
+		v, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = recoveryCodeAlphabet[v.Int64()]
+	}
+	return string(buf[:recoveryCodeLength/2]) + "-" + string(buf[recoveryCodeLength/2:]), nil
+}
+
+// replaceRecoveryCodes リカバリーコードを作り直し、表示用のコード（xxxxx-xxxxx）を返す
+func replaceRecoveryCodes(ctx context.Context, client *supa.Client, staffID string) ([]string, string) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]map[string]any, 0, recoveryCodeCount)
+	for len(codes) < recoveryCodeCount {
+		code, err := newRecoveryCode()
+		if err != nil {
+			log.Printf("SYS_500: recovery code generation error: %v", err)
+			return nil, apierror.CodeInternal
+		}
+		codes = append(codes, code)
+		rows = append(rows, map[string]any{"staff_id": staffID, "code_hash": hashRecoveryCode(code)})
+	}
+	if code := deleteRecoveryCodes(ctx, client, staffID); code != "" {
+		return nil, code
+	}
+	if _, _, err := client.Post(ctx, "/rest/v1/staff_recovery_code", nil, rows); err != nil {
+		log.Printf("DB_003: supabase insert staff_recovery_code error: %v", err)
+		return nil, apierror.CodeDBUpdate
+	}
+	return codes, ""
+}
+
+func deleteRecoveryCodes(ctx context.Context, client *supa.Client, staffID string) string {
+	q := url.Values{}
+	q.Set("staff_id", "eq."+staffID)
+	if _, _, err := client.Delete(ctx, "/rest/v1/staff_recovery_code", q); err != nil {
+		log.Printf("DB_003: supabase delete staff_recovery_code error: %v", err)
+		return apierror.CodeDBUpdate
+	}
+	return ""
+}
+
+// clearTOTP 2段階認証の登録とリカバリーコードを消す
+func clearTOTP(ctx context.Context, client *supa.Client, staffID string) string {
+	if code := updateAccount(ctx, client, staffID, map[string]any{
+		"totp_secret":     nil,
+		"totp_enabled_at": nil,
+		"totp_last_step":  nil,
+	}); code != "" {
+		return code
+	}
+	return deleteRecoveryCodes(ctx, client, staffID)
+}
+
+// hashRecoveryCode 区切りと大文字小文字を無視して SHA-256 にする
+func hashRecoveryCode(code string) string {
+	norm := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(norm))
+	return hex.EncodeToString(sum[:])
+}
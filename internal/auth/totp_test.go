@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 付録 B の SHA-1 用の鍵（"12345678901234567890"）の Base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// RFC 6238 付録 B の SHA-1 のテストベクター（8桁の値の下6桁）
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	key := []byte("12345678901234567890")
+	for _, v := range rfc6238Vectors {
+		if got := totpCode(key, v.unix/totpPeriod); got != v.code {
+			t.Errorf("totpCode(T=%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestVerifyTOTPRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, ok := verifyTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0), 0)
+		if !ok || step != v.unix/totpPeriod {
+			t.Errorf("verifyTOTP(T=%d, %s) = %d, %v; want %d, true", v.unix, v.code, step, ok, v.unix/totpPeriod)
+		}
+	}
+	// 小文字の秘密鍵・空白を含むコードも受け付ける
+	if _, ok := verifyTOTP(strings.ToLower(rfc6238Secret), " 287 082 ", time.Unix(59, 0), 0); !ok {
+		t.Error("verifyTOTP rejected a lower-case secret or a spaced code")
+	}
+}
+
+func TestVerifyTOTPDrift(t *testing.T) {
+	// T=1111111109 のステップのコード 081804 を、前後のステップの時刻で照合する
+	const code = "081804"
+	step := int64(1111111109) / totpPeriod
+	tests := []struct {
+		name     string
+		at       int64
+		lastStep int64
+		ok       bool
+	}{
+		{"same step", step * totpPeriod, 0, true},
+		{"one step later", (step + 1) * totpPeriod, 0, true},
+		{"one step earlier", (step - 1) * totpPeriod, 0, true},
+		{"two steps later", (step + 2) * totpPeriod, 0, false},
+		{"two steps earlier", (step - 2) * totpPeriod, 0, false},
+		{"already used", step * totpPeriod, step, false},
+		{"later step used", (step - 1) * totpPeriod, step + 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := verifyTOTP(rfc6238Secret, code, time.Unix(tt.at, 0), tt.lastStep)
+			if ok != tt.ok {
+				t.Fatalf("verifyTOTP = %d, %v; want ok=%v", got, ok, tt.ok)
+			}
+			if ok && got != step {
+				t.Errorf("matched step = %d, want %d", got, step)
+			}
+		})
+	}
+}
+
+func TestVerifyTOTPRejects(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, tt := range []struct{ name, secret, code string }{
+		{"wrong code", rfc6238Secret, "123456"},
+		{"short code", rfc6238Secret, "28708"},
+		{"8-digit code", rfc6238Secret, "94287082"},
+		{"broken secret", "not base32!", "287082"},
+	} {
+		if _, ok := verifyTOTP(tt.secret, tt.code, now, 0); ok {
+			t.Errorf("%s: verifyTOTP accepted %q", tt.name, tt.code)
+		}
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) != totpSecretSize {
+		t.Fatalf("newTOTPSecret = %q (%d bytes, %v), want %d random bytes", secret, len(key), err, totpSecretSize)
+	}
+	code := totpCode(key, time.Now().Unix()/totpPeriod)
+	if _, ok := verifyTOTP(secret, code, time.Now(), 0); !ok {
+		t.Error("the current code of a new secret was rejected")
+	}
+}
+
+func TestSealTOTPSecret(t *testing.T) {
+	t.Setenv("TOTP_ENCRYPTION_KEY", "test-key")
+	sealed, err := sealTOTPSecret(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, rfc6238Secret) {
+		t.Fatal("sealed secret contains the plaintext")
+	}
+	if again, _ := sealTOTPSecret(rfc6238Secret); again == sealed {
+		t.Error("sealing twice produced the same value (nonce reused)")
+	}
+	got, err := openTOTPSecret(sealed)
+	if err != nil || got != rfc6238Secret {
+		t.Fatalf("openTOTPSecret = %q, %v; want %q", got, err, rfc6238Secret)
+	}
+
+	// 改ざん・切り詰めた値は戻さない
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 0x01
+	for name, v := range map[string]string{
+		"tampered":   base64.StdEncoding.EncodeToString(raw),
+		"truncated":  base64.StdEncoding.EncodeToString(raw[:4]),
+		"not base64": "%%%",
+	} {
+		if _, err := openTOTPSecret(v); err != ErrInvalidSecret {
+			t.Errorf("%s: openTOTPSecret error = %v, want ErrInvalidSecret", name, err)
+		}
+	}
+
+	// 別の鍵では開けない
+	t.Setenv("TOTP_ENCRYPTION_KEY", "other-key")
+	if _, err := openTOTPSecret(sealed); err != ErrInvalidSecret {
+		t.Errorf("openTOTPSecret with another key: error = %v, want ErrInvalidSecret", err)
+	}
+
+	t.Setenv("TOTP_ENCRYPTION_KEY", "")
+	if _, err := sealTOTPSecret(rfc6238Secret); err != ErrNoTOTPKey {
+		t.Errorf("sealTOTPSecret without a key: error = %v, want ErrNoTOTPKey", err)
+	}
+}
+
+func TestNewRecoveryCode(t *testing.T) {
+	seen := map[rune]int{}
+	for range 2000 {
+		code, err := newRecoveryCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != recoveryCodeLength+1 || code[recoveryCodeLength/2] != '-' {
+			t.Fatalf("newRecoveryCode = %q, want xxxxx-xxxxx", code)
+		}
+		for _, r := range strings.Replace(code, "-", "", 1) {
+			if !strings.ContainsRune(recoveryCodeAlphabet, r) {
+				t.Fatalf("newRecoveryCode = %q contains %q outside the alphabet", code, r)
+			}
+			seen[r]++
+		}
+	}
+	// 20000 文字で全ての文字が出る（1文字あたりの期待値は約 645）
+	for _, r := range recoveryCodeAlphabet {
+		if seen[r] == 0 {
+			t.Errorf("character %q never appeared", r)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FromUpdatedAt updated_at（PostgREST の ISO 8601 文字列）からバージョン文字列を作る
+// 表記揺れ（タイムゾーンや小数秒の桁数）に左右されないよう時刻として解釈してから符号化する
+func FromUpdatedAt(updatedAt *string) string {
+	if updatedAt == nil || *updatedAt == "" {
+		return ""
+	}
+	t, err := time.Parse(time.RFC3339Nano, *updatedAt)
+	if err != nil {
+		// 解釈できない場合も同じ値からは同じバージョンになるよう文字列のハッシュを使う
+		sum := sha256.Sum256([]byte(*updatedAt))
+		return "h" + hex.EncodeToString(sum[:8])
+	}
+	return "v" + strconv.FormatInt(t.UnixMicro(), 36)
+}
+
+// Header バージョンを ETag ヘッダーの値（強い ETag）にする
+func Header(version string) string {
+	return `"` + version + `"`
+}
+
+// Set ETag ヘッダーを設定する（バージョンが空なら何もしない）
+func Set(c *gin.Context, version string) {
+	if version != "" {
+		c.Header("ETag", Header(version))
+	}
+}
+
+// Expected If-Match ヘッダーまたはボディの version から、クライアントが前提とするバージョンを取り出す
+// どちらも無い場合は ok=false
+func Expected(c *gin.Context, bodyVersion *string) (version string, ok bool) {
+	if h := strings.TrimSpace(c.GetHeader("If-Match")); h != "" {
+		// 複数指定は先頭のみ扱う。弱い ETag の接頭辞は無視する
+		h = strings.TrimSpace(strings.SplitN(h, ",", 2)[0])
+		h = strings.TrimPrefix(h, "W/")
+		return strings.Trim(h, `"`), true
+	}
+	if bodyVersion != nil && strings.TrimSpace(*bodyVersion) != "" {
+		return strings.Trim(strings.TrimSpace(*bodyVersion), `"`), true
+	}
+	return "", false
+}
+
+// Check 更新前の前提条件を確認する
+// 前提が無ければ 428、現在のバージョンと一致しなければ 412 を返して false を返す
+// 更新内容の確認を省けないよう If-Match: * は受け付けない
+// 412 の details には current（現在のサーバー側の値）を載せ、UI のマージ表示に使えるようにする
+func Check(c *gin.Context, bodyVersion *string, currentVersion string, current any) bool {
+	expected, ok := Expected(c, bodyVersion)
+	if !ok {
+		apierror.Respond(c, apierror.CodePreconditionRequired)
+		return false
+	}
+	if expected == currentVersion {
+		return true
+	}
+	Conflict(c, currentVersion, current)
+	return false
+}
+
+// Conflict 他の更新と競合したことを 412 で返す
+func Conflict(c *gin.Context, currentVersion string, current any) {
+	Set(c, currentVersion)
+	apierror.RespondWith(c, apierror.CodePreconditionFailed, "", gin.H{
+		"version": currentVersion,
+		"current": current,
+	})
+}
@@ -0,0 +1,102 @@
+package group
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// GroupDTO group の行
+type GroupDTO struct {
+	Number           int      `json:"number"`
+	Name             string   `json:"name"`
+	MembershipNumber int      `json:"membership_number"`
+	Initial          *string  `json:"initial"`
+	Digits           *int     `json:"digits"`
+	ExpensesRatio    *float64 `json:"expenses_ratio"`
+	IsDiscountUse    bool     `json:"is_discount_use"`
+	DiscountInterval *int     `json:"discount_interval"`
+	DiscountPrice    *int     `json:"discount_price"`
+	BelongsShop      *string  `json:"belongs_shop"`
+	CreatedAt        *string  `json:"created_at,omitempty"`
+	UpdatedAt        *string  `json:"updated_at,omitempty"`
+}
+
+// Groups グループナンバー順のグループ一覧（件数が少ないため検索は線形で行う）
+type Groups []GroupDTO
+
+// ByNumber グループナンバーが一致するグループ
+func (gs Groups) ByNumber(n int) (GroupDTO, bool) {
+	for _, g := range gs {
+		if g.Number == n {
+			return g, true
+		}
+	}
+	return GroupDTO{}, false
+}
+
+// Match グループナンバー・グループ名のいずれかと一致するグループ（CSV 取り込みなどの表記用。全角半角・大文字小文字は区別しない）
+// 部分一致はしない
+func (gs Groups) Match(label string) (GroupDTO, bool) {
+	v := normalizeLabel(label)
+	if n, err := strconv.Atoi(v); err == nil {
+		return gs.ByNumber(n)
+	}
+	for _, g := range gs {
+		if v == normalizeLabel(g.Name) {
+			return g, true
+		}
+	}
+	return GroupDTO{}, false
+}
+
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.TrimSpace(norm.NFKC.String(s)))
+}
+
+// GroupResponse グループマスタの API の形
+type GroupResponse struct {
+	Number           int      `json:"number"`
+	Name             string   `json:"name"`
+	MembershipNumber int      `json:"membershipNumber"`
+	Initial          *string  `json:"initial"`
+	Digits           *int     `json:"digits"`
+	ExpensesRatio    *float64 `json:"expensesRatio"`
+	IsDiscountUse    bool     `json:"isDiscountUse"`
+	DiscountInterval *int     `json:"discountInterval"`
+	DiscountPrice    *int     `json:"discountPrice"`
+	BelongsShop      *string  `json:"belongsShop"`
+	CreatedAt        string   `json:"createdAt,omitempty"`
+	UpdatedAt        string   `json:"updatedAt,omitempty"`
+	Version          string   `json:"version"` // 楽観的排他制御用（ETag と同じ値）
+}
+
+// CreateGroupRequest グループの追加（number 省略時は最大のナンバーの次を採番）
+type CreateGroupRequest struct {
+	Number           *int     `json:"number" binding:"omitempty,min=1,max=99999999"`
+	Name             string   `json:"name" binding:"required,max=100"`
+	MembershipNumber *int     `json:"membershipNumber" binding:"omitempty,min=0"`
+	Initial          *string  `json:"initial" binding:"omitempty,max=1"`
+	Digits           *int     `json:"digits" binding:"omitempty,min=1,max=10"`
+	ExpensesRatio    *float64 `json:"expensesRatio" binding:"omitempty,min=0,max=100"`
+	IsDiscountUse    *bool    `json:"isDiscountUse"`
+	DiscountInterval *int     `json:"discountInterval" binding:"omitempty,min=1"`
+	DiscountPrice    *int     `json:"discountPrice" binding:"omitempty,min=0"`
+	BelongsShop      *string  `json:"belongsShop" binding:"omitempty,eq=|uuid"`
+}
+
+// UpdateGroupRequest グループの部分更新（指定した項目のみ。グループナンバーは変更できない）
+type UpdateGroupRequest struct {
+	Name             *string  `json:"name" binding:"omitempty,max=100"`
+	MembershipNumber *int     `json:"membershipNumber" binding:"omitempty,min=0"`
+	Initial          *string  `json:"initial" binding:"omitempty,max=1"` // 空文字で未設定に戻す
+	Digits           *int     `json:"digits" binding:"omitempty,min=1,max=10"`
+	ExpensesRatio    *float64 `json:"expensesRatio" binding:"omitempty,min=0,max=100"`
+	IsDiscountUse    *bool    `json:"isDiscountUse"`
+	DiscountInterval *int     `json:"discountInterval" binding:"omitempty,min=1"`
+	DiscountPrice    *int     `json:"discountPrice" binding:"omitempty,min=0"`
+	BelongsShop      *string  `json:"belongsShop" binding:"omitempty,eq=|uuid"` // 空文字で未設定に戻す
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
+}
@@ -0,0 +1,449 @@
+package group
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// groupSelect グループマスタで取得する列
+const groupSelect = "number,name,membership_number,initial,digits,expenses_ratio," +
+	"is_discount_use,discount_interval,discount_price,belongs_shop,created_at,updated_at"
+
+// List グループをグループナンバー順にすべて取得する。失敗時はエラーコードを返す（ログ出力済み）
+func List(ctx context.Context, client *supa.Client) (Groups, string) {
+	q := url.Values{}
+	q.Set("select", groupSelect)
+	q.Set("order", "number.asc")
+	body, _, getErr := client.Get(ctx, "/rest/v1/group", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get group error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows Groups
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (group): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	return rows, ""
+}
+
+// GetGroupListHandler グループの一覧（GET /api/groups。グループナンバー順）
+func GetGroupListHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	rows, code := List(ctx, client)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	out := make([]GroupResponse, 0, len(rows))
+	for _, g := range rows {
+		out = append(out, toResponse(g))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetGroupDetailHandler グループ1件（GET /api/groups/:number）
+func GetGroupDetailHandler(c *gin.Context) {
+	number, ok := numberParam(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	g, found, code := fetchGroup(ctx, client, number)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := toResponse(g)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateGroupHandler グループを追加する（POST /api/groups）
+// グループナンバーが既に使われている場合は 409
+func CreateGroupHandler(c *gin.Context) {
+	var req CreateGroupRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if len(fieldErrs) == 0 && strings.TrimSpace(req.Name) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "name", "required", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	if fieldErrs, code := validateBelongsShop(ctx, client, lang, req.BelongsShop); code != "" || len(fieldErrs) > 0 {
+		if code != "" {
+			apierror.Respond(c, code)
+		} else {
+			apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		}
+		return
+	}
+
+	row := buildGroupPatch(&UpdateGroupRequest{
+		Name:             &req.Name,
+		MembershipNumber: req.MembershipNumber,
+		Initial:          req.Initial,
+		Digits:           req.Digits,
+		ExpensesRatio:    req.ExpensesRatio,
+		IsDiscountUse:    req.IsDiscountUse,
+		DiscountInterval: req.DiscountInterval,
+		DiscountPrice:    req.DiscountPrice,
+		BelongsShop:      req.BelongsShop,
+	})
+	if req.Number != nil {
+		row["number"] = *req.Number
+	} else {
+		rows, code := List(ctx, client)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		next := 1
+		if len(rows) > 0 {
+			next = rows[len(rows)-1].Number + 1
+		}
+		row["number"] = next
+	}
+
+	q := url.Values{}
+	q.Set("select", groupSelect)
+	respBody, status, postErr := client.Post(ctx, "/rest/v1/group", q, row)
+	if postErr != nil {
+		if status == http.StatusConflict {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", []apierror.FieldError{
+				validate.NewFieldError(lang, "number", "unique", ""),
+			})
+			return
+		}
+		log.Printf("DB_003: supabase insert group error: %v", postErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var created []GroupDTO
+	if err := json.Unmarshal(respBody, &created); err != nil || len(created) == 0 {
+		log.Printf("DB_002: json decode error (group insert): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	resp := toResponse(created[0])
+	etag.Set(c, resp.Version)
+	c.Header("Location", "/api/groups/"+strconv.Itoa(resp.Number))
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateGroupHandler グループを部分更新する（PATCH /api/groups/:number）
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+func UpdateGroupHandler(c *gin.Context) {
+	number, ok := numberParam(c)
+	if !ok {
+		return
+	}
+	var req UpdateGroupRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "name", "required", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchGroup(ctx, client, number)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, toResponse(current)) {
+		return
+	}
+	if fieldErrs, code := validateBelongsShop(ctx, client, lang, req.BelongsShop); code != "" || len(fieldErrs) > 0 {
+		if code != "" {
+			apierror.Respond(c, code)
+		} else {
+			apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		}
+		return
+	}
+
+	patch := buildGroupPatch(&req)
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("number", "eq."+strconv.Itoa(number))
+	q.Set("updated_at", "eq."+deref(current.UpdatedAt))
+	q.Set("select", groupSelect)
+	respBody, _, patchErr := client.Patch(ctx, "/rest/v1/group", q, patch)
+	if patchErr != nil {
+		log.Printf("DB_003: supabase patch group error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var updated []GroupDTO
+	if err := json.Unmarshal(respBody, &updated); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(updated) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchGroup(ctx, client, number); code == "" && found {
+			resp := toResponse(latest)
+			etag.Conflict(c, resp.Version, resp)
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+	resp := toResponse(updated[0])
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteGroupHandler グループを削除する（DELETE /api/groups/:number）
+// スタッフ・店舗が所属しているグループは削除できない（409）
+func DeleteGroupHandler(c *gin.Context) {
+	number, ok := numberParam(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	if _, found, code := fetchGroup(ctx, client, number); code != "" || !found {
+		if code == "" {
+			code = apierror.CodeNotFound
+		}
+		apierror.Respond(c, code)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("number", "eq."+strconv.Itoa(number))
+	if _, status, delErr := client.Delete(ctx, "/rest/v1/group", q); delErr != nil {
+		// staff.group_no / shop.group_no の外部キー（on delete restrict）に反する場合
+		if status == http.StatusConflict {
+			apierror.Respond(c, apierror.CodeInUse)
+			return
+		}
+		log.Printf("DB_003: supabase delete group error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// numberParam パスのグループナンバー。数字でなければ応答済みで ok=false
+func numberParam(c *gin.Context) (int, bool) {
+	raw := strings.TrimSpace(c.Param("number"))
+	if raw == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return 0, false
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "number", "min", "1"),
+		})
+		return 0, false
+	}
+	return n, true
+}
+
+// fetchGroup グループ1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchGroup(ctx context.Context, client *supa.Client, number int) (GroupDTO, bool, string) {
+	q := url.Values{}
+	q.Set("select", groupSelect)
+	q.Set("number", "eq."+strconv.Itoa(number))
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/group", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get group error: %v", getErr)
+		return GroupDTO{}, false, apierror.CodeDBInit
+	}
+	var rows []GroupDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (group): %v", err)
+		return GroupDTO{}, false, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return GroupDTO{}, false, ""
+	}
+	return rows[0], true, ""
+}
+
+// validateBelongsShop 所属店舗が店舗マスタにあることを確認する（nil・空文字は確認しない）
+func validateBelongsShop(ctx context.Context, client *supa.Client, lang string, shopID *string) ([]apierror.FieldError, string) {
+	if shopID == nil || strings.TrimSpace(*shopID) == "" {
+		return nil, ""
+	}
+	q := url.Values{}
+	q.Set("select", "id")
+	q.Set("id", "eq."+strings.TrimSpace(*shopID))
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/shop", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get shop error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (shop): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return []apierror.FieldError{validate.NewFieldError(lang, "belongsShop", "exists", "")}, ""
+	}
+	return nil, ""
+}
+
+// buildGroupPatch リクエストで指定された項目を group テーブルの列に変換する（追加・更新で共用）
+func buildGroupPatch(req *UpdateGroupRequest) map[string]any {
+	patch := map[string]any{}
+	if req.Name != nil {
+		patch["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.MembershipNumber != nil {
+		patch["membership_number"] = *req.MembershipNumber
+	}
+	if req.Initial != nil {
+		patch["initial"] = emptyToNil(*req.Initial)
+	}
+	if req.Digits != nil {
+		patch["digits"] = *req.Digits
+	}
+	if req.ExpensesRatio != nil {
+		patch["expenses_ratio"] = *req.ExpensesRatio
+	}
+	if req.IsDiscountUse != nil {
+		patch["is_discount_use"] = *req.IsDiscountUse
+	}
+	if req.DiscountInterval != nil {
+		patch["discount_interval"] = *req.DiscountInterval
+	}
+	if req.DiscountPrice != nil {
+		patch["discount_price"] = *req.DiscountPrice
+	}
+	if req.BelongsShop != nil {
+		patch["belongs_shop"] = emptyToNil(*req.BelongsShop)
+	}
+	return patch
+}
+
+func emptyToNil(s string) any {
+	if v := strings.TrimSpace(s); v != "" {
+		return v
+	}
+	return nil
+}
+
+func toResponse(g GroupDTO) GroupResponse {
+	return GroupResponse{
+		Number:           g.Number,
+		Name:             g.Name,
+		MembershipNumber: g.MembershipNumber,
+		Initial:          g.Initial,
+		Digits:           g.Digits,
+		ExpensesRatio:    g.ExpensesRatio,
+		IsDiscountUse:    g.IsDiscountUse,
+		DiscountInterval: g.DiscountInterval,
+		DiscountPrice:    g.DiscountPrice,
+		BelongsShop:      g.BelongsShop,
+		CreatedAt:        deref(g.CreatedAt),
+		UpdatedAt:        deref(g.UpdatedAt),
+		Version:          etag.FromUpdatedAt(g.UpdatedAt),
+	}
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
@@ -0,0 +1,181 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	auth "nissyo/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	KeyHeader      = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxBodyBytes 再送の照合のために読み込む本文の上限（CSV 取り込みの 5MB とフォームの付随部分が収まる大きさ）
+const maxBodyBytes = 6 << 20
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{8,128}$`)
+
+// 再送時に復元する応答ヘッダー
+var replayHeaders = []string{"Content-Type", "Content-Language", "Location", "ETag"}
+
+// responseRecorder ハンドラーの応答本文を保存用に複製する
+type responseRecorder struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Middleware 更新系リクエスト（POST / PUT / PATCH / DELETE）で Idempotency-Key を扱う
+//
+// 最初の応答を (利用者, キー) 単位で保存し、同じキー・同じ内容の再送にはその応答をそのまま返す。
+// 同じキーで内容が異なる場合は 422、同じキーの同時リクエストは最初の処理が終わるまで待たせる。
+// 本文は maxBodyBytes まで読み込み、超える場合は 413 を返す。
+// ヘッダーが無いリクエストは従来どおり処理する。
+func Middleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+		key := c.GetHeader(KeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !keyPattern.MatchString(key) {
+			apierror.Respond(c, apierror.CodeIdempotencyKeyInvalid)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Respond(c, apierror.CodeIdempotencyTooLarge)
+				return
+			}
+			apierror.Respond(c, apierror.CodeValidation)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		storeKey := callerScope(c) + "\x00" + key
+		hash := requestHash(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		for {
+			rec, owner := store.acquire(storeKey, hash)
+			if owner {
+				serve(c, store, storeKey, rec)
+				return
+			}
+			if rec.requestHash != hash {
+				apierror.Respond(c, apierror.CodeIdempotencyMismatch)
+				return
+			}
+			// 同じキーの先行リクエストが終わるまで待つ
+			select {
+			case <-rec.done:
+			case <-c.Request.Context().Done():
+				apierror.Respond(c, apierror.CodeIdempotencyInFlight)
+				return
+			}
+			if rec.completed {
+				replay(c, rec)
+				return
+			}
+			// 先行リクエストが保存対象外で終わった場合は改めて処理権を取りに行く
+		}
+	}
+}
+
+func serve(c *gin.Context, store *Store, storeKey string, rec *record) {
+	w := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = w
+	defer func() {
+		if p := recover(); p != nil {
+			store.release(storeKey, rec)
+			panic(p)
+		}
+	}()
+
+	c.Next()
+
+	status := w.Status()
+	if status >= http.StatusInternalServerError {
+		// サーバー側の失敗は再試行で再実行できるよう保存しない
+		store.release(storeKey, rec)
+		return
+	}
+	if strings.Contains(w.Header().Get("Cache-Control"), "no-store") {
+		// 連絡先の表示など保持してはいけない応答は保存しない（再送時も権限確認と記録をやり直す）
+		store.release(storeKey, rec)
+		return
+	}
+	header := http.Header{}
+	for _, h := range replayHeaders {
+		if v := w.Header().Get(h); v != "" {
+			header.Set(h, v)
+		}
+	}
+	store.complete(rec, status, header, bytes.Clone(w.buf.Bytes()))
+}
+
+func replay(c *gin.Context, rec *record) {
+	for h, vs := range rec.header {
+		for _, v := range vs {
+			c.Writer.Header().Set(h, v)
+		}
+	}
+	c.Header(ReplayedHeader, "true")
+	c.Status(rec.status)
+	if _, err := c.Writer.Write(rec.body); err != nil {
+		log.Printf("IDEM: replay write error: %v", err)
+	}
+	c.Abort()
+}
+
+// callerScope キーの衝突範囲を利用者ごとに分ける
+// 認証済みなら利用者ID、それ以外は認証情報のハッシュ、無ければ接続元IPを使う
+func callerScope(c *gin.Context) string {
+	if p, ok := auth.Current(c); ok {
+		return "user:" + p.Subject
+	}
+	if h := c.GetHeader("Authorization"); h != "" {
+		sum := sha256.Sum256([]byte(h))
+		return "auth:" + hex.EncodeToString(sum[:])
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func requestHash(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(uri))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
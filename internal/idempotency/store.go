@@ -0,0 +1,97 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultTTL 保存した応答を再送に使う期間
+const DefaultTTL = 24 * time.Hour
+
+// record 1つの (利用者, キー) に対する処理状態と保存済みの応答
+type record struct {
+	requestHash string
+	done        chan struct{} // 最初のリクエストの処理完了で close される
+	completed   bool          // 応答が保存済みか（false のまま done なら保存対象外で終わった）
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// Store 応答をプロセス内メモリに保持する
+// 複数インスタンスで動かす場合は共有ストアへの置き換えが必要
+type Store struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	records map[string]*record
+}
+
+// NewStore ttl<=0 の場合は DefaultTTL を使う。期限切れの掃除は ctx が終わるまで定期的に行う
+func NewStore(ctx context.Context, ttl time.Duration) *Store {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	s := &Store{ttl: ttl, records: map[string]*record{}}
+	go s.janitor(ctx)
+	return s
+}
+
+func (s *Store) janitor(ctx context.Context) {
+	t := time.NewTicker(10 * time.Minute)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			s.mu.Lock()
+			for k, r := range s.records {
+				if r.completed && now.After(r.expiresAt) {
+					delete(s.records, k)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// acquire キーの処理権を取得する
+// 既に処理中・処理済みの記録があればそれを返し（owner=false）、なければ新規に登録する（owner=true）
+func (s *Store) acquire(key, hash string) (rec *record, owner bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok {
+		if !r.completed || time.Now().Before(r.expiresAt) {
+			return r, false
+		}
+		delete(s.records, key)
+	}
+	r := &record{requestHash: hash, done: make(chan struct{})}
+	s.records[key] = r
+	return r, true
+}
+
+// complete 応答を保存して待機中のリクエストを解放する
+func (s *Store) complete(r *record, status int, header http.Header, body []byte) {
+	s.mu.Lock()
+	r.completed = true
+	r.status = status
+	r.header = header
+	r.body = body
+	r.expiresAt = time.Now().Add(s.ttl)
+	s.mu.Unlock()
+	close(r.done)
+}
+
+// release 応答を保存せずに記録を破棄する（サーバーエラー時など、再試行で再実行させたい場合）
+func (s *Store) release(key string, r *record) {
+	s.mu.Lock()
+	if s.records[key] == r {
+		delete(s.records, key)
+	}
+	s.mu.Unlock()
+	close(r.done)
+}
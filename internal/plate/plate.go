@@ -0,0 +1,239 @@
+package plate
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Plate 自動車登録番号（ナンバープレート）
+// 例: 京都 500 あ 12-34（地名・分類番号・ひらがな・一連指定番号）
+type Plate struct {
+	Region string // 地名（京都・なにわ など）
+	Class  string // 分類番号（1〜3桁。2桁目以降は英字を含むことがある）
+	Kana   string // ひらがな1文字
+	Serial int    // 一連指定番号（1〜9999）
+}
+
+// classPattern 分類番号（先頭は 1〜9、2桁目以降は数字または英字 A C F H K L M P X Y）
+var classPattern = regexp.MustCompile(`^[1-9][0-9ACFHKLMPXY]{0,2}$`)
+
+// kanas ナンバーに使われるひらがな（お・し・へ・ん は使われない）
+const kanas = "あいうえかきくけこさすせそたちつてとなにぬねのはひふほまみむめもやゆよらりるれろわを"
+
+// regionMaxLen 地名の最大文字数（尾張小牧 など）
+const regionMaxLen = 4
+
+// serialPlaceholders 一連指定番号の桁を埋める記号（「・・12」の「・」。先頭の 0 も同じ扱い）
+const serialPlaceholders = "・.0"
+
+// serialChars 一連指定番号の表記に現れる文字
+const serialChars = "0123456789・.-‐−ー"
+
+// NormalizeRegion 地名の表記を揃える（全角半角・空白）
+func NormalizeRegion(s string) string {
+	return removeSpaces(norm.NFKC.String(s))
+}
+
+// NormalizeClass 分類番号の表記を揃える（全角半角・英字は大文字）
+func NormalizeClass(s string) string {
+	return strings.ToUpper(removeSpaces(norm.NFKC.String(s)))
+}
+
+// NormalizeKana ひらがなの表記を揃える（カタカナはひらがなにする）
+func NormalizeKana(s string) string {
+	s = removeSpaces(norm.NFKC.String(s))
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - ('ァ' - 'ぁ')
+		}
+		return r
+	}, s)
+}
+
+// ValidRegion 地名として使える値か（漢字・かなの1〜4文字）
+func ValidRegion(s string) bool {
+	n := utf8.RuneCountInString(s)
+	if n == 0 || n > regionMaxLen {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.Is(unicode.Han, r) && !unicode.Is(unicode.Hiragana, r) && !unicode.Is(unicode.Katakana, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidClass 分類番号として使える値か
+func ValidClass(s string) bool {
+	return classPattern.MatchString(s)
+}
+
+// ValidKana ナンバーに使えるひらがな1文字か
+func ValidKana(s string) bool {
+	return utf8.RuneCountInString(s) == 1 && strings.Contains(kanas, s)
+}
+
+// ValidSerial 一連指定番号として使える値か
+func ValidSerial(n int) bool {
+	return n >= 1 && n <= 9999
+}
+
+// ParseSerial 一連指定番号を読む（「12-34」「1234」「・・12」「0012」など）
+func ParseSerial(s string) (int, bool) {
+	s = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("-‐−ー", r) {
+			return -1
+		}
+		return r
+	}, removeSpaces(norm.NFKC.String(s)))
+	if s == "" || utf8.RuneCountInString(s) > 4 {
+		return 0, false
+	}
+	digits := strings.TrimLeft(s, serialPlaceholders)
+	if digits == "" {
+		return 0, false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(digits)
+	if err != nil || !ValidSerial(n) {
+		return 0, false
+	}
+	return n, true
+}
+
+// FormatSerial 一連指定番号の表示（4桁は「12-34」、3桁以下は「・」で埋めて「・・12」）
+func FormatSerial(n int) string {
+	d := strconv.Itoa(n)
+	if len(d) >= 4 {
+		return d[:len(d)-2] + "-" + d[len(d)-2:]
+	}
+	return strings.Repeat("・", 4-len(d)) + d
+}
+
+// Parse ナンバーの表記を読む（例: 「京都 500 あ 12-34」「京都500あ1234」「京都 33 さ ・・12」）
+// 区切りの空白は無くてもよい。全角半角・カタカナは揃えてから確かめる
+func Parse(s string) (Plate, bool) {
+	s = removeSpaces(norm.NFKC.String(s))
+	// 地名は最初の数字まで、分類番号はそこから英数字の続く間、その次の1文字がひらがな、残りが一連指定番号
+	i := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' })
+	if i <= 0 {
+		return Plate{}, false
+	}
+	region, rest := s[:i], s[i:]
+	j := strings.IndexFunc(rest, func(r rune) bool { return r > unicode.MaxASCII })
+	if j <= 0 {
+		return Plate{}, false
+	}
+	class, rest := rest[:j], rest[j:]
+	kana, size := utf8.DecodeRuneInString(rest)
+	p := Plate{
+		Region: NormalizeRegion(region),
+		Class:  NormalizeClass(class),
+		Kana:   NormalizeKana(string(kana)),
+	}
+	serial, ok := ParseSerial(rest[size:])
+	if !ok || !ValidRegion(p.Region) || !ValidClass(p.Class) || !ValidKana(p.Kana) {
+		return Plate{}, false
+	}
+	p.Serial = serial
+	return p, true
+}
+
+// ParseQuery 検索語からナンバーの一部を読む（含まれない部分はゼロ値）
+// 「京都 500 あ 12-34」のような完全な表記のほか「12-34」「あ 1234」「京都」「京都 500」を受け付ける
+// 数字だけの検索語は一連指定番号として扱う
+func ParseQuery(s string) Plate {
+	if p, ok := Parse(s); ok {
+		return p
+	}
+	s = removeSpaces(norm.NFKC.String(s))
+	var p Plate
+	// 末尾の一連指定番号（ひらがな・カタカナの後ろ、または検索語全体）
+	end := len(s)
+	for end > 0 {
+		r, size := utf8.DecodeLastRuneInString(s[:end])
+		if !strings.ContainsRune(serialChars, r) {
+			break
+		}
+		end -= size
+	}
+	if head := s[:end]; end < len(s) && (head == "" || lastRuneIs(head, unicode.Hiragana) || lastRuneIs(head, unicode.Katakana)) {
+		if n, ok := ParseSerial(s[end:]); ok {
+			p.Serial = n
+			s = head
+		}
+	}
+	// その前のひらがな1文字（分類番号・漢字の地名の後ろ、または単独）
+	if r, size := utf8.DecodeLastRuneInString(s); size > 0 {
+		head := s[:len(s)-size]
+		if k := NormalizeKana(string(r)); ValidKana(k) && (head == "" || endsWithClass(head) || lastRuneIs(head, unicode.Han)) {
+			p.Kana = k
+			s = head
+		}
+	}
+	// 分類番号と地名
+	if i := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' }); i >= 0 {
+		if c := NormalizeClass(s[i:]); ValidClass(c) {
+			p.Class = c
+			s = s[:i]
+		}
+	}
+	if r := NormalizeRegion(s); ValidRegion(r) {
+		p.Region = r
+	}
+	return p
+}
+
+// String ナンバーの表示（例: 京都 500 あ 12-34。未設定の部分は省く）
+func (p Plate) String() string {
+	var parts []string
+	for _, v := range []string{p.Region, p.Class, p.Kana} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if p.Serial > 0 {
+		parts = append(parts, FormatSerial(p.Serial))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Complete 地名・ひらがな・一連指定番号がそろっているか（重複の判定はそろったナンバーのみ行う）
+// 分類番号は登録していない古い車両があるため問わない
+func (p Plate) Complete() bool {
+	return p.Region != "" && p.Kana != "" && p.Serial > 0
+}
+
+// IsZero いずれの部分も無いか
+func (p Plate) IsZero() bool {
+	return p == Plate{}
+}
+
+func endsWithClass(s string) bool {
+	i := strings.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' })
+	return i >= 0 && ValidClass(NormalizeClass(s[i:]))
+}
+
+func lastRuneIs(s string, t *unicode.RangeTable) bool {
+	r, size := utf8.DecodeLastRuneInString(s)
+	return size > 0 && unicode.Is(t, r)
+}
+
+func removeSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
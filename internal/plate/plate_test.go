@@ -0,0 +1,190 @@
+package plate
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Plate
+		ok   bool
+	}{
+		{"京都 500 あ 12-34", Plate{Region: "京都", Class: "500", Kana: "あ", Serial: 1234}, true},
+		{"京都500あ1234", Plate{Region: "京都", Class: "500", Kana: "あ", Serial: 1234}, true},
+		{"京都 33 さ ・・12", Plate{Region: "京都", Class: "33", Kana: "さ", Serial: 12}, true},
+		{"京都 500 あ 0012", Plate{Region: "京都", Class: "500", Kana: "あ", Serial: 12}, true},
+		{"京都　５００　ア　１２－３４", Plate{Region: "京都", Class: "500", Kana: "あ", Serial: 1234}, true},
+		{"品川 3a0 さ 1", Plate{Region: "品川", Class: "3A0", Kana: "さ", Serial: 1}, true},
+		{"尾張小牧 100 た 9999", Plate{Region: "尾張小牧", Class: "100", Kana: "た", Serial: 9999}, true},
+
+		{"", Plate{}, false},
+		{"京都", Plate{}, false},
+		{"500 あ 12-34", Plate{}, false},
+		{"京都 500 あ", Plate{}, false},
+		{"京都 500 お 12-34", Plate{}, false},
+		{"京都 050 あ 12-34", Plate{}, false},
+		{"京都 5B あ 12-34", Plate{}, false},
+		{"京都 500 あ 0", Plate{}, false},
+		{"京都 500 あ 12345", Plate{}, false},
+		{"Kyoto 500 あ 12-34", Plate{}, false},
+		{"尾張小牧北 500 あ 12-34", Plate{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := Parse(tt.in)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("Parse(%q) = %+v, %v; want %+v, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseSerial(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"12-34", 1234, true},
+		{"1234", 1234, true},
+		{"１２－３４", 1234, true},
+		{"・・12", 12, true},
+		{"..12", 12, true},
+		{"0012", 12, true},
+		{"・・・1", 1, true},
+
+		{"", 0, false},
+		{"0000", 0, false},
+		{"・・", 0, false},
+		{"12345", 0, false},
+		{"12a4", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := ParseSerial(tt.in)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("ParseSerial(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestFormatSerial(t *testing.T) {
+	tests := []struct {
+		in   int
+		want string
+	}{
+		{1234, "12-34"},
+		{100, "・100"},
+		{12, "・・12"},
+		{1, "・・・1"},
+	}
+	for _, tt := range tests {
+		if got := FormatSerial(tt.in); got != tt.want {
+			t.Errorf("FormatSerial(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+		if n, ok := ParseSerial(tt.want); !ok || n != tt.in {
+			t.Errorf("ParseSerial(%q) = %d, %v; want %d", tt.want, n, ok, tt.in)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Plate
+	}{
+		{"京都 500 あ 12-34", Plate{Region: "京都", Class: "500", Kana: "あ", Serial: 1234}},
+		{"12-34", Plate{Serial: 1234}},
+		{"1234", Plate{Serial: 1234}},
+		{"500", Plate{Serial: 500}},
+		{"あ 1234", Plate{Kana: "あ", Serial: 1234}},
+		{"ア１２－３４", Plate{Kana: "あ", Serial: 1234}},
+		{"さ", Plate{Kana: "さ"}},
+		{"京都", Plate{Region: "京都"}},
+		{"なにわ", Plate{Region: "なにわ"}},
+		{"京都 500", Plate{Region: "京都", Class: "500"}},
+		{"京都 500 あ", Plate{Region: "京都", Class: "500", Kana: "あ"}},
+		{"京都 あ 12-34", Plate{Region: "京都", Kana: "あ", Serial: 1234}},
+		{"", Plate{}},
+		{"Kyoto", Plate{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := ParseQuery(tt.in); got != tt.want {
+				t.Errorf("ParseQuery(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want bool
+	}{
+		{"あ", true}, {"わ", true}, {"お", false}, {"ん", false}, {"ア", false}, {"あい", false}, {"", false},
+	} {
+		if got := ValidKana(tt.in); got != tt.want {
+			t.Errorf("ValidKana(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		in   string
+		want bool
+	}{
+		{"5", true}, {"33", true}, {"500", true}, {"3A0", true}, {"05", false}, {"5000", false}, {"5B", false}, {"", false},
+	} {
+		if got := ValidClass(tt.in); got != tt.want {
+			t.Errorf("ValidClass(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		in   string
+		want bool
+	}{
+		{"京都", true}, {"なにわ", true}, {"尾張小牧", true}, {"尾張小牧北", false}, {"Kyoto", false}, {"", false},
+	} {
+		if got := ValidRegion(tt.in); got != tt.want {
+			t.Errorf("ValidRegion(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := NormalizeRegion("京 都"); got != "京都" {
+		t.Errorf("NormalizeRegion = %q, want 京都", got)
+	}
+	if got := NormalizeClass("３ａ０"); got != "3A0" {
+		t.Errorf("NormalizeClass = %q, want 3A0", got)
+	}
+	for _, in := range []string{"ア", "ｱ", " あ "} {
+		if got := NormalizeKana(in); got != "あ" {
+			t.Errorf("NormalizeKana(%q) = %q, want あ", in, got)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in       Plate
+		want     string
+		complete bool
+	}{
+		{Plate{Region: "京都", Class: "500", Kana: "あ", Serial: 1234}, "京都 500 あ 12-34", true},
+		{Plate{Region: "京都", Kana: "さ", Serial: 12}, "京都 さ ・・12", true},
+		{Plate{Region: "京都", Class: "500"}, "京都 500", false},
+		{Plate{Serial: 12}, "・・12", false},
+		{Plate{}, "", false},
+	}
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.in, got, tt.want)
+		}
+		if got := tt.in.Complete(); got != tt.complete {
+			t.Errorf("%+v.Complete() = %v, want %v", tt.in, got, tt.complete)
+		}
+		if got := tt.in.IsZero(); got != (tt.in == Plate{}) {
+			t.Errorf("%+v.IsZero() = %v", tt.in, got)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package position
+
+import (
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultKey 役職が未設定のスタッフを扱う役職キー（削除・キーの変更はできない）
+const DefaultKey = "office_staff"
+
+// PositionDTO position の行
+type PositionDTO struct {
+	ID          string   `json:"id"`
+	Key         string   `json:"key"`
+	NameJA      string   `json:"name_ja"`
+	NameEN      string   `json:"name_en"`
+	Rank        int      `json:"rank"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   *string  `json:"created_at,omitempty"`
+	UpdatedAt   *string  `json:"updated_at,omitempty"`
+}
+
+// Positions 序列順の役職一覧（件数が少ないため検索は線形で行う）
+type Positions []PositionDTO
+
+// ByKey キーが一致する役職
+func (ps Positions) ByKey(key string) (PositionDTO, bool) {
+	for _, p := range ps {
+		if p.Key == key {
+			return p, true
+		}
+	}
+	return PositionDTO{}, false
+}
+
+// ByID id が一致する役職
+func (ps Positions) ByID(id string) (PositionDTO, bool) {
+	for _, p := range ps {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return PositionDTO{}, false
+}
+
+// Match キー・日本語名・英語名のいずれかと一致する役職（CSV 取り込みなどの表記用。全角半角・大文字小文字は区別しない）
+// 部分一致はしない
+func (ps Positions) Match(label string) (PositionDTO, bool) {
+	v := normalizeLabel(label)
+	for _, p := range ps {
+		if v == p.Key || v == normalizeLabel(p.NameJA) || v == normalizeLabel(p.NameEN) {
+			return p, true
+		}
+	}
+	return PositionDTO{}, false
+}
+
+// Keys キーの一覧（検証エラーの選択肢に使う）
+func (ps Positions) Keys() []string {
+	keys := make([]string, 0, len(ps))
+	for _, p := range ps {
+		keys = append(keys, p.Key)
+	}
+	return keys
+}
+
+// NamesJA 日本語名の一覧
+func (ps Positions) NamesJA() []string {
+	names := make([]string, 0, len(ps))
+	for _, p := range ps {
+		names = append(names, p.NameJA)
+	}
+	return names
+}
+
+func normalizeLabel(s string) string {
+	return strings.ToLower(strings.TrimSpace(norm.NFKC.String(s)))
+}
+
+// Name 言語に応じた役職名
+func (p PositionDTO) Name(lang string) string {
+	if lang == apierror.LangEN {
+		return p.NameEN
+	}
+	return p.NameJA
+}
+
+// PositionResponse 役職マスタの API の形
+type PositionResponse struct {
+	ID          string   `json:"id"`
+	Key         string   `json:"key"`
+	Name        string   `json:"name"` // Accept-Language に応じた役職名
+	NameJA      string   `json:"nameJa"`
+	NameEN      string   `json:"nameEn"`
+	Rank        int      `json:"rank"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"createdAt,omitempty"`
+	UpdatedAt   string   `json:"updatedAt,omitempty"`
+	Version     string   `json:"version"` // 楽観的排他制御用（ETag と同じ値）
+}
+
+// CreatePositionRequest 役職の追加（rank 省略時は最後に追加）
+type CreatePositionRequest struct {
+	Key         string   `json:"key" binding:"required,master_key"`
+	NameJA      string   `json:"nameJa" binding:"required,max=50"`
+	NameEN      string   `json:"nameEn" binding:"required,max=50"`
+	Rank        *int     `json:"rank" binding:"omitempty,min=0,max=100000"`
+	Permissions []string `json:"permissions" binding:"omitempty,max=50,unique,dive,master_key"`
+}
+
+// UpdatePositionRequest 役職の部分更新（指定した項目のみ）
+type UpdatePositionRequest struct {
+	Key         *string   `json:"key" binding:"omitempty,master_key"`
+	NameJA      *string   `json:"nameJa" binding:"omitempty,max=50"`
+	NameEN      *string   `json:"nameEn" binding:"omitempty,max=50"`
+	Rank        *int      `json:"rank" binding:"omitempty,min=0,max=100000"`
+	Permissions *[]string `json:"permissions" binding:"omitempty,max=50,unique,dive,master_key"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
+}
@@ -0,0 +1,398 @@
+package position
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// positionSelect 役職マスタで取得する列
+const positionSelect = "id,key,name_ja,name_en,rank,permissions,created_at,updated_at"
+
+// rank 省略時に最後の役職から空ける間隔（間に役職を追加できるようにする）
+const rankStep = 10
+
+// List 役職を序列順にすべて取得する。失敗時はエラーコードを返す（ログ出力済み）
+func List(ctx context.Context, client *supa.Client) (Positions, string) {
+	q := url.Values{}
+	q.Set("select", positionSelect)
+	q.Set("order", "rank.asc,key.asc")
+	body, _, getErr := client.Get(ctx, "/rest/v1/position", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get position error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows Positions
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (position): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	return rows, ""
+}
+
+// GetPositionListHandler 役職の一覧（GET /api/positions。序列順）
+func GetPositionListHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	rows, code := List(ctx, client)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	lang := apierror.Lang(c)
+	out := make([]PositionResponse, 0, len(rows))
+	for _, p := range rows {
+		out = append(out, toResponse(p, lang))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetPositionDetailHandler 役職1件（GET /api/positions/:id）
+func GetPositionDetailHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	p, found, code := fetchPosition(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := toResponse(p, apierror.Lang(c))
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreatePositionHandler 役職を追加する（POST /api/positions）
+// キーが既に使われている場合は 409
+func CreatePositionHandler(c *gin.Context) {
+	var req CreatePositionRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	fieldErrs = append(fieldErrs, requireNames(lang, &req.NameJA, &req.NameEN)...)
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	row := map[string]any{
+		"key":         strings.TrimSpace(req.Key),
+		"name_ja":     strings.TrimSpace(req.NameJA),
+		"name_en":     strings.TrimSpace(req.NameEN),
+		"permissions": permissionsOrEmpty(req.Permissions),
+	}
+	if req.Rank != nil {
+		row["rank"] = *req.Rank
+	} else {
+		rows, code := List(ctx, client)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		next := 0
+		if len(rows) > 0 {
+			next = rows[len(rows)-1].Rank + rankStep
+		}
+		row["rank"] = next
+	}
+
+	q := url.Values{}
+	q.Set("select", positionSelect)
+	respBody, status, postErr := client.Post(ctx, "/rest/v1/position", q, row)
+	if postErr != nil {
+		if status == http.StatusConflict {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", []apierror.FieldError{
+				validate.NewFieldError(lang, "key", "unique", ""),
+			})
+			return
+		}
+		log.Printf("DB_003: supabase insert position error: %v", postErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var created []PositionDTO
+	if err := json.Unmarshal(respBody, &created); err != nil || len(created) == 0 {
+		log.Printf("DB_002: json decode error (position insert): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	resp := toResponse(created[0], lang)
+	etag.Set(c, resp.Version)
+	c.Header("Location", "/api/positions/"+resp.ID)
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdatePositionHandler 役職を部分更新する（PATCH /api/positions/:id）
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+// 既定の役職（DefaultKey）のキーは変更できない
+func UpdatePositionHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req UpdatePositionRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if req.Key != nil && strings.TrimSpace(*req.Key) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "key", "required", ""))
+	}
+	fieldErrs = append(fieldErrs, requireNames(lang, req.NameJA, req.NameEN)...)
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchPosition(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, toResponse(current, lang)) {
+		return
+	}
+	if req.Key != nil && current.Key == DefaultKey && strings.TrimSpace(*req.Key) != DefaultKey {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(lang, "key", "oneof", DefaultKey),
+		})
+		return
+	}
+
+	patch := map[string]any{}
+	if req.Key != nil {
+		patch["key"] = strings.TrimSpace(*req.Key)
+	}
+	if req.NameJA != nil {
+		patch["name_ja"] = strings.TrimSpace(*req.NameJA)
+	}
+	if req.NameEN != nil {
+		patch["name_en"] = strings.TrimSpace(*req.NameEN)
+	}
+	if req.Rank != nil {
+		patch["rank"] = *req.Rank
+	}
+	if req.Permissions != nil {
+		patch["permissions"] = permissionsOrEmpty(*req.Permissions)
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	q.Set("updated_at", "eq."+deref(current.UpdatedAt))
+	q.Set("select", positionSelect)
+	respBody, status, patchErr := client.Patch(ctx, "/rest/v1/position", q, patch)
+	if patchErr != nil {
+		if status == http.StatusConflict {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", []apierror.FieldError{
+				validate.NewFieldError(lang, "key", "unique", ""),
+			})
+			return
+		}
+		log.Printf("DB_003: supabase patch position error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var updated []PositionDTO
+	if err := json.Unmarshal(respBody, &updated); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(updated) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchPosition(ctx, client, id); code == "" && found {
+			resp := toResponse(latest, lang)
+			etag.Conflict(c, resp.Version, resp)
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+	resp := toResponse(updated[0], lang)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeletePositionHandler 役職を削除する（DELETE /api/positions/:id）
+// スタッフに割り当てられている役職と既定の役職は削除できない（409）
+func DeletePositionHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchPosition(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if current.Key == DefaultKey {
+		apierror.Respond(c, apierror.CodeInUse)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	if _, status, delErr := client.Delete(ctx, "/rest/v1/position", q); delErr != nil {
+		// staff.position_id の外部キー（on delete restrict）に反する場合
+		if status == http.StatusConflict {
+			apierror.Respond(c, apierror.CodeInUse)
+			return
+		}
+		log.Printf("DB_003: supabase delete position error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// fetchPosition 役職1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchPosition(ctx context.Context, client *supa.Client, id string) (PositionDTO, bool, string) {
+	q := url.Values{}
+	q.Set("select", positionSelect)
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/position", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get position error: %v", getErr)
+		return PositionDTO{}, false, apierror.CodeDBInit
+	}
+	var rows []PositionDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (position): %v", err)
+		return PositionDTO{}, false, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return PositionDTO{}, false, ""
+	}
+	return rows[0], true, ""
+}
+
+// requireNames 指定された役職名が空白だけでないことを確認する（nil は未指定）
+func requireNames(lang string, ja, en *string) []apierror.FieldError {
+	var errs []apierror.FieldError
+	if ja != nil && strings.TrimSpace(*ja) == "" {
+		errs = append(errs, validate.NewFieldError(lang, "nameJa", "required", ""))
+	}
+	if en != nil && strings.TrimSpace(*en) == "" {
+		errs = append(errs, validate.NewFieldError(lang, "nameEn", "required", ""))
+	}
+	return errs
+}
+
+// permissionsOrEmpty permissions 列は not null のため未指定は空の配列にする
+func permissionsOrEmpty(p []string) []string {
+	if p == nil {
+		return []string{}
+	}
+	return p
+}
+
+func toResponse(p PositionDTO, lang string) PositionResponse {
+	return PositionResponse{
+		ID:          p.ID,
+		Key:         p.Key,
+		Name:        p.Name(lang),
+		NameJA:      p.NameJA,
+		NameEN:      p.NameEN,
+		Rank:        p.Rank,
+		Permissions: permissionsOrEmpty(p.Permissions),
+		CreatedAt:   deref(p.CreatedAt),
+		UpdatedAt:   deref(p.UpdatedAt),
+		Version:     etag.FromUpdatedAt(p.UpdatedAt),
+	}
+}
+
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+type counter struct {
+	count   int
+	resetAt time.Time
+}
+
+// Limiter キーごとに一定期間（固定ウィンドウ）の回数を数える
+// プロセス内メモリに保持するため、複数インスタンスで動かす場合は共有ストアへの置き換えが必要
+type Limiter struct {
+	limit    int
+	window   time.Duration
+	mu       sync.Mutex
+	counters map[string]*counter
+}
+
+// NewLimiter window の間に limit 回まで許可する。期限切れの掃除は ctx が終わるまで定期的に行う
+func NewLimiter(ctx context.Context, limit int, window time.Duration) *Limiter {
+	l := &Limiter{limit: limit, window: window, counters: map[string]*counter{}}
+	go l.janitor(ctx)
+	return l
+}
+
+func (l *Limiter) janitor(ctx context.Context) {
+	t := time.NewTicker(l.window)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			l.mu.Lock()
+			for k, cnt := range l.counters {
+				if !now.Before(cnt.resetAt) {
+					delete(l.counters, k)
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// Allow key の回数を1つ進め、上限内なら true を返す
+// 上限を超えた場合は次に許可されるまでの時間を返す
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cnt, ok := l.counters[key]
+	if !ok || !now.Before(cnt.resetAt) {
+		cnt = &counter{resetAt: now.Add(l.window)}
+		l.counters[key] = cnt
+	}
+	if cnt.count >= l.limit {
+		return false, cnt.resetAt.Sub(now)
+	}
+	cnt.count++
+	return true, 0
+}
+
+// Middleware keyFunc が返すキー単位で回数を制限し、超えた場合は 429 と Retry-After を返す
+// keyFunc が空文字を返したリクエストは制限しない
+func Middleware(l *Limiter, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if ok, retry := l.Allow(key); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+			apierror.Respond(c, apierror.CodeRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// 証明書ファイルの更新確認間隔（ハンドシェイクごとに stat しないよう間引く）
+const certCheckInterval = 10 * time.Second
+
+// certReloader 証明書・秘密鍵・クライアントCAをファイルから読み込み、
+// ファイルの更新（証明書のローテーション）を検知したら再起動なしで差し替える
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("read client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("client CA file contains no certificates")
+		}
+	}
+	mod := r.latestModTime()
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = pool
+	r.modTime = mod
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+		if st, err := os.Stat(f); err == nil && st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest
+}
+
+// maybeReload 前回確認から一定時間経過していればファイルの更新時刻を確認し、変わっていれば読み直す
+// 読み直しに失敗した場合（ローテーション途中で鍵と証明書が揃っていない等）は旧証明書を使い続ける
+func (r *certReloader) maybeReload() {
+	r.mu.RLock()
+	due := time.Since(r.checkedAt) >= certCheckInterval
+	prev := r.modTime
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+	if mod := r.latestModTime(); mod.After(prev) {
+		if err := r.reload(); err != nil {
+			log.Printf("TLS: certificate reload failed, keeping previous: %v", err)
+			r.mu.Lock()
+			r.checkedAt = time.Now()
+			r.mu.Unlock()
+			return
+		}
+		log.Printf("TLS: certificate reloaded from %s", r.certFile)
+		return
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// tlsConfig TCP(HTTP/1.1, HTTP/2) 用の TLS 設定を組み立てる
+// クライアントCAが設定されている場合は、証明書を提示したクライアントのみ検証する（任意の mTLS）
+func (r *certReloader) tlsConfig(requireClientCert bool) *tls.Config {
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: r.getCertificate,
+	}
+	if r.caFile == "" {
+		return base
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.maybeReload()
+		r.mu.RLock()
+		pool := r.clientCAs
+		r.mu.RUnlock()
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		if requireClientCert {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return cfg, nil
+	}
+	return base
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Config 待ち受けと TLS の設定
+// CertFile / KeyFile が未設定の場合は従来どおり平文 HTTP/1.1 で待ち受ける
+type Config struct {
+	Addr              string
+	CertFile          string
+	KeyFile           string
+	ClientCAFile      string
+	RequireClientCert bool
+	EnableHTTP3       bool
+}
+
+// LoadConfigFromEnv 環境変数から設定を読み込む
+//
+//	LISTEN_ADDR          待ち受けアドレス（既定 :8080。HTTP/3 は同じポートの UDP）
+//	TLS_CERT_FILE        サーバー証明書（PEM）
+//	TLS_KEY_FILE         秘密鍵（PEM）
+//	TLS_CLIENT_CA_FILE   クライアント証明書を検証する CA（設定時のみ mTLS を有効化）
+//	TLS_CLIENT_AUTH      "require" でクライアント証明書を必須にする（既定は提示された場合のみ検証。CA が必要）
+//	HTTP3_ENABLED        "false" で HTTP/3 を無効化（TLS 有効時の既定は有効）
+//
+// 検証済みのクライアント証明書の CN は auth.Middleware が TLS_CLIENT_ROLES で利用者にする
+func LoadConfigFromEnv() Config {
+	addr := strings.TrimSpace(os.Getenv("LISTEN_ADDR"))
+	if addr == "" {
+		addr = ":8080"
+	}
+	return Config{
+		Addr:              addr,
+		CertFile:          strings.TrimSpace(os.Getenv("TLS_CERT_FILE")),
+		KeyFile:           strings.TrimSpace(os.Getenv("TLS_KEY_FILE")),
+		ClientCAFile:      strings.TrimSpace(os.Getenv("TLS_CLIENT_CA_FILE")),
+		RequireClientCert: strings.EqualFold(strings.TrimSpace(os.Getenv("TLS_CLIENT_AUTH")), "require"),
+		EnableHTTP3:       !strings.EqualFold(strings.TrimSpace(os.Getenv("HTTP3_ENABLED")), "false"),
+	}
+}
+
+// TLSEnabled 証明書と鍵の両方が設定されているか
+func (cfg Config) TLSEnabled() bool {
+	return cfg.CertFile != "" && cfg.KeyFile != ""
+}
+
+// Server HTTP/1.1・HTTP/2（TCP）と HTTP/3（UDP）をまとめて管理する
+type Server struct {
+	cfg  Config
+	h1h2 *http.Server
+	h3   *http3.Server
+}
+
+// New 設定を検証し、証明書を読み込んだ Server を返す
+func New(cfg Config) (*Server, error) {
+	s := &Server{cfg: cfg}
+	s.h1h2 = &http.Server{
+		Addr:              cfg.Addr,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if cfg.RequireClientCert && cfg.ClientCAFile == "" {
+		return nil, errors.New("TLS_CLIENT_AUTH=require needs TLS_CLIENT_CA_FILE")
+	}
+	if !cfg.TLSEnabled() {
+		if cfg.CertFile != "" || cfg.KeyFile != "" {
+			return nil, errors.New("both TLS_CERT_FILE and TLS_KEY_FILE must be set")
+		}
+		if cfg.ClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE needs TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return s, nil
+	}
+
+	reloader, err := newCertReloader(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	tlsConf := reloader.tlsConfig(cfg.RequireClientCert)
+	s.h1h2.TLSConfig = tlsConf
+	if cfg.EnableHTTP3 {
+		s.h3 = &http3.Server{
+			Addr:      cfg.Addr,
+			TLSConfig: tlsConf,
+			// 0-RTT はリプレイされ得るため、更新系 API を持つこのサーバーでは受け付けない
+			QUICConfig: &quic.Config{Allow0RTT: false},
+		}
+	}
+	return s, nil
+}
+
+// Middleware HTTP/3 の Alt-Svc ヘッダーを付与する
+func (s *Server) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.h3 != nil && c.Request.ProtoMajor < 3 {
+			_ = s.h3.SetQUICHeaders(c.Writer.Header())
+		}
+		c.Next()
+	}
+}
+
+// Run handler を待ち受け、SIGINT / SIGTERM を受けたら処理中のリクエストを待って終了する
+func (s *Server) Run(handler http.Handler) error {
+	s.h1h2.Handler = handler
+	if s.h3 != nil {
+		s.h3.Handler = handler
+	}
+
+	errCh := make(chan error, 2)
+	go func() {
+		if s.cfg.TLSEnabled() {
+			log.Printf("server: listening on %s (TLS, HTTP/2)", s.cfg.Addr)
+			// 証明書は TLSConfig.GetCertificate から供給するためファイル名は空でよい
+			errCh <- s.h1h2.ListenAndServeTLS("", "")
+			return
+		}
+		log.Printf("server: listening on %s (plaintext HTTP/1.1)", s.cfg.Addr)
+		errCh <- s.h1h2.ListenAndServe()
+	}()
+	if s.h3 != nil {
+		go func() {
+			log.Printf("server: listening on %s/udp (HTTP/3)", s.cfg.Addr)
+			errCh <- s.h3.ListenAndServe()
+		}()
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	var runErr error
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			runErr = err
+		}
+	case sig := <-sigCh:
+		log.Printf("server: received %s, shutting down", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := s.h1h2.Shutdown(ctx); err != nil && runErr == nil {
+		runErr = err
+	}
+	if s.h3 != nil {
+		if err := s.h3.Shutdown(ctx); err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+	return runErr
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA 実行時に生成する自己署名の CA
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue CA が署名した証明書と秘密鍵（PEM）を返す。client なら クライアント認証用
+func (ca *testCA) issue(t *testing.T, serial int64, cn string, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		tmpl.DNSNames, tmpl.IPAddresses = nil, nil
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testFiles サーバー証明書・鍵・クライアント CA を一時ディレクトリに書き出した設定
+func testFiles(t *testing.T, ca *testCA) Config {
+	t.Helper()
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, 100, "localhost", false)
+	cfg := Config{
+		Addr:         "127.0.0.1:0",
+		CertFile:     filepath.Join(dir, "cert.pem"),
+		KeyFile:      filepath.Join(dir, "key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	writeFile(t, cfg.ClientCAFile, ca.pem)
+	return cfg
+}
+
+// serve s の TCP 側（HTTP/1.1・HTTP/2）をランダムなポートで起動し、URL を返す
+func serve(t *testing.T, s *Server) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.h1h2.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cn := ""
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			cn = r.TLS.VerifiedChains[0][0].Subject.CommonName
+		}
+		_, _ = io.WriteString(w, r.Proto+" "+cn)
+	})
+	go func() { _ = s.h1h2.ServeTLS(ln, "", "") }()
+	t.Cleanup(func() { _ = s.h1h2.Close() })
+	return "https://" + ln.Addr().String() + "/"
+}
+
+func testClient(ca *testCA, certs ...tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.pem)
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool, ServerName: "localhost", Certificates: certs},
+			ForceAttemptHTTP2: true,
+		},
+	}
+}
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestServeTLSWithHTTP2(t *testing.T) {
+	ca := newTestCA(t)
+	cfg := testFiles(t, ca)
+	cfg.ClientCAFile = ""
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, s)
+
+	resp, body := get(t, testClient(ca), url)
+	if resp.ProtoMajor != 2 {
+		t.Errorf("protocol = %s, want HTTP/2", resp.Proto)
+	}
+	if body != "HTTP/2.0 " {
+		t.Errorf("body = %q", body)
+	}
+	if got := resp.TLS.PeerCertificates[0].SerialNumber.Int64(); got != 100 {
+		t.Errorf("server certificate serial = %d, want 100", got)
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	ca := newTestCA(t)
+	cfg := testFiles(t, ca)
+	r, err := newCertReloader(cfg.CertFile, cfg.KeyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial := func() int64 {
+		t.Helper()
+		cert, err := r.getCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+	if got := serial(); got != 100 {
+		t.Fatalf("serial = %d, want 100", got)
+	}
+
+	// 証明書をローテーションする（更新時刻は確実に進める）
+	certPEM, keyPEM := ca.issue(t, 200, "localhost", false)
+	writeFile(t, cfg.CertFile, certPEM)
+	writeFile(t, cfg.KeyFile, keyPEM)
+	later := time.Now().Add(time.Minute)
+	for _, f := range []string{cfg.CertFile, cfg.KeyFile} {
+		if err := os.Chtimes(f, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := serial(); got != 100 {
+		t.Errorf("serial before the check interval = %d, want 100", got)
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.mu.Unlock()
+	if got := serial(); got != 200 {
+		t.Errorf("serial after rotation = %d, want 200", got)
+	}
+
+	// 読み込めないファイルに差し替えられた場合は前の証明書を使い続ける
+	writeFile(t, cfg.KeyFile, []byte("broken"))
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(cfg.KeyFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.mu.Unlock()
+	if got := serial(); got != 200 {
+		t.Errorf("serial after a broken rotation = %d, want 200", got)
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	cfg := testFiles(t, ca)
+	cfg.RequireClientCert = true
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, s)
+
+	if resp, err := testClient(ca).Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatal("request without a client certificate succeeded")
+	}
+
+	certPEM, keyPEM := ca.issue(t, 300, "billing-batch", true)
+	clientCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, body := get(t, testClient(ca, clientCert), url); body != "HTTP/2.0 billing-batch" {
+		t.Errorf("body = %q, want the verified client CN", body)
+	}
+
+	// 別の CA が署名したクライアント証明書は拒否する
+	other := newTestCA(t)
+	certPEM, keyPEM = other.issue(t, 400, "intruder", true)
+	otherCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := testClient(ca, otherCert).Get(url); err == nil {
+		resp.Body.Close()
+		t.Fatal("request with a certificate from an unknown CA succeeded")
+	}
+}
+
+func TestOptionalClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	s, err := New(testFiles(t, ca))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serve(t, s)
+	if _, body := get(t, testClient(ca), url); body != "HTTP/2.0 " {
+		t.Errorf("body = %q, want no client CN", body)
+	}
+}
+
+func TestNewConfigErrors(t *testing.T) {
+	ca := newTestCA(t)
+	files := testFiles(t, ca)
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"require without CA", Config{Addr: ":0", CertFile: files.CertFile, KeyFile: files.KeyFile, RequireClientCert: true}},
+		{"require without TLS", Config{Addr: ":0", RequireClientCert: true}},
+		{"CA without TLS", Config{Addr: ":0", ClientCAFile: files.ClientCAFile}},
+		{"cert without key", Config{Addr: ":0", CertFile: files.CertFile}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("New succeeded, want a configuration error")
+			}
+		})
+	}
+}
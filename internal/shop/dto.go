@@ -1,59 +1,120 @@
 package shop
 
 type ShopDTO struct {
-	ID                              string  `json:"id"`
-	SPID                            *int    `json:"spid"`
-	DepartmentNo                    *int    `json:"department_no"`
-	AccountingCategory              *string `json:"accounting_category"`
-	StoreName                       *string `json:"store_name"`
-	StoreNameFurigana              *string `json:"store_name_furigana"`
-	StoreNameShort                  *string `json:"store_name_short"`
-	PhoneNumber                     *string `json:"phone_number"`
-	URL                             *string `json:"url"`
-	Mail                            *string `json:"mail"`
-	IsWeb                           *bool   `json:"is_web"`
-	WebManagementID                 *string `json:"web_management_id"`
-	WebManagementPW                 *string `json:"web_management_pw"`
-	WebManagementURL                *string `json:"web_management_url"`
-	HostessPageURL                 *string `json:"hostess_page_url"`
-	HostessListURL                 *string `json:"hostess_list_url"`
-	HostessAttendanceManagementURL *string `json:"hostess_attendance_management_url"`
-	HostessManagementURL            *string `json:"hostess_management_url"`
-	SendHsprofile                  *string `json:"send_hsprofile"`
-	SendHsattend                   *string `json:"send_hsattend"`
-	SendHsjob                      *string `json:"send_hsjob"`
-	SendCtpoint                    *string `json:"send_ctpoint"`
-	SendHsstart                    *string `json:"send_hsstart"`
-	SendHsranking                  *string `json:"send_hsranking"`
-	CourseFeeStyle                 *bool   `json:"course_fee_style"`
-	NominationFeeStyle             *bool   `json:"nomination_fee_style"`
-	GmCategory                     *bool   `json:"gm_category"`
-	NominationFee                  *int    `json:"nomination_fee"`
-	ExtensionFee                   *int    `json:"extension_fee"`
-	ExtensionPerMinutes            *int    `json:"extension_per_minutes"`
-	StandardTransportationExpenses *int    `json:"standard_transportation_expenses"`
-	CancelFee                      *int    `json:"cancel_fee"`
-	IsMembershipCard               *bool   `json:"is_membership_card"`
-	CustomerPointInitialFormer     *int    `json:"customer_point_initial_former"`
-	CustomerPointInitialLatter     *int    `json:"customer_point_initial_latter"`
-	IsNominationPlusback          *bool   `json:"is_nomination_plusback"`
-	MembershipNumberManagement     *bool   `json:"membership_number_management"`
-	ChangeFee                      *int    `json:"change_fee"`
-	CardCommission                 *int    `json:"card_commission"`
+	ID                             string   `json:"id"`
+	SPID                           *int     `json:"spid"`
+	DepartmentNo                   *int     `json:"department_no"`
+	AccountingCategory             *string  `json:"accounting_category"`
+	StoreName                      *string  `json:"store_name"`
+	StoreNameFurigana              *string  `json:"store_name_furigana"`
+	StoreNameShort                 *string  `json:"store_name_short"`
+	PhoneNumber                    *string  `json:"phone_number"`
+	URL                            *string  `json:"url"`
+	Mail                           *string  `json:"mail"`
+	IsWeb                          *bool    `json:"is_web"`
+	WebManagementID                *string  `json:"web_management_id"`
+	WebManagementPW                *string  `json:"web_management_pw"`
+	WebManagementURL               *string  `json:"web_management_url"`
+	HostessPageURL                 *string  `json:"hostess_page_url"`
+	HostessListURL                 *string  `json:"hostess_list_url"`
+	HostessAttendanceManagementURL *string  `json:"hostess_attendance_management_url"`
+	HostessManagementURL           *string  `json:"hostess_management_url"`
+	SendHsprofile                  *string  `json:"send_hsprofile"`
+	SendHsattend                   *string  `json:"send_hsattend"`
+	SendHsjob                      *string  `json:"send_hsjob"`
+	SendCtpoint                    *string  `json:"send_ctpoint"`
+	SendHsstart                    *string  `json:"send_hsstart"`
+	SendHsranking                  *string  `json:"send_hsranking"`
+	CourseFeeStyle                 *bool    `json:"course_fee_style"`
+	NominationFeeStyle             *bool    `json:"nomination_fee_style"`
+	GmCategory                     *bool    `json:"gm_category"`
+	NominationFee                  *int     `json:"nomination_fee"`
+	ExtensionFee                   *int     `json:"extension_fee"`
+	ExtensionPerMinutes            *int     `json:"extension_per_minutes"`
+	StandardTransportationExpenses *int     `json:"standard_transportation_expenses"`
+	CancelFee                      *int     `json:"cancel_fee"`
+	IsMembershipCard               *bool    `json:"is_membership_card"`
+	CustomerPointInitialFormer     *int     `json:"customer_point_initial_former"`
+	CustomerPointInitialLatter     *int     `json:"customer_point_initial_latter"`
+	IsNominationPlusback           *bool    `json:"is_nomination_plusback"`
+	MembershipNumberManagement     *bool    `json:"membership_number_management"`
+	ChangeFee                      *int     `json:"change_fee"`
+	CardCommission                 *int     `json:"card_commission"`
 	StandardHostessRecieveRate     *float64 `json:"standard_hostess_recieve_rate"`
 	ExtensionStyle                 *string  `json:"extension_style"`
-	ExtensionHostessRecieveRate   *float64 `json:"extension_hostess_recieve_rate"`
+	ExtensionHostessRecieveRate    *float64 `json:"extension_hostess_recieve_rate"`
 	PanelNominationFee             *int     `json:"panel_nomination_fee"`
 	StarPrice                      *int     `json:"star_price"`
 	GroupNo                        *int     `json:"group_no"`
-	BusinessStyle                  *string  `json:"business_style"`
-	FormerStart                    *string  `json:"former_start"`
-	FormerEnd                      *string  `json:"former_end"`
-	LatterStart                    *string  `json:"latter_start"`
-	LatterEnd                      *string  `json:"latter_end"`
-	IsHsSendRoomNo                 *bool   `json:"is_hs_send_room_no"`
-	IsHsSendEnd                    *bool   `json:"is_hs_send_end"`
-	CreatedAt                      *string `json:"created_at"`
-	UpdatedAt                      *string `json:"updated_at"`
+	Group                          *struct {
+		Number int    `json:"number"`
+		Name   string `json:"name"`
+	} `json:"group,omitempty"` // グループマスタの埋め込み（グループ名の表示用）
+	BusinessStyle  *string `json:"business_style"`
+	FormerStart    *string `json:"former_start"`
+	FormerEnd      *string `json:"former_end"`
+	LatterStart    *string `json:"latter_start"`
+	LatterEnd      *string `json:"latter_end"`
+	IsHsSendRoomNo *bool   `json:"is_hs_send_room_no"`
+	IsHsSendEnd    *bool   `json:"is_hs_send_end"`
+	CreatedAt      *string `json:"created_at"`
+	UpdatedAt      *string `json:"updated_at"`
+	Version        string  `json:"version,omitempty"` // 詳細取得時のみ。楽観的排他制御用（ETag と同じ値）
 }
 
+// UpdateShopRequest 店舗の部分更新リクエスト（未指定の項目は変更しない）
+// web_management_pw は暗号化方式が決まるまで API からは更新しない
+type UpdateShopRequest struct {
+	SPID                           *int     `json:"spid,omitempty" binding:"omitempty,min=0"`
+	DepartmentNo                   *int     `json:"department_no,omitempty" binding:"omitempty,min=0"`
+	AccountingCategory             *string  `json:"accounting_category,omitempty" binding:"omitempty,max=10"`
+	StoreName                      *string  `json:"store_name,omitempty" binding:"omitempty,max=255"`
+	StoreNameFurigana              *string  `json:"store_name_furigana,omitempty" binding:"omitempty,max=255"`
+	StoreNameShort                 *string  `json:"store_name_short,omitempty" binding:"omitempty,max=255"`
+	PhoneNumber                    *string  `json:"phone_number,omitempty" binding:"omitempty,phone"`
+	URL                            *string  `json:"url,omitempty" binding:"omitempty,max=255,eq=|url"`
+	Mail                           *string  `json:"mail,omitempty" binding:"omitempty,max=255,eq=|email"`
+	IsWeb                          *bool    `json:"is_web,omitempty"`
+	WebManagementID                *string  `json:"web_management_id,omitempty" binding:"omitempty,max=255"`
+	WebManagementURL               *string  `json:"web_management_url,omitempty" binding:"omitempty,max=255,eq=|url"`
+	HostessPageURL                 *string  `json:"hostess_page_url,omitempty" binding:"omitempty,max=255,eq=|url"`
+	HostessListURL                 *string  `json:"hostess_list_url,omitempty" binding:"omitempty,max=255,eq=|url"`
+	HostessAttendanceManagementURL *string  `json:"hostess_attendance_management_url,omitempty" binding:"omitempty,max=255,eq=|url"`
+	HostessManagementURL           *string  `json:"hostess_management_url,omitempty" binding:"omitempty,max=255,eq=|url"`
+	SendHsprofile                  *string  `json:"send_hsprofile,omitempty" binding:"omitempty,max=255"`
+	SendHsattend                   *string  `json:"send_hsattend,omitempty" binding:"omitempty,max=255"`
+	SendHsjob                      *string  `json:"send_hsjob,omitempty" binding:"omitempty,max=255"`
+	SendCtpoint                    *string  `json:"send_ctpoint,omitempty" binding:"omitempty,max=255"`
+	SendHsstart                    *string  `json:"send_hsstart,omitempty" binding:"omitempty,max=255"`
+	SendHsranking                  *string  `json:"send_hsranking,omitempty" binding:"omitempty,max=255"`
+	CourseFeeStyle                 *bool    `json:"course_fee_style,omitempty"`
+	NominationFeeStyle             *bool    `json:"nomination_fee_style,omitempty"`
+	GmCategory                     *bool    `json:"gm_category,omitempty"`
+	NominationFee                  *int     `json:"nomination_fee,omitempty" binding:"omitempty,min=0"`
+	ExtensionFee                   *int     `json:"extension_fee,omitempty" binding:"omitempty,min=0"`
+	ExtensionPerMinutes            *int     `json:"extension_per_minutes,omitempty" binding:"omitempty,min=0"`
+	StandardTransportationExpenses *int     `json:"standard_transportation_expenses,omitempty" binding:"omitempty,min=0"`
+	CancelFee                      *int     `json:"cancel_fee,omitempty" binding:"omitempty,min=0"`
+	IsMembershipCard               *bool    `json:"is_membership_card,omitempty"`
+	CustomerPointInitialFormer     *int     `json:"customer_point_initial_former,omitempty" binding:"omitempty,min=0"`
+	CustomerPointInitialLatter     *int     `json:"customer_point_initial_latter,omitempty" binding:"omitempty,min=0"`
+	IsNominationPlusback           *bool    `json:"is_nomination_plusback,omitempty"`
+	MembershipNumberManagement     *bool    `json:"membership_number_management,omitempty"`
+	ChangeFee                      *int     `json:"change_fee,omitempty" binding:"omitempty,min=0"`
+	CardCommission                 *int     `json:"card_commission,omitempty" binding:"omitempty,min=0"`
+	StandardHostessRecieveRate     *float64 `json:"standard_hostess_recieve_rate,omitempty" binding:"omitempty,min=0,max=100"`
+	ExtensionStyle                 *string  `json:"extension_style,omitempty" binding:"omitempty,oneof=fixed_rate hostess_specific"`
+	ExtensionHostessRecieveRate    *float64 `json:"extension_hostess_recieve_rate,omitempty" binding:"omitempty,min=0,max=100"`
+	PanelNominationFee             *int     `json:"panel_nomination_fee,omitempty" binding:"omitempty,min=0"`
+	StarPrice                      *int     `json:"star_price,omitempty" binding:"omitempty,min=0"`
+	GroupNo                        *int     `json:"group_no,omitempty" binding:"omitempty,min=0"` // group.number（0 で未設定）
+	BusinessStyle                  *string  `json:"business_style,omitempty" binding:"omitempty,oneof=delivery_health hotel_health"`
+	FormerStart                    *string  `json:"former_start,omitempty" binding:"omitempty,hhmm"`
+	FormerEnd                      *string  `json:"former_end,omitempty" binding:"omitempty,hhmm"`
+	LatterStart                    *string  `json:"latter_start,omitempty" binding:"omitempty,hhmm"`
+	LatterEnd                      *string  `json:"latter_end,omitempty" binding:"omitempty,hhmm"`
+	IsHsSendRoomNo                 *bool    `json:"is_hs_send_room_no,omitempty"`
+	IsHsSendEnd                    *bool    `json:"is_hs_send_end,omitempty"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version,omitempty"`
+}
@@ -9,16 +9,15 @@ import (
 	"strings"
 	"time"
 
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	group "nissyo/internal/group"
 	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
 
 	"github.com/gin-gonic/gin"
 )
 
-type ErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-}
-
 func coalesce(ptr *string, fallback string) string {
 	if ptr != nil {
 		return *ptr
@@ -39,6 +38,26 @@ func maskPhone(phone *string) *string {
 	return &masked
 }
 
+// shopSelect 一覧・詳細で取得する列（web_management_pw は返却しないため取得しない）
+var shopSelect = strings.Join([]string{
+	"id", "spid", "department_no", "accounting_category", "store_name", "store_name_furigana",
+	"store_name_short", "phone_number", "url", "mail", "is_web", "web_management_id",
+	"web_management_url", "hostess_page_url", "hostess_list_url",
+	"hostess_attendance_management_url", "hostess_management_url",
+	"send_hsprofile", "send_hsattend", "send_hsjob", "send_ctpoint",
+	"send_hsstart", "send_hsranking", "course_fee_style", "nomination_fee_style",
+	"gm_category", "nomination_fee", "extension_fee", "extension_per_minutes",
+	"standard_transportation_expenses", "cancel_fee", "is_membership_card",
+	"customer_point_initial_former", "customer_point_initial_latter",
+	"is_nomination_plusback", "membership_number_management", "change_fee",
+	"card_commission", "standard_hostess_recieve_rate", "extension_style",
+	"extension_hostess_recieve_rate", "panel_nomination_fee", "star_price",
+	"group_no", "business_style", "former_start", "former_end",
+	"latter_start", "latter_end", "is_hs_send_room_no", "is_hs_send_end",
+	"created_at", "updated_at",
+	"group:group_no(number,name)",
+}, ",")
+
 // GetShopListHandler 店舗一覧を取得するハンドラー
 func GetShopListHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
@@ -47,50 +66,31 @@ func GetShopListHandler(c *gin.Context) {
 	client, err := supa.NewClientFromEnv()
 	if err != nil {
 		log.Printf("DB_001: failed to init supabase client: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "backend initialization error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
 	q := url.Values{}
-	q.Set("select", strings.Join([]string{
-		"id", "spid", "department_no", "accounting_category", "store_name", "store_name_furigana",
-		"store_name_short", "phone_number", "url", "mail", "is_web", "web_management_id",
-		"web_management_url", "hostess_page_url", "hostess_list_url",
-		"hostess_attendance_management_url", "hostess_management_url",
-		"send_hsprofile", "send_hsattend", "send_hsjob", "send_ctpoint",
-		"send_hsstart", "send_hsranking", "course_fee_style", "nomination_fee_style",
-		"gm_category", "nomination_fee", "extension_fee", "extension_per_minutes",
-		"standard_transportation_expenses", "cancel_fee", "is_membership_card",
-		"customer_point_initial_former", "customer_point_initial_latter",
-		"is_nomination_plusback", "membership_number_management", "change_fee",
-		"card_commission", "standard_hostess_recieve_rate", "extension_style",
-		"extension_hostess_recieve_rate", "panel_nomination_fee", "star_price",
-		"group_no", "business_style", "former_start", "former_end",
-		"latter_start", "latter_end", "is_hs_send_room_no", "is_hs_send_end",
-		"created_at", "updated_at",
-	}, ","))
+	q.Set("select", shopSelect)
 	q.Set("order", "created_at.asc")
 	q.Set("limit", "200")
 
 	body, _, getErr := client.Get(ctx, "/rest/v1/shop", q)
 	if getErr != nil {
 		log.Printf("DB_001: supabase get error: %v", getErr)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "database fetch error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
 	var rows []ShopDTO
 	if err := json.Unmarshal(body, &rows); err != nil {
 		log.Printf("DB_002: json decode error: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_002", Message: "response decode error"})
+		apierror.Respond(c, apierror.CodeDBDecode)
 		return
 	}
 
-	// 個人情報(電話番号)は返却時にマスク
 	for i := range rows {
-		rows[i].PhoneNumber = maskPhone(rows[i].PhoneNumber)
-		// パスワードは返却しない（セキュリティ）
-		rows[i].WebManagementPW = nil
+		rows[i] = sanitizeShop(rows[i])
 	}
 
 	c.JSON(http.StatusOK, rows)
@@ -100,7 +100,7 @@ func GetShopListHandler(c *gin.Context) {
 func GetShopDetailHandler(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Code: "VAL_001", Message: "missing id"})
+		apierror.Respond(c, apierror.CodeMissingID)
 		return
 	}
 
@@ -110,56 +110,166 @@ func GetShopDetailHandler(c *gin.Context) {
 	client, err := supa.NewClientFromEnv()
 	if err != nil {
 		log.Printf("DB_001: failed to init supabase client: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "backend initialization error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
+	shop, found, code := fetchShop(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+
+	shop.Version = etag.FromUpdatedAt(shop.UpdatedAt)
+	etag.Set(c, shop.Version)
+	c.JSON(http.StatusOK, sanitizeShop(shop))
+}
+
+// fetchShop 店舗1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchShop(ctx context.Context, client *supa.Client, id string) (ShopDTO, bool, string) {
 	q := url.Values{}
-	q.Set("select", strings.Join([]string{
-		"id", "spid", "department_no", "accounting_category", "store_name", "store_name_furigana",
-		"store_name_short", "phone_number", "url", "mail", "is_web", "web_management_id",
-		"web_management_url", "hostess_page_url", "hostess_list_url",
-		"hostess_attendance_management_url", "hostess_management_url",
-		"send_hsprofile", "send_hsattend", "send_hsjob", "send_ctpoint",
-		"send_hsstart", "send_hsranking", "course_fee_style", "nomination_fee_style",
-		"gm_category", "nomination_fee", "extension_fee", "extension_per_minutes",
-		"standard_transportation_expenses", "cancel_fee", "is_membership_card",
-		"customer_point_initial_former", "customer_point_initial_latter",
-		"is_nomination_plusback", "membership_number_management", "change_fee",
-		"card_commission", "standard_hostess_recieve_rate", "extension_style",
-		"extension_hostess_recieve_rate", "panel_nomination_fee", "star_price",
-		"group_no", "business_style", "former_start", "former_end",
-		"latter_start", "latter_end", "is_hs_send_room_no", "is_hs_send_end",
-		"created_at", "updated_at",
-	}, ","))
+	q.Set("select", shopSelect)
 	q.Set("id", "eq."+id)
 	q.Set("limit", "1")
 
 	body, _, getErr := client.Get(ctx, "/rest/v1/shop", q)
 	if getErr != nil {
 		log.Printf("DB_001: supabase get error: %v", getErr)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "database fetch error"})
-		return
+		return ShopDTO{}, false, apierror.CodeDBInit
 	}
 
 	var rows []ShopDTO
 	if err := json.Unmarshal(body, &rows); err != nil {
 		log.Printf("DB_002: json decode error: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_002", Message: "response decode error"})
-		return
+		return ShopDTO{}, false, apierror.CodeDBDecode
 	}
-
 	if len(rows) == 0 {
-		c.JSON(http.StatusNotFound, ErrorResponse{Code: "DB_404", Message: "shop not found"})
-		return
+		return ShopDTO{}, false, ""
 	}
+	return rows[0], true, ""
+}
 
-	shop := rows[0]
+// sanitizeShop 返却前に個人情報をマスクし、パスワードを取り除く
+func sanitizeShop(shop ShopDTO) ShopDTO {
 	// 個人情報(電話番号)は返却時にマスク
 	shop.PhoneNumber = maskPhone(shop.PhoneNumber)
 	// パスワードは返却しない（セキュリティ）
 	shop.WebManagementPW = nil
-
-	c.JSON(http.StatusOK, shop)
+	return shop
 }
 
+// UpdateShopHandler 店舗を部分更新するハンドラー
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+func UpdateShopHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 12*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req UpdateShopRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	current, found, code := fetchShop(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	current.Version = currentVersion
+	if !etag.Check(c, req.Version, currentVersion, sanitizeShop(current)) {
+		return
+	}
+
+	// 指定された項目だけをパッチにする（omitempty により未指定の項目は含まれない）
+	req.Version = nil
+	raw, err := json.Marshal(req)
+	if err != nil {
+		log.Printf("VAL_002: encode patch error: %v", err)
+		apierror.Respond(c, apierror.CodeInternal)
+		return
+	}
+	patch := map[string]any{}
+	_ = json.Unmarshal(raw, &patch)
+	if req.GroupNo != nil {
+		// 0 は未設定に戻す。それ以外はグループマスタにあるナンバーのみ
+		if *req.GroupNo == 0 {
+			patch["group_no"] = nil
+		} else {
+			groups, code := group.List(ctx, client)
+			if code != "" {
+				apierror.Respond(c, code)
+				return
+			}
+			if _, ok := groups.ByNumber(*req.GroupNo); !ok {
+				apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+					validate.NewFieldError(apierror.Lang(c), "group_no", "exists", ""),
+				})
+				return
+			}
+		}
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	q.Set("updated_at", "eq."+coalesce(current.UpdatedAt, ""))
+	q.Set("select", shopSelect)
+	respBody, _, patchErr := client.Patch(ctx, "/rest/v1/shop", q, patch)
+	if patchErr != nil {
+		log.Printf("DB_003: supabase patch error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var updated []ShopDTO
+	if err := json.Unmarshal(respBody, &updated); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(updated) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchShop(ctx, client, id); code == "" && found {
+			latest.Version = etag.FromUpdatedAt(latest.UpdatedAt)
+			etag.Conflict(c, latest.Version, sanitizeShop(latest))
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+
+	shop := updated[0]
+	shop.Version = etag.FromUpdatedAt(shop.UpdatedAt)
+	etag.Set(c, shop.Version)
+	c.JSON(http.StatusOK, sanitizeShop(shop))
+}
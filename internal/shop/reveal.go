@@ -0,0 +1,70 @@
+package shop
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	accesslog "nissyo/internal/accesslog"
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevealShopContactResponse マスクしていない連絡先（パスワードは含めない）
+type RevealShopContactResponse struct {
+	ID          string  `json:"id"`
+	PhoneNumber *string `json:"phone_number"`
+	Mail        *string `json:"mail"`
+}
+
+// RevealShopContactHandler 店舗のマスク前の電話番号を返す（POST /api/shops/:id/reveal）
+// 権限の確認と回数制限はルーター側のミドルウェアで行う。表示の記録に失敗した場合は値を返さない
+func RevealShopContactHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	reason, ok := accesslog.BindReason(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	shop, found, code := fetchShop(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+
+	if err := accesslog.RecordReveal(ctx, client, c, accesslog.Reveal{
+		ResourceType: accesslog.ResourceShop,
+		ResourceID:   shop.ID,
+		Fields:       []string{"phone_number", "mail"},
+		Reason:       reason,
+	}); err != nil {
+		log.Printf("DB_003: %v", err)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, RevealShopContactResponse{ID: shop.ID, PhoneNumber: shop.PhoneNumber, Mail: shop.Mail})
+}
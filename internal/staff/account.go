@@ -0,0 +1,123 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	auth "nissyo/internal/auth"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// staff_account の既定値（行が作られたときと同じ）
+const (
+	defaultAccessType     = "staff"
+	defaultAccessStatus   = "active"
+	defaultAdjustmentRate = 1.0
+)
+
+// staffAccountSelect スタッフのアカウントの埋め込み
+const staffAccountSelect = "staff_account(login_id,access_type,access_status,adjustment_rate)"
+
+// accountFields 台帳・詳細に出すアカウントの項目（アカウント未取得時は既定値。ログインIDは空）
+func accountFields(a *StaffAccountDTO) (name, accessType, accessStatus string, rate float64) {
+	if a == nil {
+		return "", defaultAccessType, defaultAccessStatus, defaultAdjustmentRate
+	}
+	return a.LoginID, a.AccessType, a.AccessStatus, a.AdjustmentRate
+}
+
+// buildAccountPatch リクエストで指定されたアカウントの項目を staff_account の列に変換する
+func buildAccountPatch(req *UpdateStaffDetailRequest) map[string]any {
+	patch := map[string]any{}
+	if req.AccountName != nil {
+		patch["login_id"] = strings.TrimSpace(*req.AccountName)
+	}
+	if req.AccessType != nil {
+		patch["access_type"] = *req.AccessType
+	}
+	if req.AccessStatus != nil {
+		patch["access_status"] = *req.AccessStatus
+	}
+	if req.AdjustmentRate != nil {
+		patch["adjustment_rate"] = *req.AdjustmentRate
+	}
+	return patch
+}
+
+// checkAccountPermission アカウントの項目は auth.PermAccountAdmin の利用者のみ指定できる
+// 権限が無い場合は応答済み（401 / 403）で ok=false
+func checkAccountPermission(c *gin.Context, req *UpdateStaffDetailRequest) bool {
+	if len(buildAccountPatch(req)) == 0 || auth.Permitted(c, auth.PermAccountAdmin) {
+		return true
+	}
+	auth.RespondDenied(c)
+	return false
+}
+
+// validateAccountName ログインIDは空にできず、他のスタッフと重複できない（大文字小文字は区別しない）
+// staffID は更新対象（作成時は空）。問題なければ nil と空のコードを返す
+func validateAccountName(ctx context.Context, client *supa.Client, lang string, name *string, staffID string) ([]apierror.FieldError, string) {
+	if name == nil {
+		return nil, ""
+	}
+	v := strings.TrimSpace(*name)
+	if v == "" {
+		return []apierror.FieldError{validate.NewFieldError(lang, "accountName", "required", "")}, ""
+	}
+	q := url.Values{}
+	q.Set("select", "staff_id")
+	q.Set("login_id", "ilike."+supa.EscapeLike(v))
+	if staffID != "" {
+		q.Set("staff_id", "neq."+staffID)
+	}
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_account", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_account error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []struct {
+		StaffID string `json:"staff_id"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_account): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	if len(rows) > 0 {
+		return []apierror.FieldError{validate.NewFieldError(lang, "accountName", "unique", "")}, ""
+	}
+	return nil, ""
+}
+
+// updateStaffAccount スタッフのアカウントを更新する（ログインIDの重複は CodeDuplicate）
+func updateStaffAccount(ctx context.Context, client *supa.Client, staffID string, patch map[string]any) string {
+	q := url.Values{}
+	q.Set("staff_id", "eq."+staffID)
+	body, status, patchErr := client.Patch(ctx, "/rest/v1/staff_account", q, patch)
+	if patchErr != nil {
+		if status == http.StatusConflict {
+			return apierror.CodeDuplicate
+		}
+		log.Printf("DB_003: supabase patch staff_account error: %v", patchErr)
+		return apierror.CodeDBUpdate
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_account): %v", err)
+		return apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		// アカウントはスタッフの追加時にトリガーで作られる
+		log.Printf("DB_003: staff_account not found for staff %s", staffID)
+		return apierror.CodeDBUpdate
+	}
+	return ""
+}
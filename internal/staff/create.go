@@ -0,0 +1,296 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sfid 採番の再試行回数（一意制約違反＝他の作成と衝突した場合に次の番号で再挿入する）
+const sfidAllocateAttempts = 5
+
+// 同一プロセス内の同時作成は直列化して衝突を減らす（プロセス間は一意制約と再試行で保証する）
+var sfidMu sync.Mutex
+
+// CreateStaffHandler スタッフを新規作成するハンドラー
+// リクエストは UpdateStaffDetailRequest と同じ形。sfid 未指定時は次の空き番号を採番し、
+// car が指定され vehicleId が無い場合は staff_car も作成して紐付ける
+func CreateStaffHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req UpdateStaffDetailRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if !checkAccountPermission(c, &req) {
+		return
+	}
+	lang := apierror.Lang(c)
+	if req.LastName == nil || strings.TrimSpace(*req.LastName) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "lastName", "required", ""))
+	}
+	if req.FirstName == nil || strings.TrimSpace(*req.FirstName) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "firstName", "required", ""))
+	}
+	if len(fieldErrs) == 0 {
+		fieldErrs = validateSchedule(lang, req.Schedule, nil)
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	if fieldErrs, code := validateAccountName(ctx, client, lang, req.AccountName, ""); code != "" || len(fieldErrs) > 0 {
+		if code != "" {
+			apierror.Respond(c, code)
+		} else {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", fieldErrs)
+		}
+		return
+	}
+	masterFields, fieldErrs, code := masterPatch(ctx, client, lang, &req)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	if !checkVehicleAssignable(c, ctx, client, req.VehicleId, "") {
+		return
+	}
+
+	row := buildStaffPatch(&req)
+	for k, v := range masterFields {
+		row[k] = v
+	}
+	if _, ok := row["status"]; !ok {
+		row["status"] = true
+	}
+
+	// 1) 車両の作成（既存車両の指定がない場合のみ）
+	var createdCarID string
+	if req.Car != nil && (req.VehicleId == nil || strings.TrimSpace(*req.VehicleId) == "") {
+		carRow := buildCarRow(req.Car)
+		if p, _ := patchedPlate(nil, carRow); !checkPlateAvailable(c, ctx, client, p, "") {
+			return
+		}
+		carID, code := insertStaffCar(ctx, client, carRow)
+		if code == apierror.CodeDuplicate {
+			respondPlateDuplicate(c)
+			return
+		}
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		createdCarID = carID
+		row["vehicle"] = createdCarID
+	}
+
+	// 2) staff の挿入（sfid 未指定時は採番しながら再試行）
+	id, code := insertStaff(ctx, client, row, req.Sfid == nil || strings.TrimSpace(*req.Sfid) == "")
+	if code != "" {
+		if createdCarID != "" {
+			// 作成した車両が孤立しないよう取り消す
+			deleteStaffCar(ctx, client, createdCarID)
+		}
+		if code == apierror.CodeVehicleAssigned {
+			vehicleID, _ := row["vehicle"].(string)
+			respondVehicleTaken(c, ctx, client, vehicleID, "")
+			return
+		}
+		apierror.Respond(c, code)
+		return
+	}
+
+	// 3) アカウント（作成時にトリガーで既定値の行ができる）に指定された項目を反映
+	if accountPatch := buildAccountPatch(&req); len(accountPatch) > 0 {
+		if code := updateStaffAccount(ctx, client, id, accountPatch); code != "" {
+			// 挿入失敗時と同様に、作成したスタッフ（アカウントは連動して消える）と車両を取り消す
+			deleteStaffRow(ctx, client, id)
+			if createdCarID != "" {
+				deleteStaffCar(ctx, client, createdCarID)
+			}
+			apierror.Respond(c, code)
+			return
+		}
+	}
+
+	// 4) 詳細と同じ形で返却
+	created, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildStaffDetail(created)
+	etag.Set(c, resp.Version)
+	c.Header("Location", "/api/staff/"+id)
+	c.JSON(http.StatusCreated, resp)
+}
+
+// buildCarRow 車両リクエストを staff_car の列に変換する（指定された項目のみ。ナンバーは表記を揃える）
+func buildCarRow(car *UpdateCarRequest) map[string]any {
+	row := map[string]any{}
+	if car == nil {
+		return row
+	}
+	car.normalizePlate()
+	if car.CarType != nil {
+		row["car_type"] = *car.CarType
+	}
+	if car.Color != nil {
+		row["color"] = *car.Color
+	}
+	if car.Capacity != nil {
+		row["capacity"] = *car.Capacity
+	}
+	if car.Area != nil {
+		row["area"] = *car.Area
+	}
+	if car.ClassNumber != nil {
+		row["class_number"] = *car.ClassNumber
+	}
+	if car.Character != nil {
+		row["character"] = *car.Character
+	}
+	if car.Number != nil {
+		row["number"] = *car.Number
+	}
+	if car.IsETC != nil {
+		row["is_etc"] = *car.IsETC
+	}
+	clearEmptyPlate(row)
+	return row
+}
+
+// insertStaffCar staff_car を1件挿入して id を返す。失敗時はエラーコードを返す（ログ出力済み）
+func insertStaffCar(ctx context.Context, client *supa.Client, row map[string]any) (string, string) {
+	respBody, status, postErr := client.Post(ctx, "/rest/v1/staff_car", nil, row)
+	if postErr != nil {
+		if status == http.StatusConflict {
+			// ナンバーの一意制約（staff_car_plate_key）に反する
+			return "", apierror.CodeDuplicate
+		}
+		log.Printf("DB_003: supabase insert car error: %v", postErr)
+		return "", apierror.CodeDBUpdate
+	}
+	var cars []StaffCarDTO
+	if err := json.Unmarshal(respBody, &cars); err != nil || len(cars) == 0 {
+		log.Printf("DB_002: json decode error (car insert): %v", err)
+		return "", apierror.CodeDBDecode
+	}
+	return cars[0].ID, ""
+}
+
+// deleteStaffCar 作成済みの staff_car を取り消す（後続の staff 更新に失敗した場合）
+func deleteStaffCar(ctx context.Context, client *supa.Client, id string) {
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	if _, _, err := client.Delete(ctx, "/rest/v1/staff_car", q); err != nil {
+		log.Printf("DB_003: failed to roll back staff_car %s: %v", id, err)
+	}
+}
+
+// deleteStaffRow 作成済みの staff を取り消す（後続のアカウント更新に失敗した場合）
+func deleteStaffRow(ctx context.Context, client *supa.Client, id string) {
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	if _, _, err := client.Delete(ctx, "/rest/v1/staff", q); err != nil {
+		log.Printf("DB_003: failed to roll back staff %s: %v", id, err)
+	}
+}
+
+// insertStaff staff を1件挿入して id を返す。失敗時はエラーコードを返す（ログ出力済み）
+// allocate=true の場合は sfid を採番し、一意制約違反（他の作成と同じ番号を取った）なら番号を取り直す
+func insertStaff(ctx context.Context, client *supa.Client, row map[string]any, allocate bool) (string, string) {
+	if allocate {
+		sfidMu.Lock()
+		defer sfidMu.Unlock()
+	}
+	for attempt := 0; attempt < sfidAllocateAttempts; attempt++ {
+		if allocate {
+			next, code := nextFreeSfid(ctx, client)
+			if code != "" {
+				return "", code
+			}
+			row["sfid"] = next
+		}
+		respBody, status, postErr := client.Post(ctx, "/rest/v1/staff", nil, row)
+		if postErr != nil {
+			if status == http.StatusConflict {
+				if isVehicleTaken(respBody) {
+					return "", apierror.CodeVehicleAssigned
+				}
+				if allocate {
+					log.Printf("DB_409: sfid %v already taken, retrying", row["sfid"])
+					continue
+				}
+				return "", apierror.CodeDuplicate
+			}
+			log.Printf("DB_003: supabase insert staff error: %v", postErr)
+			return "", apierror.CodeDBUpdate
+		}
+		var rows []StaffDTO
+		if err := json.Unmarshal(respBody, &rows); err != nil || len(rows) == 0 {
+			log.Printf("DB_002: json decode error (staff insert): %v", err)
+			return "", apierror.CodeDBDecode
+		}
+		return rows[0].ID, ""
+	}
+	log.Printf("DB_409: gave up allocating sfid after %d attempts", sfidAllocateAttempts)
+	return "", apierror.CodeDuplicate
+}
+
+// nextFreeSfid 現在の最大 sfid の次の番号を返す
+func nextFreeSfid(ctx context.Context, client *supa.Client) (int, string) {
+	q := url.Values{}
+	q.Set("select", "sfid")
+	q.Set("sfid", "not.is.null")
+	q.Set("order", "sfid.desc")
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		return 0, apierror.CodeDBInit
+	}
+	var rows []struct {
+		SFID *int `json:"sfid"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return 0, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 || rows[0].SFID == nil {
+		return 1, ""
+	}
+	return *rows[0].SFID + 1, ""
+}
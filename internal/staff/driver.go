@@ -0,0 +1,338 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 送迎の所要時間（分）。minutes 省略時は driverWindowDefault
+const (
+	driverWindowDefault = 60
+	driverWindowMax     = 720
+)
+
+// dispatchActiveStatuses 割り当て済みとみなす送迎の状態
+var dispatchActiveStatuses = []string{"scheduled", "in_progress"}
+
+type DriverVehicle struct {
+	ID          string  `json:"id"`
+	CarType     *string `json:"carType,omitempty"`
+	Area        *string `json:"area,omitempty"`
+	ClassNumber *string `json:"classNumber,omitempty"`
+	Character   *string `json:"character,omitempty"`
+	Number      *int    `json:"number,omitempty"`
+	Plate       *string `json:"plate,omitempty"`
+	Capacity    *int    `json:"capacity,omitempty"`
+	IsETC       bool    `json:"isEtc"`
+	// at の日の時点で切れている車検・保険（shaken / compulsory_insurance / voluntary_insurance）
+	Lapsed          []string `json:"lapsed,omitempty"`
+	InsuranceLapsed bool     `json:"insuranceLapsed"` // 自賠責保険・任意保険のどちらかが切れている
+}
+
+// DriverCandidate 送迎を割り当てられるドライバー
+type DriverCandidate struct {
+	ID           string        `json:"id"`
+	SFID         string        `json:"sfid"`
+	Name         string        `json:"name"`
+	AreaDivision *string       `json:"areaDivision,omitempty"`
+	AreaMatch    bool          `json:"areaMatch"`           // 車両のエリアまたは所属エリアが area と一致
+	FreeSeats    *int          `json:"freeSeats,omitempty"` // 定員 − seats（定員未登録は省略）
+	Vehicle      DriverVehicle `json:"vehicle"`
+	Shift        RosterShift   `json:"shift"` // at から until を含む勤務
+}
+
+type DriverAvailabilityResponse struct {
+	At         string            `json:"at"`
+	Until      string            `json:"until"`
+	Area       string            `json:"area,omitempty"`
+	Seats      int               `json:"seats"`
+	Total      int               `json:"total"`
+	Candidates []DriverCandidate `json:"candidates"`
+}
+
+// driverSelect ドライバー検索で使う列（車両は必須のため inner join）
+var driverSelect = strings.Join([]string{
+	"id", "sfid", "first_name", "last_name", "area_division", "status",
+	"job_description", "joining_date", "resignation_date", "display_order",
+	"staff_car:vehicle!inner(id,car_type,capacity,area,class_number,character,number,is_etc)",
+	"staff_shift_pattern(weekday,start_time,end_time)",
+	"staff_shift_override(date,kind,start_time,end_time)",
+	"staff_qualification(type,expires_on)",
+}, ",")
+
+// GetAvailableDriversHandler 送迎に割り当てられるドライバーを返す（GET /api/drivers/available）
+//
+//	at=2026-10-18T23:30   送迎の開始日時（タイムゾーン省略時は JST。省略時は現在）
+//	minutes=60            送迎の所要時間（分）。at から minutes の間に他の送迎が入っているドライバーは除く
+//	area=京都             優先するエリア（車両のエリアまたは所属エリア）
+//	seats=3               乗車人数（定員が足りない車両は除く）
+//	etc=true|false        ETC の有無で絞り込む
+//
+// 職種が driver で車両が紐付いており、at から minutes の間ずっと勤務中のスタッフが対象
+// 運転免許が登録されていて at の日にすべて期限切れのドライバーは除く（送迎の割り当ても DB で拒否される）
+// 車検・保険が切れている車両は vehicle.lapsed に印を付ける（除かない）
+// 保険の切れている車両を後ろに、エリアが一致するドライバーを先に、同じ場合は空席の多い順に並べる
+func GetAvailableDriversHandler(c *gin.Context) {
+	lang := apierror.Lang(c)
+	var fieldErrs []apierror.FieldError
+
+	at := time.Now().In(jst)
+	if v := strings.TrimSpace(c.Query("at")); v != "" {
+		t, ok := parseRosterTime(v)
+		if !ok {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "at", "datetime", ""))
+		}
+		at = t
+	}
+	minutes := driverWindowDefault
+	if v := strings.TrimSpace(c.Query("minutes")); v != "" {
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil || n < 1:
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "minutes", "min", "1"))
+		case n > driverWindowMax:
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "minutes", "max", strconv.Itoa(driverWindowMax)))
+		default:
+			minutes = n
+		}
+	}
+	seats := 0
+	if v := strings.TrimSpace(c.Query("seats")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "seats", "min", "1"))
+		}
+		seats = n
+	}
+	var etc *bool
+	switch c.Query("etc") {
+	case "":
+	case "true", "false":
+		v := c.Query("etc") == "true"
+		etc = &v
+	default:
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "etc", "oneof", "true false"))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	area := strings.TrimSpace(c.Query("area"))
+	until := at.Add(time.Duration(minutes) * time.Minute)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", driverSelect)
+	q.Add("staff_shift_override.date", "gte."+dayStart(at).AddDate(0, 0, -1).Format("2006-01-02"))
+	q.Add("staff_shift_override.date", "lte."+dayStart(at).Format("2006-01-02"))
+	q.Set("staff_qualification.type", "in.("+strings.Join(driverLicenseTypes, ",")+")")
+	if etc != nil {
+		if *etc {
+			q.Set("staff_car.is_etc", "is.true")
+		} else {
+			q.Set("staff_car.is_etc", "not.is.true")
+		}
+	}
+	q.Set("order", "display_order.asc.nullslast,sfid.asc")
+	q.Set("limit", "2000")
+
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []StaffDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+
+	// 送迎の間ずっと勤務中（1つの勤務が [at, until) を含む）のドライバーに絞ってから割り当て済みを除く
+	var drivers []StaffDTO
+	var shifts []RosterShift
+	for _, s := range rows {
+		if s.StaffCar == nil || !isDriver(s) || s.licenseExpiredOn(dayStart(at)) {
+			continue
+		}
+		shift, ok := s.shiftCovering(at, until)
+		if !ok {
+			continue
+		}
+		drivers = append(drivers, s)
+		shifts = append(shifts, shift)
+	}
+	ids := make([]string, 0, len(drivers))
+	for _, s := range drivers {
+		ids = append(ids, s.ID)
+	}
+	busy, code := busyDrivers(ctx, client, ids, at, until)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	candidates := make([]DriverCandidate, 0)
+	for i, s := range drivers {
+		if busy[s.ID] {
+			continue
+		}
+		cand := driverCandidate(s, shifts[i], area, seats)
+		if seats > 0 && (cand.FreeSeats == nil || *cand.FreeSeats < 0) {
+			continue
+		}
+		candidates = append(candidates, cand)
+	}
+
+	carIDs := make([]string, 0, len(candidates))
+	for _, cand := range candidates {
+		carIDs = append(carIDs, cand.Vehicle.ID)
+	}
+	lapsed, code := lapsedVehicles(ctx, client, carIDs, dayStart(at))
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	for i := range candidates {
+		v := &candidates[i].Vehicle
+		v.Lapsed = lapsed[v.ID]
+		for _, k := range v.Lapsed {
+			if containsString(insuranceKinds, k) {
+				v.InsuranceLapsed = true
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Vehicle.InsuranceLapsed != b.Vehicle.InsuranceLapsed {
+			return b.Vehicle.InsuranceLapsed
+		}
+		if a.AreaMatch != b.AreaMatch {
+			return a.AreaMatch
+		}
+		if (a.FreeSeats == nil) != (b.FreeSeats == nil) {
+			return a.FreeSeats != nil // 定員未登録は後ろ
+		}
+		if a.FreeSeats != nil && *a.FreeSeats != *b.FreeSeats {
+			return *a.FreeSeats > *b.FreeSeats
+		}
+		return false
+	})
+
+	c.JSON(http.StatusOK, DriverAvailabilityResponse{
+		At:         at.Format(time.RFC3339),
+		Until:      until.Format(time.RFC3339),
+		Area:       area,
+		Seats:      seats,
+		Total:      len(candidates),
+		Candidates: candidates,
+	})
+}
+
+// busyDrivers staffIDs のうち [from, to) と重なる送迎が割り当て済みのスタッフ
+// 候補は数千人になり得るため、ID を URL に並べず RPC の本文で渡す
+func busyDrivers(ctx context.Context, client *supa.Client, staffIDs []string, from, to time.Time) (map[string]bool, string) {
+	busy := make(map[string]bool)
+	if len(staffIDs) == 0 {
+		return busy, ""
+	}
+	body, _, rpcErr := client.RPC(ctx, "busy_dispatch_staff", map[string]any{
+		"p_staff_ids": staffIDs,
+		"p_from":      from.Format(time.RFC3339),
+		"p_to":        to.Format(time.RFC3339),
+		"p_statuses":  dispatchActiveStatuses,
+	})
+	if rpcErr != nil {
+		log.Printf("DB_001: busy_dispatch_staff error: %v body=%s", rpcErr, string(body))
+		return nil, apierror.CodeDBInit
+	}
+	var rows []struct {
+		StaffID string `json:"staff_id"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (busy_dispatch_staff): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	for _, r := range rows {
+		busy[r.StaffID] = true
+	}
+	return busy, ""
+}
+
+// shiftCovering [from, to) をすべて含む勤務（出勤日の時点で在籍している日のみ）
+// 途中で終わる・途中から始まる勤務では送迎を任せられないため、1つの勤務で全体を含む必要がある
+func (s StaffDTO) shiftCovering(from, to time.Time) (RosterShift, bool) {
+	for _, sh := range s.shiftsBetween(from, to) {
+		start, err1 := time.Parse(time.RFC3339, sh.Start)
+		end, err2 := time.Parse(time.RFC3339, sh.End)
+		if err1 == nil && err2 == nil && !start.After(from) && !end.Before(to) {
+			return sh, true
+		}
+	}
+	return RosterShift{}, false
+}
+
+// isDriver 職種に driver を含むか
+func isDriver(s StaffDTO) bool {
+	for _, jt := range mapJobTypes(s.JobDescription) {
+		if jt == "driver" {
+			return true
+		}
+	}
+	return false
+}
+
+func driverCandidate(s StaffDTO, shift RosterShift, area string, seats int) DriverCandidate {
+	car := s.StaffCar
+	cand := DriverCandidate{
+		ID:           s.ID,
+		SFID:         coalesce(toStringPtrFromIntPtr(s.SFID), ""),
+		Name:         strings.TrimSpace(coalesce(s.LastName, "") + " " + coalesce(s.FirstName, "")),
+		AreaDivision: s.AreaDivision,
+		Vehicle: DriverVehicle{
+			ID:          car.ID,
+			CarType:     car.CarType,
+			Area:        car.Area,
+			ClassNumber: car.ClassNumber,
+			Character:   car.Character,
+			Number:      car.Number,
+			Plate:       car.plateLabel(),
+			Capacity:    car.Capacity,
+			IsETC:       car.IsETC != nil && *car.IsETC,
+		},
+		Shift: shift,
+	}
+	if area != "" {
+		cand.AreaMatch = strings.TrimSpace(coalesce(car.Area, "")) == area ||
+			strings.TrimSpace(coalesce(s.AreaDivision, "")) == area
+	}
+	if car.Capacity != nil {
+		free := *car.Capacity - seats
+		cand.FreeSeats = &free
+	}
+	return cand
+}
@@ -1,52 +1,97 @@
 package staff
 
+import (
+	area "nissyo/internal/area"
+	group "nissyo/internal/group"
+	position "nissyo/internal/position"
+)
+
 type StaffCarDTO struct {
-	ID        string  `json:"id"`
-	CarType   *string `json:"car_type"`
-	Color     *string `json:"color"`
-	Capacity  *int    `json:"capacity"`
-	Area      *string `json:"area"`
-	Character *string `json:"character"`
-	Number    *int    `json:"number"`
-	IsETC     *bool   `json:"is_etc"`
+	ID          string  `json:"id"`
+	CarType     *string `json:"car_type"`
+	Color       *string `json:"color"`
+	Capacity    *int    `json:"capacity"`
+	Area        *string `json:"area"`
+	ClassNumber *string `json:"class_number"`
+	Character   *string `json:"character"`
+	Number      *int    `json:"number"`
+	IsETC       *bool   `json:"is_etc"`
 }
 
 type StaffDTO struct {
-	ID                string       `json:"id"`
-	SFID              *int         `json:"sfid"`
-	FirstName         *string      `json:"first_name"`
-	LastName          *string      `json:"last_name"`
-	FirstNameFurigana *string      `json:"first_name_furigana"`
-	LastNameFurigana  *string      `json:"last_name_furigana"`
-	AreaDivision      *string      `json:"area_division"`
-	Group             *string      `json:"group"`
-	Status            *bool        `json:"status"`
-	BathTowel         *int         `json:"bath_towel"`
-	Equipment         *int         `json:"equipment"`
-	EmploymentType    *string      `json:"employment_type"`
-	JobDescription    *string      `json:"job_description"`
-	Position          *string      `json:"position"`
-	JoiningDate       *string      `json:"joining_date"`
-	ResignationDate   *string      `json:"resignation_date"`
-	PhoneNumber       *string      `json:"phone_number"`
-	MobileEmail       *string      `json:"mobile_email_address"`
-	PcEmail           *string      `json:"pc_email_address"`
-	Remarks           *string      `json:"remarks"`
-	MonStart          *string      `json:"mon_start"`
-	MonEnd            *string      `json:"mon_end"`
-	TueStart          *string      `json:"tue_start"`
-	TueEnd            *string      `json:"tue_end"`
-	WedStart          *string      `json:"wed_start"`
-	WedEnd            *string      `json:"wed_end"`
-	ThuStart          *string      `json:"thu_start"`
-	ThuEnd            *string      `json:"thu_end"`
-	FriStart          *string      `json:"fri_start"`
-	FriEnd            *string      `json:"fri_end"`
-	SatStart          *string      `json:"sat_start"`
-	SatEnd            *string      `json:"sat_end"`
-	SunStart          *string      `json:"sun_start"`
-	SunEnd            *string      `json:"sun_end"`
-	CreatedAt         *string      `json:"created_at"`
-	UpdatedAt         *string      `json:"updated_at"`
-	StaffCar          *StaffCarDTO `json:"staff_car"`
+	ID                string           `json:"id"`
+	SFID              *int             `json:"sfid"`
+	FirstName         *string          `json:"first_name"`
+	LastName          *string          `json:"last_name"`
+	FirstNameFurigana *string          `json:"first_name_furigana"`
+	LastNameFurigana  *string          `json:"last_name_furigana"`
+	AreaDivision      *string          `json:"area_division"`
+	GroupNo           *int             `json:"group_no"`
+	Status            *bool            `json:"status"`
+	BathTowel         *int             `json:"bath_towel"`
+	Equipment         *int             `json:"equipment"`
+	EmploymentType    *string          `json:"employment_type"`
+	JobDescription    *string          `json:"job_description"`
+	PositionID        *string          `json:"position_id"`
+	JoiningDate       *string          `json:"joining_date"`
+	ResignationDate   *string          `json:"resignation_date"`
+	PhoneNumber       *string          `json:"phone_number"`
+	MobileEmail       *string          `json:"mobile_email_address"`
+	PcEmail           *string          `json:"pc_email_address"`
+	Remarks           *string          `json:"remarks"`
+	MonStart          *string          `json:"mon_start"`
+	MonEnd            *string          `json:"mon_end"`
+	TueStart          *string          `json:"tue_start"`
+	TueEnd            *string          `json:"tue_end"`
+	WedStart          *string          `json:"wed_start"`
+	WedEnd            *string          `json:"wed_end"`
+	ThuStart          *string          `json:"thu_start"`
+	ThuEnd            *string          `json:"thu_end"`
+	FriStart          *string          `json:"fri_start"`
+	FriEnd            *string          `json:"fri_end"`
+	SatStart          *string          `json:"sat_start"`
+	SatEnd            *string          `json:"sat_end"`
+	SunStart          *string          `json:"sun_start"`
+	SunEnd            *string          `json:"sun_end"`
+	DisplayOrder      *int             `json:"display_order"`
+	CreatedAt         *string          `json:"created_at"`
+	UpdatedAt         *string          `json:"updated_at"`
+	StaffCar          *StaffCarDTO     `json:"staff_car"`
+	Account           *StaffAccountDTO `json:"staff_account,omitempty"`
+	// 役職マスタの埋め込み（positionSelect）。履歴のスナップショットは attachPositions で補う
+	Position *position.PositionDTO `json:"position,omitempty"`
+	// 地域区分・グループマスタの埋め込み（areaSelect / groupSelect）
+	Area  *area.AreaDTO   `json:"area,omitempty"`
+	Group *group.GroupDTO `json:"group,omitempty"`
+	// 埋め込みで取得した場合のみ値が入る（未取得は nil、勤務なしは空）
+	ShiftPatterns  []ShiftPatternDTO  `json:"staff_shift_pattern,omitempty"`
+	ShiftOverrides []ShiftOverrideDTO `json:"staff_shift_override,omitempty"`
+	Qualifications []QualificationDTO `json:"staff_qualification,omitempty"`
+}
+
+// StaffAccountDTO staff_account の行（スタッフ1人に1件）
+type StaffAccountDTO struct {
+	LoginID        string  `json:"login_id"`
+	AccessType     string  `json:"access_type"`   // admin / manager / accounting_manager / dispatcher / staff
+	AccessStatus   string  `json:"access_status"` // active / suspended
+	AdjustmentRate float64 `json:"adjustment_rate"`
+}
+
+// ShiftPatternDTO staff_shift_pattern の行（weekday は 0: 日曜 〜 6: 土曜）
+type ShiftPatternDTO struct {
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// ShiftOverrideDTO staff_shift_override の行
+type ShiftOverrideDTO struct {
+	ID        string  `json:"id"`
+	StaffID   string  `json:"staff_id,omitempty"`
+	Date      string  `json:"date"`
+	Kind      string  `json:"kind"` // off / extra
+	StartTime *string `json:"start_time"`
+	EndTime   *string `json:"end_time"`
+	Note      *string `json:"note"`
+	CreatedAt *string `json:"created_at,omitempty"`
 }
@@ -7,19 +7,20 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	position "nissyo/internal/position"
 	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
 
 	"github.com/gin-gonic/gin"
 )
 
-type ErrorResponse struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
-}
-
 func maskPhone(phone *string) *string {
 	if phone == nil {
 		return nil
@@ -33,6 +34,23 @@ func maskPhone(phone *string) *string {
 	return &masked
 }
 
+// maskEmail メールアドレスを先頭1文字とドメインだけ残して伏せる（例: t***@example.com）
+func maskEmail(email *string) *string {
+	if email == nil {
+		return nil
+	}
+	e := strings.TrimSpace(*email)
+	if e == "" {
+		return &e
+	}
+	masked := "****"
+	if at := strings.LastIndex(e, "@"); at > 0 {
+		_, size := utf8.DecodeRuneInString(e)
+		masked = e[:size] + "***" + e[at:]
+	}
+	return &masked
+}
+
 func GetStaffHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
@@ -41,14 +59,14 @@ func GetStaffHandler(c *gin.Context) {
 	if err != nil {
 		// 環境変数の詳細値はログに出さない
 		log.Printf("DB_001: failed to init supabase client: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "backend initialization error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
 	q := url.Values{}
 	q.Set("select", strings.Join([]string{
 		"id", "sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
-		"area_division", "group", "status", "employment_type", "job_description", "position",
+		"area_division", "group_no", "status", "employment_type", "job_description", "position_id",
 		"joining_date", "resignation_date", "phone_number", "remarks",
 		"mon_start", "mon_end",
 		"tue_start", "tue_end",
@@ -58,7 +76,8 @@ func GetStaffHandler(c *gin.Context) {
 		"sat_start", "sat_end",
 		"sun_start", "sun_end",
 		"created_at", "updated_at",
-		"staff_car:vehicle(id,car_type,area,character,number,is_etc)",
+		"staff_car:vehicle(id,car_type,area,class_number,character,number,is_etc)",
+		positionSelect, areaSelect, groupSelect,
 	}, ","))
 	q.Set("order", "created_at.desc")
 	q.Set("limit", "100")
@@ -66,14 +85,14 @@ func GetStaffHandler(c *gin.Context) {
 	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
 	if getErr != nil {
 		log.Printf("DB_001: supabase get error: %v", getErr)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "database fetch error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
 	var rows []StaffDTO
 	if err := json.Unmarshal(body, &rows); err != nil {
 		log.Printf("DB_002: json decode error: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_002", Message: "response decode error"})
+		apierror.Respond(c, apierror.CodeDBDecode)
 		return
 	}
 
@@ -94,7 +113,9 @@ type StaffLedgerRecord struct {
 	LastNameKana     *string  `json:"lastNameKana,omitempty"`
 	FirstNameKana    *string  `json:"firstNameKana,omitempty"`
 	AreaDivision     *string  `json:"areaDivision,omitempty"`
-	Group            *string  `json:"group,omitempty"`
+	AreaDivisionName *string  `json:"areaDivisionName,omitempty"` // Accept-Language に応じた地域区分名
+	GroupNo          *int     `json:"groupNo,omitempty"`
+	GroupName        *string  `json:"groupName,omitempty"`
 	EmploymentDate   string   `json:"employmentDate"`
 	RetirementDate   *string  `json:"retirementDate,omitempty"`
 	EmploymentType   string   `json:"employmentType"`
@@ -113,54 +134,64 @@ type StaffLedgerRecord struct {
 	Equipment        *int     `json:"equipment,omitempty"`
 	Remarks          *string  `json:"remarks"`
 	Vehicle          *struct {
-		ID        string  `json:"id"`
-		CarType   *string `json:"carType,omitempty"`
-		Color     *string `json:"color,omitempty"`
-		Capacity  *int    `json:"capacity,omitempty"`
-		Area      *string `json:"area,omitempty"`
-		Character *string `json:"character,omitempty"`
-		Number    *int    `json:"number,omitempty"`
-		IsETC     *bool   `json:"isETC,omitempty"`
+		ID          string  `json:"id"`
+		CarType     *string `json:"carType,omitempty"`
+		Color       *string `json:"color,omitempty"`
+		Capacity    *int    `json:"capacity,omitempty"`
+		Area        *string `json:"area,omitempty"`
+		ClassNumber *string `json:"classNumber,omitempty"`
+		Character   *string `json:"character,omitempty"`
+		Number      *int    `json:"number,omitempty"`
+		Plate       *string `json:"plate,omitempty"`
+		IsETC       *bool   `json:"isETC,omitempty"`
 	} `json:"vehicle,omitempty"`
 	Schedule *struct {
 		Mon *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"mon,omitempty"`
 		Tue *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"tue,omitempty"`
 		Wed *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"wed,omitempty"`
 		Thu *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"thu,omitempty"`
 		Fri *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"fri,omitempty"`
 		Sat *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"sat,omitempty"`
 		Sun *struct {
-			Work  bool   `json:"work"`
-			Start string `json:"start"`
-			End   string `json:"end"`
+			Work      bool   `json:"work"`
+			Start     string `json:"start"`
+			End       string `json:"end"`
+			Overnight bool   `json:"overnight"`
 		} `json:"sun,omitempty"`
 	} `json:"schedule,omitempty"`
-	CreatedAt string `json:"createdAt"`
-	UpdatedAt string `json:"updatedAt"`
+	Shifts    map[string][]ShiftTime `json:"shifts"` // 曜日ごとの全勤務（休みは空）
+	CreatedAt string                 `json:"createdAt"`
+	UpdatedAt string                 `json:"updatedAt"`
 }
 
 func coalesce(ptr *string, fallback string) string {
@@ -243,32 +274,6 @@ func mapJobTypes(job *string) []string {
 	return out
 }
 
-func mapRole(position *string) string {
-	p := strings.TrimSpace(strings.ToLower(coalesce(position, "")))
-	switch {
-	case strings.Contains(p, "会長"):
-		return "chairman"
-	case strings.Contains(p, "顧問"):
-		return "advisor"
-	case strings.Contains(p, "社長"):
-		return "president"
-	case strings.Contains(p, "統括"):
-		return "general_manager"
-	case strings.Contains(p, "管理部長"):
-		return "admin_manager"
-	case strings.Contains(p, "内勤部長"):
-		return "office_manager"
-	case strings.Contains(p, "女子"):
-		return "female_manager"
-	case strings.Contains(p, "pr"):
-		return "pr"
-	case strings.Contains(p, "マネージャ"):
-		return "manager"
-	default:
-		return "office_staff"
-	}
-}
-
 func GetStaffLedgerHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
@@ -276,14 +281,14 @@ func GetStaffLedgerHandler(c *gin.Context) {
 	client, err := supa.NewClientFromEnv()
 	if err != nil {
 		log.Printf("DB_001: failed to init supabase client: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "backend initialization error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
 	q := url.Values{}
 	q.Set("select", strings.Join([]string{
 		"id", "sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
-		"area_division", "group", "status", "employment_type", "job_description", "position",
+		"area_division", "group_no", "status", "employment_type", "job_description", "position_id",
 		"joining_date", "resignation_date", "phone_number", "mobile_email_address", "pc_email_address",
 		"bath_towel", "equipment", "remarks",
 		"mon_start", "mon_end",
@@ -293,193 +298,238 @@ func GetStaffLedgerHandler(c *gin.Context) {
 		"fri_start", "fri_end",
 		"sat_start", "sat_end",
 		"sun_start", "sun_end",
-		"created_at", "updated_at",
-		"staff_car:vehicle(id,car_type,color,capacity,area,character,number,is_etc)",
+		"display_order", "created_at", "updated_at",
+		"staff_car:vehicle(id,car_type,color,capacity,area,class_number,character,number,is_etc)",
+		"staff_shift_pattern(weekday,start_time,end_time)",
+		staffAccountSelect,
+		positionSelect, areaSelect, groupSelect,
 	}, ","))
-	q.Set("order", "created_at.asc")
-	q.Set("limit", "200")
-
-	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
-	if getErr != nil {
-		log.Printf("DB_001: supabase get error: %v", getErr)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "database fetch error"})
+	var positions position.Positions
+	var code string
+	if c.Query("role") != "" {
+		if positions, code = position.List(ctx, client); code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+	}
+	carInner, fieldErrs := applyLedgerFilters(c, q, positions)
+	sortRows, sortErr := applyLedgerSort(c, q)
+	if sortErr != nil {
+		fieldErrs = append(fieldErrs, *sortErr)
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
 		return
 	}
+	if carInner {
+		q.Set("select", strings.Replace(q.Get("select"), "staff_car:vehicle(", "staff_car:vehicle!inner(", 1))
+	}
 
 	var rows []StaffDTO
-	if err := json.Unmarshal(body, &rows); err != nil {
-		log.Printf("DB_002: json decode error: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_002", Message: "response decode error"})
+	if sortRows == nil {
+		q.Set("limit", strconv.Itoa(ledgerLimit))
+		rows, code = getLedgerRows(ctx, client, q)
+	} else {
+		// Go で並べ替える場合は条件に合う全件を取得してから先頭を返す
+		rows, code = getAllLedgerRows(ctx, client, q)
+		if code == "" {
+			sortRows(rows)
+			if len(rows) > ledgerLimit {
+				rows = rows[:ledgerLimit]
+			}
+		}
+	}
+	if code != "" {
+		apierror.Respond(c, code)
 		return
 	}
 
+	lang := apierror.Lang(c)
 	records := make([]StaffLedgerRecord, 0, len(rows))
 	for i, s := range rows {
 		last := coalesce(s.LastName, "")
 		first := coalesce(s.FirstName, "")
 
-		// 電話番号をマスク（個人情報保護）
+		// 電話番号・メールアドレスをマスク（個人情報保護）
 		maskedPhone := maskPhone(s.PhoneNumber)
 
 		// 車両情報をマッピング
 		var vehicle *struct {
-			ID        string  `json:"id"`
-			CarType   *string `json:"carType,omitempty"`
-			Color     *string `json:"color,omitempty"`
-			Capacity  *int    `json:"capacity,omitempty"`
-			Area      *string `json:"area,omitempty"`
-			Character *string `json:"character,omitempty"`
-			Number    *int    `json:"number,omitempty"`
-			IsETC     *bool   `json:"isETC,omitempty"`
+			ID          string  `json:"id"`
+			CarType     *string `json:"carType,omitempty"`
+			Color       *string `json:"color,omitempty"`
+			Capacity    *int    `json:"capacity,omitempty"`
+			Area        *string `json:"area,omitempty"`
+			ClassNumber *string `json:"classNumber,omitempty"`
+			Character   *string `json:"character,omitempty"`
+			Number      *int    `json:"number,omitempty"`
+			Plate       *string `json:"plate,omitempty"`
+			IsETC       *bool   `json:"isETC,omitempty"`
 		}
 		if s.StaffCar != nil {
 			vehicle = &struct {
-				ID        string  `json:"id"`
-				CarType   *string `json:"carType,omitempty"`
-				Color     *string `json:"color,omitempty"`
-				Capacity  *int    `json:"capacity,omitempty"`
-				Area      *string `json:"area,omitempty"`
-				Character *string `json:"character,omitempty"`
-				Number    *int    `json:"number,omitempty"`
-				IsETC     *bool   `json:"isETC,omitempty"`
+				ID          string  `json:"id"`
+				CarType     *string `json:"carType,omitempty"`
+				Color       *string `json:"color,omitempty"`
+				Capacity    *int    `json:"capacity,omitempty"`
+				Area        *string `json:"area,omitempty"`
+				ClassNumber *string `json:"classNumber,omitempty"`
+				Character   *string `json:"character,omitempty"`
+				Number      *int    `json:"number,omitempty"`
+				Plate       *string `json:"plate,omitempty"`
+				IsETC       *bool   `json:"isETC,omitempty"`
 			}{
-				ID:        s.StaffCar.ID,
-				CarType:   s.StaffCar.CarType,
-				Color:     s.StaffCar.Color,
-				Capacity:  s.StaffCar.Capacity,
-				Area:      s.StaffCar.Area,
-				Character: s.StaffCar.Character,
-				Number:    s.StaffCar.Number,
-				IsETC:     s.StaffCar.IsETC,
+				ID:          s.StaffCar.ID,
+				CarType:     s.StaffCar.CarType,
+				Color:       s.StaffCar.Color,
+				Capacity:    s.StaffCar.Capacity,
+				Area:        s.StaffCar.Area,
+				ClassNumber: s.StaffCar.ClassNumber,
+				Character:   s.StaffCar.Character,
+				Number:      s.StaffCar.Number,
+				Plate:       s.StaffCar.plateLabel(),
+				IsETC:       s.StaffCar.IsETC,
 			}
 		}
 
-		// スケジュール情報をマッピング（hhmm関数を使用）
-		hhmmHelper := func(t *string, fallback string) string {
-			if t == nil || *t == "" {
-				return fallback
-			}
-			parts := strings.Split(*t, ":")
-			if len(parts) >= 2 {
-				return fmt.Sprintf("%s:%s", parts[0], parts[1])
-			}
-			return *t
-		}
+		// スケジュール情報をマッピング（勤務パターンから作る。退勤が出勤より前なら翌日退勤）
 
 		schedule := &struct {
 			Mon *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"mon,omitempty"`
 			Tue *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"tue,omitempty"`
 			Wed *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"wed,omitempty"`
 			Thu *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"thu,omitempty"`
 			Fri *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"fri,omitempty"`
 			Sat *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"sat,omitempty"`
 			Sun *struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			} `json:"sun,omitempty"`
 		}{}
 
-		// 各曜日のスケジュールを設定
-		if s.MonStart != nil && s.MonEnd != nil {
+		// 各曜日のスケジュールを設定（schedule は各曜日の最初の勤務、shifts はすべての勤務）
+		week := s.weeklyShifts()
+		if shifts := week["mon"]; len(shifts) > 0 {
 			schedule.Mon = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.MonStart, "09:00"),
-				End:   hhmmHelper(s.MonEnd, "18:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
-		if s.TueStart != nil && s.TueEnd != nil {
+		if shifts := week["tue"]; len(shifts) > 0 {
 			schedule.Tue = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.TueStart, "09:00"),
-				End:   hhmmHelper(s.TueEnd, "18:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
-		if s.WedStart != nil && s.WedEnd != nil {
+		if shifts := week["wed"]; len(shifts) > 0 {
 			schedule.Wed = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.WedStart, "09:00"),
-				End:   hhmmHelper(s.WedEnd, "18:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
-		if s.ThuStart != nil && s.ThuEnd != nil {
+		if shifts := week["thu"]; len(shifts) > 0 {
 			schedule.Thu = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.ThuStart, "09:00"),
-				End:   hhmmHelper(s.ThuEnd, "18:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
-		if s.FriStart != nil && s.FriEnd != nil {
+		if shifts := week["fri"]; len(shifts) > 0 {
 			schedule.Fri = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.FriStart, "09:00"),
-				End:   hhmmHelper(s.FriEnd, "18:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
-		if s.SatStart != nil && s.SatEnd != nil {
+		if shifts := week["sat"]; len(shifts) > 0 {
 			schedule.Sat = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.SatStart, "10:00"),
-				End:   hhmmHelper(s.SatEnd, "16:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
-		if s.SunStart != nil && s.SunEnd != nil {
+		if shifts := week["sun"]; len(shifts) > 0 {
 			schedule.Sun = &struct {
-				Work  bool   `json:"work"`
-				Start string `json:"start"`
-				End   string `json:"end"`
+				Work      bool   `json:"work"`
+				Start     string `json:"start"`
+				End       string `json:"end"`
+				Overnight bool   `json:"overnight"`
 			}{
-				Work:  true,
-				Start: hhmmHelper(s.SunStart, "00:00"),
-				End:   hhmmHelper(s.SunEnd, "00:00"),
+				Work:      true,
+				Start:     shifts[0].Start,
+				End:       shifts[0].End,
+				Overnight: shifts[0].Overnight,
 			}
 		}
 
@@ -491,35 +541,43 @@ func GetStaffLedgerHandler(c *gin.Context) {
 			LastNameKana:     s.LastNameFurigana,
 			FirstNameKana:    s.FirstNameFurigana,
 			AreaDivision:     s.AreaDivision,
-			Group:            s.Group,
+			AreaDivisionName: s.areaLabel(lang),
+			GroupNo:          s.GroupNo,
+			GroupName:        s.groupName(),
 			EmploymentDate:   parseDateOnly(s.JoiningDate),
-			RetirementDate:   s.ResignationDate,
+			RetirementDate:   retirementDate(s.ResignationDate),
 			EmploymentType:   mapEmploymentType(s.EmploymentType),
 			JobTypes:         mapJobTypes(s.JobDescription),
-			Role:             mapRole(s.Position),
+			Role:             s.roleKey(),
 			EmploymentStatus: mapEmploymentStatus(s.Status),
-			AdjustmentRate:   1.0,
-			DisplayOrder:     i + 1,
-			AccountName:      buildAccountName(last, first, s.SFID),
-			AccessType:       "staff",
-			AccessStatus:     "active",
+			DisplayOrder:     displayOrder(s.DisplayOrder, i),
 			PhoneNumber:      maskedPhone,
-			MobileEmail:      s.MobileEmail,
-			PcEmail:          s.PcEmail,
+			MobileEmail:      maskEmail(s.MobileEmail),
+			PcEmail:          maskEmail(s.PcEmail),
 			BathTowel:        s.BathTowel,
 			Equipment:        s.Equipment,
 			Remarks:          s.Remarks, // nilの場合はomitemptyでJSONに含まれない
 			Vehicle:          vehicle,
 			Schedule:         schedule,
+			Shifts:           week,
 			CreatedAt:        formatDateTimeLikeSample(s.CreatedAt),
 			UpdatedAt:        formatDateTimeLikeSample(s.UpdatedAt),
 		}
+		rec.AccountName, rec.AccessType, rec.AccessStatus, rec.AdjustmentRate = accountFields(s.Account)
 		records = append(records, rec)
 	}
 
 	c.JSON(http.StatusOK, records)
 }
 
+// displayOrder 保存された表示順（未採番の行は取得順の位置）
+func displayOrder(n *int, i int) int {
+	if n != nil {
+		return *n
+	}
+	return i + 1
+}
+
 func toStringPtrFromIntPtr(n *int) *string {
 	if n == nil {
 		return nil
@@ -539,6 +597,14 @@ func mapEmploymentType(t *string) string {
 	}
 }
 
+// retirementDate 退職日を YYYY-MM-DD にする（未設定なら nil）
+func retirementDate(iso *string) *string {
+	if d := parseDateOnly(iso); d != "" {
+		return &d
+	}
+	return nil
+}
+
 func mapEmploymentStatus(b *bool) string {
 	if b == nil {
 		return ""
@@ -546,85 +612,59 @@ func mapEmploymentStatus(b *bool) string {
 	if *b {
 		return "active"
 	}
-	return ""
-}
-
-func buildAccountName(last, first string, sfid *int) string {
-	if sfid != nil {
-		return fmt.Sprintf("staff_%03d", *sfid)
-	}
-	// fallback: romaji-like simple, removing spaces
-	name := strings.TrimSpace(last + "_" + first)
-	if name == "_" || name == "" {
-		return "staff_000"
-	}
-	return name
+	return "retired"
 }
 
 // --- Update (partial) ---
 type UpdateDay struct {
 	Work  *bool   `json:"work"`
-	Start *string `json:"start"`
-	End   *string `json:"end"`
+	Start *string `json:"start" binding:"omitempty,hhmm"`
+	End   *string `json:"end" binding:"omitempty,hhmm"`
 }
 
-type UpdateStaffDetailRequest struct {
-	Sfid             *string `json:"sfid"`
-	LastName         *string `json:"lastName"`
-	FirstName        *string `json:"firstName"`
-	LastNameKana     *string `json:"lastNameKana"`
-	FirstNameKana    *string `json:"firstNameKana"`
-	AreaDivision     *string `json:"areaDivision"`
-	EmploymentStatus *string `json:"employmentStatus"` // 'active' | ''
-	EmploymentDate   *string `json:"employmentDate"`   // YYYY-MM-DD
-	EmploymentType   *string `json:"employmentType"`   // 'employee' | 'part_time'
-	JobDriver        *bool   `json:"jobDriver"`
-	JobOffice        *bool   `json:"jobOffice"`
-	Role             *string `json:"role"`
-	PhoneNumber      *string `json:"phoneNumber"`
-	MobileEmail      *string `json:"mobileEmail"`
-	PcEmail          *string `json:"pcEmail"`
-	VehicleId        *string `json:"vehicleId"`
-	BathTowel        *int    `json:"bathTowel"`
-	Equipment        *int    `json:"equipment"`
-	Remarks          *string `json:"remarks"`
-	Car              *struct {
-		CarType   *string `json:"carType"`
-		Color     *string `json:"color"`
-		Capacity  *int    `json:"capacity"`
-		Area      *string `json:"area"`
-		Character *string `json:"character"`
-		Number    *int    `json:"number"`
-		IsETC     *bool   `json:"isETC"`
-	} `json:"car"`
-	Schedule map[string]UpdateDay `json:"schedule"`
+type UpdateCarRequest struct {
+	CarType     *string `json:"carType" binding:"omitempty,max=100"`
+	Color       *string `json:"color" binding:"omitempty,max=255"`
+	Capacity    *int    `json:"capacity" binding:"omitempty,min=1,max=99"`
+	Area        *string `json:"area" binding:"omitempty,plate_region"`       // ナンバーの地名
+	ClassNumber *string `json:"classNumber" binding:"omitempty,plate_class"` // ナンバーの分類番号
+	Character   *string `json:"character" binding:"omitempty,plate_kana"`    // ナンバーのひらがな
+	Number      *int    `json:"number" binding:"omitempty,min=1,max=9999"`   // ナンバーの一連指定番号
+	// ナンバーをまとめて指定する（例: 京都 500 あ 12-34。area〜number より優先。空文字でナンバーを未設定に戻す）
+	Plate *string `json:"plate" binding:"omitempty,plate"`
+	IsETC *bool   `json:"isETC"`
 }
 
-func roleKeyToPosition(role string) string {
-	switch role {
-	case "chairman":
-		return "会長"
-	case "advisor":
-		return "顧問"
-	case "president":
-		return "社長"
-	case "general_manager":
-		return "統括部長"
-	case "manager":
-		return "マネージャ"
-	case "admin_manager":
-		return "管理部長"
-	case "office_manager":
-		return "内勤部長"
-	case "female_manager":
-		return "女子管理責任"
-	case "office_staff":
-		return "内勤"
-	case "pr":
-		return "PR"
-	default:
-		return role
-	}
+type UpdateStaffDetailRequest struct {
+	Sfid             *string              `json:"sfid" binding:"omitempty,sfid"`
+	LastName         *string              `json:"lastName" binding:"omitempty,max=255"`
+	FirstName        *string              `json:"firstName" binding:"omitempty,max=255"`
+	LastNameKana     *string              `json:"lastNameKana" binding:"omitempty,max=255"`
+	FirstNameKana    *string              `json:"firstNameKana" binding:"omitempty,max=255"`
+	AreaDivision     *string              `json:"areaDivision" binding:"omitempty,max=255"`                     // 地域区分名（area.name）
+	GroupNo          *int                 `json:"groupNo" binding:"omitempty,min=0"`                            // グループナンバー（group.number。0 で未設定）
+	EmploymentStatus *string              `json:"employmentStatus" binding:"omitempty,oneof='' active retired"` // 'active' | 'retired' | ''
+	EmploymentDate   *string              `json:"employmentDate" binding:"omitempty,ymd"`                       // YYYY-MM-DD
+	RetirementDate   *string              `json:"retirementDate" binding:"omitempty,ymd"`                       // YYYY-MM-DD（resignation_date）
+	EmploymentType   *string              `json:"employmentType" binding:"omitempty,oneof=employee part_time"`
+	JobDriver        *bool                `json:"jobDriver"`
+	JobOffice        *bool                `json:"jobOffice"`
+	Role             *string              `json:"role" binding:"omitempty,master_key"` // 役職キー（position.key）
+	PhoneNumber      *string              `json:"phoneNumber" binding:"omitempty,phone"`
+	MobileEmail      *string              `json:"mobileEmail" binding:"omitempty,max=255,eq=|email"`
+	PcEmail          *string              `json:"pcEmail" binding:"omitempty,max=255,eq=|email"`
+	VehicleId        *string              `json:"vehicleId" binding:"omitempty,eq=|uuid"`
+	BathTowel        *int                 `json:"bathTowel" binding:"omitempty,min=0"`
+	Equipment        *int                 `json:"equipment" binding:"omitempty,min=0"`
+	Remarks          *string              `json:"remarks" binding:"omitempty,max=255"`
+	AccountName      *string              `json:"accountName" binding:"omitempty,login_id"` // staff_account.login_id
+	AccessType       *string              `json:"accessType" binding:"omitempty,oneof=admin manager accounting_manager dispatcher staff"`
+	AccessStatus     *string              `json:"accessStatus" binding:"omitempty,oneof=active suspended"`
+	AdjustmentRate   *float64             `json:"adjustmentRate" binding:"omitempty,min=0,max=9.99"`
+	Car              *UpdateCarRequest    `json:"car"`
+	Schedule         map[string]UpdateDay `json:"schedule" binding:"omitempty,dive,keys,oneof=mon tue wed thu fri sat sun,endkeys"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
 }
 
 func buildJobDescription(d *bool, o *bool) *string {
@@ -663,84 +703,30 @@ func currentValueBool(v any) bool {
 	return false
 }
 
-func UpdateStaffHandler(c *gin.Context) {
-	id := c.Param("id")
-	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Code: "VAL_001", Message: "missing id"})
-		return
+// dropMaskedContacts 詳細の応答で伏せた値をそのまま送り返したメールアドレスは変更なしとして扱う
+// （電話番号の伏せた表記は形式の検証で受け付けない）
+func (r *UpdateStaffDetailRequest) dropMaskedContacts(current StaffDTO) {
+	echoed := func(v, masked *string) bool {
+		return v != nil && masked != nil && *masked != "" && strings.TrimSpace(*v) == *masked
 	}
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 12*time.Second)
-	defer cancel()
-
-	client, err := supa.NewClientFromEnv()
-	if err != nil {
-		log.Printf("DB_001: failed to init supabase client: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "backend initialization error"})
-		return
-	}
-
-	var req UpdateStaffDetailRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("VAL_002: invalid body: %v", err)
-		c.JSON(http.StatusBadRequest, ErrorResponse{Code: "VAL_002", Message: "invalid request body"})
-		return
-	}
-
-	// 1) 現在値を取得
-	qget := url.Values{}
-	qget.Set("select", strings.Join([]string{
-		"id", "sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
-		"area_division", "status", "employment_type", "job_description", "position",
-		"joining_date", "phone_number", "mobile_email_address", "pc_email_address",
-		"mon_start", "mon_end", "tue_start", "tue_end", "wed_start", "wed_end",
-		"thu_start", "thu_end", "fri_start", "fri_end", "sat_start", "sat_end", "sun_start", "sun_end",
-		"staff_car:vehicle(id,car_type,color,capacity,area,character,number,is_etc)",
-	}, ","))
-	qget.Set("id", "eq."+id)
-	qget.Set("limit", "1")
-	body, _, getErr := client.Get(ctx, "/rest/v1/staff", qget)
-	if getErr != nil {
-		log.Printf("DB_001: supabase get error: %v", getErr)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "database fetch error"})
-		return
+	if echoed(r.MobileEmail, maskEmail(current.MobileEmail)) {
+		r.MobileEmail = nil
 	}
-	var rows []StaffDTO
-	if err := json.Unmarshal(body, &rows); err != nil {
-		log.Printf("DB_002: json decode error: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_002", Message: "response decode error"})
-		return
-	}
-	if len(rows) == 0 {
-		c.JSON(http.StatusNotFound, ErrorResponse{Code: "DB_404", Message: "staff not found"})
-		return
+	if echoed(r.PcEmail, maskEmail(current.PcEmail)) {
+		r.PcEmail = nil
 	}
-	current := rows[0]
+}
 
-	// 2) パッチを構築（差分比較せず、リクエストで受けた値をそのまま反映）
+// buildStaffPatch リクエストで指定された項目を staff テーブルの列に変換する（作成・更新で共用）
+func buildStaffPatch(req *UpdateStaffDetailRequest) map[string]any {
 	patch := map[string]any{}
 	// sfid
 	if req.Sfid != nil {
 		if v := strings.TrimSpace(*req.Sfid); v == "" {
 			patch["sfid"] = nil
-		} else {
-			// parse integer
-			digits := strings.TrimPrefix(strings.TrimPrefix(v, "+"), "-")
-			sign := 1
-			if strings.HasPrefix(v, "-") {
-				sign = -1
-			}
-			val := 0
-			valid := true
-			for _, ch := range digits {
-				if ch < '0' || ch > '9' {
-					valid = false
-					break
-				}
-				val = val*10 + int(ch-'0')
-			}
-			if valid {
-				patch["sfid"] = val * sign
-			}
+		} else if n, err := strconv.Atoi(v); err == nil {
+			// 形式は validate.BindJSON で検証済み
+			patch["sfid"] = n
 		}
 	}
 	// names
@@ -756,10 +742,7 @@ func UpdateStaffHandler(c *gin.Context) {
 	if req.FirstNameKana != nil {
 		patch["first_name_furigana"] = *req.FirstNameKana
 	}
-	// area
-	if req.AreaDivision != nil {
-		patch["area_division"] = *req.AreaDivision
-	}
+	// 役職・地域区分・グループはマスタの確認が要るため masterPatch で扱う
 	// status
 	if req.EmploymentStatus != nil {
 		patch["status"] = (*req.EmploymentStatus == "active")
@@ -771,6 +754,14 @@ func UpdateStaffHandler(c *gin.Context) {
 	if req.Equipment != nil {
 		patch["equipment"] = *req.Equipment
 	}
+	// resignation_date
+	if req.RetirementDate != nil {
+		if strings.TrimSpace(*req.RetirementDate) == "" {
+			patch["resignation_date"] = nil
+		} else {
+			patch["resignation_date"] = *req.RetirementDate
+		}
+	}
 	// joining_date
 	if req.EmploymentDate != nil {
 		if strings.TrimSpace(*req.EmploymentDate) == "" {
@@ -787,10 +778,6 @@ func UpdateStaffHandler(c *gin.Context) {
 	if jd := buildJobDescription(req.JobDriver, req.JobOffice); jd != nil {
 		patch["job_description"] = *jd
 	}
-	// role / position
-	if req.Role != nil {
-		patch["position"] = roleKeyToPosition(*req.Role)
-	}
 	// contacts
 	if req.PhoneNumber != nil {
 		patch["phone_number"] = *req.PhoneNumber
@@ -811,6 +798,14 @@ func UpdateStaffHandler(c *gin.Context) {
 	}
 	// schedule (times)
 	if req.Schedule != nil {
+		// 退勤が出勤より前の時刻は翌日退勤としてそのまま保存する（組み合わせは validateSchedule で確認済み）
+		// 空文字は値のクリア
+		timeValue := func(v string) any {
+			if v == "" {
+				return nil
+			}
+			return v
+		}
 		setTime := func(day string, upd UpdateDay) {
 			if upd.Work != nil && !*upd.Work {
 				patch[day+"_start"] = nil
@@ -818,10 +813,10 @@ func UpdateStaffHandler(c *gin.Context) {
 				return
 			}
 			if upd.Start != nil {
-				patch[day+"_start"] = *upd.Start
+				patch[day+"_start"] = timeValue(*upd.Start)
 			}
 			if upd.End != nil {
-				patch[day+"_end"] = *upd.End
+				patch[day+"_end"] = timeValue(*upd.End)
 			}
 		}
 		if v, ok := req.Schedule["mon"]; ok {
@@ -855,10 +850,95 @@ func UpdateStaffHandler(c *gin.Context) {
 			patch["vehicle"] = newVehicle
 		}
 	}
+	return patch
+}
+
+func UpdateStaffHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 12*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req UpdateStaffDetailRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	if !checkAccountPermission(c, &req) {
+		return
+	}
+
+	// 1) 現在値を取得し、クライアントが編集を始めた時点のバージョンと比較する
+	current, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if fieldErrs := validateSchedule(apierror.Lang(c), req.Schedule, &current); len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	if fieldErrs, code := validateAccountName(ctx, client, apierror.Lang(c), req.AccountName, current.ID); code != "" || len(fieldErrs) > 0 {
+		if code != "" {
+			apierror.Respond(c, code)
+		} else {
+			apierror.RespondWith(c, apierror.CodeDuplicate, "", fieldErrs)
+		}
+		return
+	}
+	masterFields, fieldErrs, code := masterPatch(ctx, client, apierror.Lang(c), &req)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, buildStaffDetail(current)) {
+		return
+	}
+	if current.StaffCar == nil || req.VehicleId == nil || strings.TrimSpace(*req.VehicleId) != current.StaffCar.ID {
+		if !checkVehicleAssignable(c, ctx, client, req.VehicleId, current.ID) {
+			return
+		}
+	}
+
+	// 2) パッチを構築（差分比較せず、リクエストで受けた値をそのまま反映）
+	req.dropMaskedContacts(current)
+	patch := buildStaffPatch(&req)
+	for k, v := range masterFields {
+		patch[k] = v
+	}
+	accountPatch := buildAccountPatch(&req)
 
 	// staff_car patch (if requested)
-	var carPatched bool
+	// 送信は staff の条件付き更新で競合がないことを確かめてから行う
+	var carPatch map[string]any
+	var carTarget string
 	if req.Car != nil {
+		req.Car.normalizePlate()
 		// determine target vehicle id
 		var targetVid *string
 		if req.VehicleId != nil && strings.TrimSpace(*req.VehicleId) != "" {
@@ -868,7 +948,8 @@ func UpdateStaffHandler(c *gin.Context) {
 			targetVid = &idCopy
 		}
 		if targetVid != nil {
-			carPatch := map[string]any{}
+			carPatch = map[string]any{}
+			carTarget = *targetVid
 			sameVehicle := current.StaffCar != nil && current.StaffCar.ID == *targetVid
 			setCarField := func(key string, newPtr any, curVal any) {
 				switch nv := newPtr.(type) {
@@ -894,23 +975,24 @@ func UpdateStaffHandler(c *gin.Context) {
 			}
 			// helpers current values
 			var cur *struct {
-				CarType, Color, Area, Character *string
-				Capacity, Number                *int
-				IsETC                           *bool
+				CarType, Color, Area, ClassNumber, Character *string
+				Capacity, Number                             *int
+				IsETC                                        *bool
 			}
 			if current.StaffCar != nil {
 				cur = &struct {
-					CarType, Color, Area, Character *string
-					Capacity, Number                *int
-					IsETC                           *bool
+					CarType, Color, Area, ClassNumber, Character *string
+					Capacity, Number                             *int
+					IsETC                                        *bool
 				}{
-					CarType:   current.StaffCar.CarType,
-					Color:     current.StaffCar.Color,
-					Area:      current.StaffCar.Area,
-					Character: current.StaffCar.Character,
-					Capacity:  current.StaffCar.Capacity,
-					Number:    current.StaffCar.Number,
-					IsETC:     current.StaffCar.IsETC,
+					CarType:     current.StaffCar.CarType,
+					Color:       current.StaffCar.Color,
+					Area:        current.StaffCar.Area,
+					ClassNumber: current.StaffCar.ClassNumber,
+					Character:   current.StaffCar.Character,
+					Capacity:    current.StaffCar.Capacity,
+					Number:      current.StaffCar.Number,
+					IsETC:       current.StaffCar.IsETC,
 				}
 			}
 			setCarField("car_type", req.Car.CarType, func() *string {
@@ -937,6 +1019,12 @@ func UpdateStaffHandler(c *gin.Context) {
 				}
 				return nil
 			}())
+			setCarField("class_number", req.Car.ClassNumber, func() *string {
+				if cur != nil {
+					return cur.ClassNumber
+				}
+				return nil
+			}())
 			setCarField("character", req.Car.Character, func() *string {
 				if cur != nil {
 					return cur.Character
@@ -955,57 +1043,95 @@ func UpdateStaffHandler(c *gin.Context) {
 				}
 				return nil
 			}())
+		}
+	}
 
-			if len(carPatch) > 0 {
-				qcar := url.Values{}
-				qcar.Set("id", "eq."+*targetVid)
-				if _, _, err := client.Patch(ctx, "/rest/v1/staff_car", qcar, carPatch); err != nil {
-					log.Printf("DB_003: supabase patch car error: %v", err)
-					c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_003", Message: "database update error (car)"})
-					return
-				}
-				carPatched = true
+	if len(carPatch) > 0 {
+		// ナンバーの重複（別の車両を指定した場合はその車両の登録内容に重ねて確かめる）
+		clearEmptyPlate(carPatch)
+		base := current.StaffCar
+		if base == nil || base.ID != carTarget {
+			target, found, code := fetchVehicle(ctx, client, carTarget)
+			if code != "" {
+				apierror.Respond(c, code)
+				return
 			}
+			if found {
+				base = &target.StaffCarDTO
+			}
+		}
+		if p, changed := patchedPlate(base, carPatch); changed && !checkPlateAvailable(c, ctx, client, p, carTarget) {
+			return
 		}
 	}
 
-	if len(patch) == 0 && !carPatched {
-		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes"})
+	if len(patch) == 0 && len(carPatch) == 0 && len(accountPatch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
 		return
 	}
 
-	// 3) staff パッチ送信（差分がある場合のみ）
-	var staffUpdated []map[string]any
-	if len(patch) > 0 {
-		qpatch := url.Values{}
-		qpatch.Set("id", "eq."+id)
-		respBody, _, patchErr := client.Patch(ctx, "/rest/v1/staff", qpatch, patch)
-		if patchErr != nil {
-			log.Printf("DB_003: supabase patch error: %v", patchErr)
-			c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_003", Message: "database update error"})
+	// 3) staff パッチ送信（読み込んだ時点の updated_at と一致する場合のみ更新）
+	// 車両・アカウントのみの変更でも staff 行を更新して updated_at を進め、同時編集を検知できるようにする
+	staffPatch := patch
+	if len(staffPatch) == 0 {
+		staffPatch = map[string]any{"updated_at": "now"} // トリガーで now() に上書きされる
+	}
+	staffUpdated, ok := patchStaffIfUnchanged(c, ctx, client, current, staffPatch)
+	if !ok {
+		return
+	}
+
+	// 4) staff_car パッチ送信
+	if len(carPatch) > 0 {
+		qcar := url.Values{}
+		qcar.Set("id", "eq."+carTarget)
+		if _, status, err := client.Patch(ctx, "/rest/v1/staff_car", qcar, carPatch); err != nil {
+			if status == http.StatusConflict {
+				respondPlateDuplicate(c)
+				return
+			}
+			log.Printf("DB_003: supabase patch car error: %v", err)
+			apierror.Respond(c, apierror.CodeDBUpdate)
+			return
+		}
+	}
+
+	// 5) staff_account パッチ送信
+	if len(accountPatch) > 0 {
+		if code := updateStaffAccount(ctx, client, current.ID, accountPatch); code != "" {
+			apierror.Respond(c, code)
 			return
 		}
-		// PostgREST returns an array with updated row when Prefer=return=representation
-		_ = json.Unmarshal(respBody, &staffUpdated)
 	}
 
+	var newVersion string
+	if v, ok := staffUpdated["updated_at"].(string); ok {
+		newVersion = etag.FromUpdatedAt(&v)
+	}
+	etag.Set(c, newVersion)
+
 	c.JSON(http.StatusOK, gin.H{
 		"updated":       1,
 		"changedFields": patch,
-		"row":           staffUpdated,
+		"accountFields": accountPatch,
+		"row":           []map[string]any{staffUpdated},
+		"version":       newVersion,
 	})
 }
 
 // ------- Staff Detail -------
 type DaySchedule struct {
-	Work  bool   `json:"work"`
-	Start string `json:"start"`
-	End   string `json:"end"`
+	Work      bool   `json:"work"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Overnight bool   `json:"overnight"` // 退勤が翌日
 }
 
 type StaffDetailResponse struct {
+	ID               string  `json:"id"`
 	EmploymentStatus string  `json:"employmentStatus"`
 	EmploymentDate   string  `json:"employmentDate"`
+	RetirementDate   *string `json:"retirementDate,omitempty"`
 	EmploymentType   string  `json:"employmentType"`
 	JobDriver        bool    `json:"jobDriver"`
 	JobOffice        bool    `json:"jobOffice"`
@@ -1020,37 +1146,37 @@ type StaffDetailResponse struct {
 	LastNameKana     *string `json:"lastNameKana,omitempty"`
 	FirstNameKana    *string `json:"firstNameKana,omitempty"`
 	AreaDivision     *string `json:"areaDivision,omitempty"`
+	GroupNo          *int    `json:"groupNo,omitempty"`
+	GroupName        *string `json:"groupName,omitempty"`
 	PhoneNumber      *string `json:"phoneNumber,omitempty"`
 	MobileEmail      *string `json:"mobileEmail,omitempty"`
 	PcEmail          *string `json:"pcEmail,omitempty"`
 	Remarks          *string `json:"remarks"`
+	AccountName      string  `json:"accountName"`
+	AccessType       string  `json:"accessType"`
+	AccessStatus     string  `json:"accessStatus"`
+	AdjustmentRate   float64 `json:"adjustmentRate"`
 	Car              *struct {
-		CarType   *string `json:"carType,omitempty"`
-		Color     *string `json:"color,omitempty"`
-		Capacity  *int    `json:"capacity,omitempty"`
-		Area      *string `json:"area,omitempty"`
-		Character *string `json:"character,omitempty"`
-		Number    *int    `json:"number,omitempty"`
-		IsETC     *bool   `json:"isETC,omitempty"`
+		CarType     *string `json:"carType,omitempty"`
+		Color       *string `json:"color,omitempty"`
+		Capacity    *int    `json:"capacity,omitempty"`
+		Area        *string `json:"area,omitempty"`
+		ClassNumber *string `json:"classNumber,omitempty"`
+		Character   *string `json:"character,omitempty"`
+		Number      *int    `json:"number,omitempty"`
+		Plate       *string `json:"plate,omitempty"`
+		IsETC       *bool   `json:"isETC,omitempty"`
 	} `json:"car,omitempty"`
-	Schedule interface{} `json:"schedule"` // map[string]DaySchedule
-}
-
-func hhmm(t *string, fallback string) string {
-	if t == nil || *t == "" {
-		return fallback
-	}
-	parts := strings.Split(*t, ":")
-	if len(parts) >= 2 {
-		return fmt.Sprintf("%s:%s", parts[0], parts[1])
-	}
-	return *t
+	Schedule  interface{}            `json:"schedule"`  // map[string]DaySchedule（各曜日の最初の勤務）
+	Shifts    map[string][]ShiftTime `json:"shifts"`    // 曜日ごとの全勤務（休みは空）
+	Overrides []ShiftOverrideItem    `json:"overrides"` // 本日以降の日付指定の変更
+	Version   string                 `json:"version"`   // 楽観的排他制御用（ETag と同じ値）
 }
 
 func GetStaffDetailHandler(c *gin.Context) {
 	id := c.Param("id")
 	if strings.TrimSpace(id) == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{Code: "VAL_001", Message: "missing id"})
+		apierror.Respond(c, apierror.CodeMissingID)
 		return
 	}
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
@@ -1059,62 +1185,147 @@ func GetStaffDetailHandler(c *gin.Context) {
 	client, err := supa.NewClientFromEnv()
 	if err != nil {
 		log.Printf("DB_001: failed to init supabase client: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "backend initialization error"})
+		apierror.Respond(c, apierror.CodeDBInit)
 		return
 	}
 
+	s, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+
+	resp := buildStaffDetail(s)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// staffDetailSelect 詳細表示・更新で使う列
+var staffDetailSelect = strings.Join([]string{
+	"id", "sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
+	"status", "employment_type", "job_description", "position_id",
+	"joining_date", "resignation_date", "area_division", "group_no", "phone_number", "mobile_email_address", "pc_email_address",
+	"bath_towel", "equipment", "remarks",
+	"mon_start", "mon_end",
+	"tue_start", "tue_end",
+	"wed_start", "wed_end",
+	"thu_start", "thu_end",
+	"fri_start", "fri_end",
+	"sat_start", "sat_end",
+	"sun_start", "sun_end",
+	"updated_at",
+	"staff_car:vehicle(id,car_type,color,capacity,area,class_number,character,number,is_etc)",
+	"staff_shift_pattern(weekday,start_time,end_time)",
+	"staff_shift_override(id,date,kind,start_time,end_time,note)",
+	staffAccountSelect,
+	positionSelect,
+	groupSelect,
+}, ",")
+
+// fetchStaffDetailRow スタッフ1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchStaffDetailRow(ctx context.Context, client *supa.Client, id string) (StaffDTO, bool, string) {
 	q := url.Values{}
-	q.Set("select", strings.Join([]string{
-		"id", "sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
-		"status", "employment_type", "job_description", "position",
-		"joining_date", "area_division", "phone_number", "mobile_email_address", "pc_email_address",
-		"bath_towel", "equipment", "remarks",
-		"mon_start", "mon_end",
-		"tue_start", "tue_end",
-		"wed_start", "wed_end",
-		"thu_start", "thu_end",
-		"fri_start", "fri_end",
-		"sat_start", "sat_end",
-		"sun_start", "sun_end",
-		"staff_car:vehicle(id,car_type,color,capacity,area,character,number,is_etc)",
-	}, ","))
+	q.Set("select", staffDetailSelect)
 	q.Set("id", "eq."+id)
 	q.Set("limit", "1")
+	q.Set("staff_shift_override.date", "gte."+time.Now().In(jst).Format("2006-01-02"))
+	q.Set("staff_shift_override.order", "date,start_time")
 
 	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
 	if getErr != nil {
 		log.Printf("DB_001: supabase get error: %v", getErr)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_001", Message: "database fetch error"})
-		return
+		return StaffDTO{}, false, apierror.CodeDBInit
 	}
 	var rows []StaffDTO
 	if err := json.Unmarshal(body, &rows); err != nil {
 		log.Printf("DB_002: json decode error: %v", err)
-		c.JSON(http.StatusInternalServerError, ErrorResponse{Code: "DB_002", Message: "response decode error"})
-		return
+		return StaffDTO{}, false, apierror.CodeDBDecode
 	}
 	if len(rows) == 0 {
-		c.JSON(http.StatusNotFound, ErrorResponse{Code: "DB_404", Message: "staff not found"})
-		return
+		return StaffDTO{}, false, ""
 	}
-	s := rows[0]
+	return rows[0], true, ""
+}
 
-	schedule := map[string]DaySchedule{
-		"mon": {Work: s.MonStart != nil && s.MonEnd != nil, Start: hhmm(s.MonStart, "09:00"), End: hhmm(s.MonEnd, "18:00")},
-		"tue": {Work: s.TueStart != nil && s.TueEnd != nil, Start: hhmm(s.TueStart, "09:00"), End: hhmm(s.TueEnd, "18:00")},
-		"wed": {Work: s.WedStart != nil && s.WedEnd != nil, Start: hhmm(s.WedStart, "09:00"), End: hhmm(s.WedEnd, "18:00")},
-		"thu": {Work: s.ThuStart != nil && s.ThuEnd != nil, Start: hhmm(s.ThuStart, "09:00"), End: hhmm(s.ThuEnd, "18:00")},
-		"fri": {Work: s.FriStart != nil && s.FriEnd != nil, Start: hhmm(s.FriStart, "09:00"), End: hhmm(s.FriEnd, "18:00")},
-		"sat": {Work: s.SatStart != nil && s.SatEnd != nil, Start: hhmm(s.SatStart, "10:00"), End: hhmm(s.SatEnd, "16:00")},
-		"sun": {Work: s.SunStart != nil && s.SunEnd != nil, Start: hhmm(s.SunStart, "00:00"), End: hhmm(s.SunEnd, "00:00")},
+// patchStaffIfUnchanged 読み込んだ時点の updated_at と一致する場合のみ staff を更新し、更新後の行を返す
+// 失敗時・他の更新と競合した場合（412、最新の値付き）は応答済みで ok=false
+func patchStaffIfUnchanged(c *gin.Context, ctx context.Context, client *supa.Client, current StaffDTO, patch map[string]any) (map[string]any, bool) {
+	q := url.Values{}
+	q.Set("id", "eq."+current.ID)
+	q.Set("updated_at", "eq."+coalesce(current.UpdatedAt, ""))
+	respBody, status, patchErr := client.Patch(ctx, "/rest/v1/staff", q, patch)
+	if patchErr != nil {
+		if status == http.StatusConflict && isVehicleTaken(respBody) {
+			vehicleID, _ := patch["vehicle"].(string)
+			if vehicleID == "" && current.StaffCar != nil {
+				vehicleID = current.StaffCar.ID
+			}
+			respondVehicleTaken(c, ctx, client, vehicleID, current.ID)
+			return nil, false
+		}
+		log.Printf("DB_003: supabase patch error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return nil, false
+	}
+	// PostgREST returns an array with updated row when Prefer=return=representation
+	var updated []map[string]any
+	_ = json.Unmarshal(respBody, &updated)
+	if len(updated) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchStaffDetailRow(ctx, client, current.ID); code == "" && found {
+			etag.Conflict(c, etag.FromUpdatedAt(latest.UpdatedAt), buildStaffDetail(latest))
+			return nil, false
+		}
+		etag.Conflict(c, "", nil)
+		return nil, false
+	}
+	return updated[0], true
+}
+
+// scheduleDefaults 休みの曜日に入れる既定の時刻（画面で出勤にしたときの初期値）
+var scheduleDefaults = map[string][2]string{
+	"mon": {"09:00", "18:00"},
+	"tue": {"09:00", "18:00"},
+	"wed": {"09:00", "18:00"},
+	"thu": {"09:00", "18:00"},
+	"fri": {"09:00", "18:00"},
+	"sat": {"10:00", "16:00"},
+	"sun": {"00:00", "00:00"},
+}
+
+// staffSchedule 曜日ごとの最初の勤務（休みの曜日は既定の時刻を入れる）
+func staffSchedule(s StaffDTO) map[string]DaySchedule {
+	out := make(map[string]DaySchedule, len(scheduleDays))
+	for day, shifts := range s.weeklyShifts() {
+		if len(shifts) == 0 {
+			d := scheduleDefaults[day]
+			out[day] = DaySchedule{Start: d[0], End: d[1]}
+			continue
+		}
+		out[day] = DaySchedule{Work: true, Start: shifts[0].Start, End: shifts[0].End, Overnight: shifts[0].Overnight}
 	}
+	return out
+}
+
+// buildStaffDetail DB の行を詳細画面向けの形に変換する
+// 電話番号・メールアドレスは伏せる（マスク前の値は POST /api/staff/:id/reveal で取得する）
+func buildStaffDetail(s StaffDTO) StaffDetailResponse {
+	schedule := staffSchedule(s)
+
 	resp := StaffDetailResponse{
+		ID:               s.ID,
 		EmploymentStatus: mapEmploymentStatus(s.Status),
 		EmploymentDate:   parseDateOnly(s.JoiningDate),
+		RetirementDate:   retirementDate(s.ResignationDate),
 		EmploymentType:   mapEmploymentType(s.EmploymentType),
 		JobDriver:        strings.Contains(strings.ToLower(coalesce(s.JobDescription, "")), "driver") || strings.Contains(coalesce(s.JobDescription, ""), "送迎"),
 		JobOffice:        strings.Contains(strings.ToLower(coalesce(s.JobDescription, "")), "office") || strings.Contains(coalesce(s.JobDescription, ""), "事務"),
-		Role:             mapRole(s.Position),
+		Role:             s.roleKey(),
 		EtcEnabled:       s.StaffCar != nil && s.StaffCar.IsETC != nil && *s.StaffCar.IsETC,
 		BathTowel:        s.BathTowel,
 		Equipment:        s.Equipment,
@@ -1124,33 +1335,43 @@ func GetStaffDetailHandler(c *gin.Context) {
 		LastNameKana:     s.LastNameFurigana,
 		FirstNameKana:    s.FirstNameFurigana,
 		AreaDivision:     s.AreaDivision,
-		PhoneNumber:      s.PhoneNumber,
-		MobileEmail:      s.MobileEmail,
-		PcEmail:          s.PcEmail,
+		GroupNo:          s.GroupNo,
+		GroupName:        s.groupName(),
+		PhoneNumber:      maskPhone(s.PhoneNumber),
+		MobileEmail:      maskEmail(s.MobileEmail),
+		PcEmail:          maskEmail(s.PcEmail),
 		Remarks:          s.Remarks, // nilの場合はomitemptyでJSONに含まれないが、フロントエンドでundefinedとして処理される
 		Schedule:         schedule,
+		Shifts:           s.weeklyShifts(),
+		Overrides:        shiftOverrideItems(s.ShiftOverrides),
+		Version:          etag.FromUpdatedAt(s.UpdatedAt),
 	}
+	resp.AccountName, resp.AccessType, resp.AccessStatus, resp.AdjustmentRate = accountFields(s.Account)
 	if s.StaffCar != nil {
 		id := s.StaffCar.ID
 		resp.VehicleId = &id
 		resp.Car = &struct {
-			CarType   *string `json:"carType,omitempty"`
-			Color     *string `json:"color,omitempty"`
-			Capacity  *int    `json:"capacity,omitempty"`
-			Area      *string `json:"area,omitempty"`
-			Character *string `json:"character,omitempty"`
-			Number    *int    `json:"number,omitempty"`
-			IsETC     *bool   `json:"isETC,omitempty"`
+			CarType     *string `json:"carType,omitempty"`
+			Color       *string `json:"color,omitempty"`
+			Capacity    *int    `json:"capacity,omitempty"`
+			Area        *string `json:"area,omitempty"`
+			ClassNumber *string `json:"classNumber,omitempty"`
+			Character   *string `json:"character,omitempty"`
+			Number      *int    `json:"number,omitempty"`
+			Plate       *string `json:"plate,omitempty"`
+			IsETC       *bool   `json:"isETC,omitempty"`
 		}{
-			CarType:   s.StaffCar.CarType,
-			Color:     s.StaffCar.Color,
-			Capacity:  s.StaffCar.Capacity,
-			Area:      s.StaffCar.Area,
-			Character: s.StaffCar.Character,
-			Number:    s.StaffCar.Number,
-			IsETC:     s.StaffCar.IsETC,
+			CarType:     s.StaffCar.CarType,
+			Color:       s.StaffCar.Color,
+			Capacity:    s.StaffCar.Capacity,
+			Area:        s.StaffCar.Area,
+			ClassNumber: s.StaffCar.ClassNumber,
+			Character:   s.StaffCar.Character,
+			Number:      s.StaffCar.Number,
+			Plate:       s.StaffCar.plateLabel(),
+			IsETC:       s.StaffCar.IsETC,
 		}
 	}
 
-	c.JSON(http.StatusOK, resp)
+	return resp
 }
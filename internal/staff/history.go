@@ -0,0 +1,682 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	position "nissyo/internal/position"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 履歴一覧の最大件数
+const staffHistoryLimit = 100
+
+// staffHistoryColumns 版の比較・復元の対象にする staff の列（id・作成/更新日時・表示順は対象外）
+// 曜日別の列（mon_start など）は比較にのみ使い、復元は版の勤務パターン（shift_pattern）で行う
+var staffHistoryColumns = []string{
+	"sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
+	"area_division", "group_no", "status", "bath_towel", "equipment",
+	"joining_date", "resignation_date", "position_id", "employment_type", "job_description",
+	"mobile_email_address", "pc_email_address", "phone_number", "vehicle", "remarks",
+	"mon_start", "mon_end",
+	"tue_start", "tue_end",
+	"wed_start", "wed_end",
+	"thu_start", "thu_end",
+	"fri_start", "fri_end",
+	"sat_start", "sat_end",
+	"sun_start", "sun_end",
+}
+
+// staffCarHistoryColumns 版の比較・復元の対象にする staff_car の列
+var staffCarHistoryColumns = []string{"car_type", "color", "capacity", "area", "class_number", "character", "number", "is_etc"}
+
+type staffHistoryRow struct {
+	Version      int             `json:"version"`
+	Operation    string          `json:"operation"`
+	Staff        json.RawMessage `json:"staff"`
+	StaffCar     json.RawMessage `json:"staff_car"`
+	ShiftPattern json.RawMessage `json:"shift_pattern"` // 勤務パターンを記録する前の版は null
+	ChangedAt    string          `json:"changed_at"`
+}
+
+type StaffHistoryEntry struct {
+	Version   int      `json:"version"`
+	Operation string   `json:"operation"` // baseline / insert / update / car_update
+	ChangedAt string   `json:"changedAt"`
+	Changes   []string `json:"changes,omitempty"` // 直前の版から変わった列（staff_car の列は car_ 接頭辞。勤務パターンは shift_pattern）
+}
+
+type StaffHistoryListResponse struct {
+	StaffID  string              `json:"staffId"`
+	Versions []StaffHistoryEntry `json:"versions"`
+}
+
+type StaffHistoryVersionResponse struct {
+	StaffHistoryEntry
+	Staff StaffDetailResponse `json:"staff"`
+}
+
+type RevertStaffRequest struct {
+	Version *string `json:"version"`
+}
+
+// 版に戻さなかった項目（RevertStaffResponse.NotRestored）
+const (
+	notRestoredShiftPattern   = "shifts"         // 勤務パターンを記録する前の版（現在の勤務パターンのまま）
+	notRestoredShiftOverrides = "shiftOverrides" // 日付指定の勤務変更（版に含まれないため常に現在のまま）
+)
+
+// RevertStaffResponse 戻した後の詳細と、戻さなかった項目
+type RevertStaffResponse struct {
+	StaffDetailResponse
+	NotRestored []string `json:"notRestored"`
+}
+
+// GetStaffHistoryHandler スタッフの版の一覧（新しい順）
+// at（YYYY-MM-DD または RFC 3339）を指定するとその時点までの版に絞る（先頭がその時点の内容）
+func GetStaffHistoryHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	if at := strings.TrimSpace(c.Query("at")); at != "" {
+		cond, ok := historyAtFilter(at)
+		if !ok {
+			apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+				validate.NewFieldError(apierror.Lang(c), "at", "ymd", ""),
+			})
+			return
+		}
+		q.Set("changed_at", cond)
+	}
+	// 最も古い版の変更点を出すため1件多く取る
+	rows, code := fetchStaffHistory(ctx, client, id, q, staffHistoryLimit+1)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if len(rows) == 0 {
+		if _, found, code := fetchStaffDetailRow(ctx, client, id); code != "" || !found {
+			if code == "" {
+				code = apierror.CodeNotFound
+			}
+			apierror.Respond(c, code)
+			return
+		}
+	}
+
+	resp := StaffHistoryListResponse{StaffID: id, Versions: make([]StaffHistoryEntry, 0, len(rows))}
+	for i := 0; i < len(rows) && i < staffHistoryLimit; i++ {
+		var prev *staffHistoryRow
+		if i+1 < len(rows) {
+			prev = &rows[i+1]
+		}
+		resp.Versions = append(resp.Versions, historyEntry(rows[i], prev))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetStaffHistoryVersionHandler 指定した版の内容（詳細画面と同じ形）
+func GetStaffHistoryVersionHandler(c *gin.Context) {
+	id, version, ok := historyParams(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	// 指定の版と直前の版
+	q := url.Values{}
+	q.Set("version", "lte."+strconv.Itoa(version))
+	rows, code := fetchStaffHistory(ctx, client, id, q, 2)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if len(rows) == 0 || rows[0].Version != version {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	var prev *staffHistoryRow
+	if len(rows) > 1 {
+		prev = &rows[1]
+	}
+	snapshot, err := rows[0].staffDTO()
+	if err != nil {
+		log.Printf("DB_002: json decode error (staff_history): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	positions, code := position.List(ctx, client)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	// 当時の役職がマスタから消えている場合は未設定として表示する
+	snapshot.attachPosition(positions)
+	c.JSON(http.StatusOK, StaffHistoryVersionResponse{
+		StaffHistoryEntry: historyEntry(rows[0], prev),
+		Staff:             buildStaffDetail(snapshot),
+	})
+}
+
+// RevertStaffHandler 指定した版の内容に戻す（POST /api/staff/:id/revert/:version）
+// 通常の更新と同じく、版の内容を更新リクエストとして検証し、If-Match（またはボディの version）で競合を確認する
+// 勤務パターンは replace_staff_shift_pattern で版の内容に置き換える。日付指定の勤務変更と、
+// 勤務パターンを記録する前の版の勤務パターンは戻さず、応答の notRestored で知らせる
+// 戻した結果も新しい版として履歴に残る
+func RevertStaffHandler(c *gin.Context) {
+	id, version, ok := historyParams(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 12*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req RevertStaffRequest
+	if c.Request.ContentLength != 0 {
+		fieldErrs, err := validate.BindJSON(c, &req)
+		if err != nil {
+			log.Printf("VAL_002: invalid body: %v", err)
+			apierror.Respond(c, apierror.CodeValidation)
+			return
+		}
+		if len(fieldErrs) > 0 {
+			apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+			return
+		}
+	}
+
+	// 1) 戻す版を取得し、更新リクエストとして妥当か確認する
+	q := url.Values{}
+	q.Set("version", "eq."+strconv.Itoa(version))
+	rows, code := fetchStaffHistory(ctx, client, id, q, 1)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if len(rows) == 0 {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	target, targetCar, err := rows[0].maps()
+	if err != nil {
+		log.Printf("DB_002: json decode error (staff_history): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	snapshot, err := rows[0].staffDTO()
+	if err != nil {
+		log.Printf("DB_002: json decode error (staff_history): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	positions, code := position.List(ctx, client)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !snapshot.attachPosition(positions) {
+		// 当時の役職がマスタから削除されている
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "role", "oneof", strings.Join(positions.Keys(), " ")),
+		})
+		return
+	}
+	asRequest := requestFromStaff(snapshot)
+	fieldErrs, err := validate.Struct(apierror.Lang(c), &asRequest)
+	if err != nil {
+		log.Printf("VAL_002: validate snapshot: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+	// 当時の地域区分・グループがマスタから削除・改名されていないか
+	if _, fieldErrs, code := masterPatch(ctx, client, apierror.Lang(c), &asRequest); code != "" || len(fieldErrs) > 0 {
+		if code != "" {
+			apierror.Respond(c, code)
+		} else {
+			apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		}
+		return
+	}
+
+	// 2) 現在値を取得し、クライアントが見ている版と比較する
+	current, currentRow, found, code := fetchStaffForRevert(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if !etag.Check(c, req.Version, etag.FromUpdatedAt(current.UpdatedAt), buildStaffDetail(current)) {
+		return
+	}
+
+	// 3) 差分を作る（車両は当時の車両が残っていれば紐付け直し、消えていれば当時の内容で作り直す）
+	patch, _ := diffColumns(pick(currentRow, staffHistoryColumns), pick(target, staffHistoryColumns), "")
+	delete(patch, "vehicle")
+	for _, day := range scheduleDays {
+		// 曜日別の列を直接戻すとその曜日の勤務が1件に置き換わるため、勤務パターンとして戻す
+		delete(patch, day+"_start")
+		delete(patch, day+"_end")
+	}
+	targetPattern, patternRecorded, err := rows[0].shiftPattern()
+	if err != nil {
+		log.Printf("DB_002: json decode error (staff_history shift_pattern): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	notRestored := []string{notRestoredShiftOverrides}
+	if !patternRecorded {
+		notRestored = append([]string{notRestoredShiftPattern}, notRestored...)
+	}
+	var carPatch map[string]any
+	var carTarget, createdCarID string
+	targetVehicle, _ := target["vehicle"].(string)
+	currentCar, _ := currentRow["staff_car"].(map[string]any)
+	currentVehicle, _ := currentRow["vehicle"].(string)
+	switch {
+	case targetVehicle == "":
+		if currentVehicle != "" {
+			patch["vehicle"] = nil
+		}
+	case targetVehicle == currentVehicle:
+		carTarget = currentVehicle
+		carPatch, _ = diffColumns(pick(currentCar, staffCarHistoryColumns), pick(targetCar, staffCarHistoryColumns), "")
+	default:
+		existingCar, found, code := fetchStaffCarRow(ctx, client, targetVehicle)
+		if code != "" {
+			apierror.Respond(c, code)
+			return
+		}
+		if found {
+			// 当時の車両が今は他の在職中のスタッフに割り当てられている場合は戻さない（409）
+			if !checkVehicleAssignable(c, ctx, client, &targetVehicle, id) {
+				return
+			}
+			carTarget = targetVehicle
+			carPatch, _ = diffColumns(pick(existingCar, staffCarHistoryColumns), pick(targetCar, staffCarHistoryColumns), "")
+		} else {
+			newID, code := insertStaffCar(ctx, client, pick(targetCar, staffCarHistoryColumns))
+			if code == apierror.CodeDuplicate {
+				// 版のナンバーが今は他の車両に登録されている
+				respondPlateDuplicate(c)
+				return
+			}
+			if code != "" {
+				apierror.Respond(c, code)
+				return
+			}
+			createdCarID = newID
+			targetVehicle = newID
+		}
+		patch["vehicle"] = targetVehicle
+	}
+
+	// 4) staff → staff_car → 勤務パターンの順に反映（staff・staff_car は UpdateStaffHandler と同じ）
+	updatedAt := coalesce(current.UpdatedAt, "")
+	if len(patch) > 0 || len(carPatch) > 0 {
+		staffPatch := patch
+		if len(staffPatch) == 0 {
+			staffPatch = map[string]any{"updated_at": "now"}
+		}
+		staffUpdated, ok := patchStaffIfUnchanged(c, ctx, client, current, staffPatch)
+		if !ok {
+			if createdCarID != "" {
+				deleteStaffCar(ctx, client, createdCarID)
+			}
+			return
+		}
+		if v, ok := staffUpdated["updated_at"].(string); ok {
+			updatedAt = v
+		}
+		if len(carPatch) > 0 {
+			qcar := url.Values{}
+			qcar.Set("id", "eq."+carTarget)
+			if _, status, err := client.Patch(ctx, "/rest/v1/staff_car", qcar, carPatch); err != nil {
+				if status == http.StatusConflict {
+					respondPlateDuplicate(c)
+					return
+				}
+				log.Printf("DB_003: supabase patch car error: %v", err)
+				apierror.Respond(c, apierror.CodeDBUpdate)
+				return
+			}
+		}
+	}
+	if patternRecorded && shiftPatternKey(targetPattern) != shiftPatternKey(current.ShiftPatterns) {
+		shifts := make([]map[string]any, 0, len(targetPattern))
+		for _, p := range targetPattern {
+			shifts = append(shifts, map[string]any{"weekday": p.Weekday, "start_time": p.StartTime, "end_time": p.EndTime})
+		}
+		body, status, rpcErr := client.RPC(ctx, "replace_staff_shift_pattern", map[string]any{
+			"p_staff_id":   id,
+			"p_updated_at": updatedAt,
+			"p_shifts":     shifts,
+		})
+		if rpcErr != nil {
+			if status == http.StatusPreconditionFailed {
+				// 読み込みから更新までの間に他の更新が入った
+				if latest, found, code := fetchStaffDetailRow(ctx, client, id); code == "" && found {
+					etag.Conflict(c, etag.FromUpdatedAt(latest.UpdatedAt), buildStaffDetail(latest))
+					return
+				}
+				etag.Conflict(c, "", nil)
+				return
+			}
+			log.Printf("DB_003: replace_staff_shift_pattern error: %v body=%s", rpcErr, string(body))
+			apierror.Respond(c, apierror.CodeDBUpdate)
+			return
+		}
+	}
+
+	updated, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildStaffDetail(updated)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, RevertStaffResponse{StaffDetailResponse: resp, NotRestored: notRestored})
+}
+
+// historyParams :id と :version を取り出す（不正な場合は応答済みで ok=false）
+func historyParams(c *gin.Context) (string, int, bool) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "version", "min", "1"),
+		})
+		return "", 0, false
+	}
+	return id, version, true
+}
+
+// historyAtFilter at を changed_at の条件にする（日付のみならその日の終わりまで、日本時間）
+func historyAtFilter(at string) (string, bool) {
+	if d, err := time.ParseInLocation("2006-01-02", at, jst); err == nil {
+		return "lt." + d.AddDate(0, 0, 1).Format(time.RFC3339), true
+	}
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return "lte." + t.Format(time.RFC3339Nano), true
+	}
+	return "", false
+}
+
+// fetchStaffHistory 版を新しい順に取得する（q に追加の条件）
+func fetchStaffHistory(ctx context.Context, client *supa.Client, id string, q url.Values, limit int) ([]staffHistoryRow, string) {
+	q.Set("select", "version,operation,staff,staff_car,shift_pattern,changed_at")
+	q.Set("staff_id", "eq."+id)
+	q.Set("order", "version.desc")
+	q.Set("limit", strconv.Itoa(limit))
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_history", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []staffHistoryRow
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	return rows, ""
+}
+
+// fetchStaffForRevert 現在の行を StaffDTO と列名のマップの両方で取得する
+func fetchStaffForRevert(ctx context.Context, client *supa.Client, id string) (StaffDTO, map[string]any, bool, string) {
+	q := url.Values{}
+	q.Set("select", "id,"+strings.Join(staffHistoryColumns, ",")+",updated_at,"+
+		"staff_car:vehicle(id,"+strings.Join(staffCarHistoryColumns, ",")+"),"+
+		"staff_shift_pattern(weekday,start_time,end_time),"+positionSelect)
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		return StaffDTO{}, nil, false, apierror.CodeDBInit
+	}
+	var raws []json.RawMessage
+	if err := json.Unmarshal(body, &raws); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return StaffDTO{}, nil, false, apierror.CodeDBDecode
+	}
+	if len(raws) == 0 {
+		return StaffDTO{}, nil, false, ""
+	}
+	var s StaffDTO
+	var m map[string]any
+	if err := json.Unmarshal(raws[0], &s); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return StaffDTO{}, nil, false, apierror.CodeDBDecode
+	}
+	if err := json.Unmarshal(raws[0], &m); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return StaffDTO{}, nil, false, apierror.CodeDBDecode
+	}
+	return s, m, true, ""
+}
+
+// fetchStaffCarRow staff_car を1件取得する
+func fetchStaffCarRow(ctx context.Context, client *supa.Client, id string) (map[string]any, bool, string) {
+	q := url.Values{}
+	q.Set("select", "id,"+strings.Join(staffCarHistoryColumns, ","))
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_car", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		return nil, false, apierror.CodeDBInit
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return nil, false, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return nil, false, ""
+	}
+	return rows[0], true, ""
+}
+
+// maps スナップショットを列名のマップにする（車両が無ければ car は nil）
+func (h staffHistoryRow) maps() (staff, car map[string]any, err error) {
+	if err = json.Unmarshal(h.Staff, &staff); err != nil {
+		return nil, nil, err
+	}
+	if len(h.StaffCar) > 0 && string(h.StaffCar) != "null" {
+		if err = json.Unmarshal(h.StaffCar, &car); err != nil {
+			return nil, nil, err
+		}
+	}
+	return staff, car, nil
+}
+
+// shiftPattern 版の勤務パターン（記録する前の版は recorded=false）
+func (h staffHistoryRow) shiftPattern() (pattern []ShiftPatternDTO, recorded bool, err error) {
+	if len(h.ShiftPattern) == 0 || string(h.ShiftPattern) == "null" {
+		return nil, false, nil
+	}
+	pattern = []ShiftPatternDTO{}
+	if err := json.Unmarshal(h.ShiftPattern, &pattern); err != nil {
+		return nil, false, err
+	}
+	return pattern, true, nil
+}
+
+// shiftPatternKey 勤務パターンの比較用の文字列（並び順・秒の有無によらない）
+func shiftPatternKey(pattern []ShiftPatternDTO) string {
+	keys := make([]string, 0, len(pattern))
+	for _, p := range pattern {
+		keys = append(keys, strconv.Itoa(p.Weekday)+" "+columnValueString("_start", p.StartTime)+"-"+columnValueString("_end", p.EndTime))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// staffDTO スナップショットを API の行と同じ形にする（staff_car・勤務パターンを埋め込む）
+func (h staffHistoryRow) staffDTO() (StaffDTO, error) {
+	var s StaffDTO
+	if err := json.Unmarshal(h.Staff, &s); err != nil {
+		return StaffDTO{}, err
+	}
+	pattern, recorded, err := h.shiftPattern()
+	if err != nil {
+		return StaffDTO{}, err
+	}
+	if recorded {
+		s.ShiftPatterns = pattern
+	}
+	if len(h.StaffCar) > 0 && string(h.StaffCar) != "null" {
+		var car StaffCarDTO
+		if err := json.Unmarshal(h.StaffCar, &car); err != nil {
+			return StaffDTO{}, err
+		}
+		s.StaffCar = &car
+	}
+	return s, nil
+}
+
+// historyEntry 版の概要（prev が無ければ変更点は出さない）
+func historyEntry(h staffHistoryRow, prev *staffHistoryRow) StaffHistoryEntry {
+	e := StaffHistoryEntry{
+		Version:   h.Version,
+		Operation: h.Operation,
+		ChangedAt: formatDateTimeLikeSample(&h.ChangedAt),
+	}
+	if prev == nil {
+		return e
+	}
+	cur, curCar, err1 := h.maps()
+	old, oldCar, err2 := prev.maps()
+	if err1 != nil || err2 != nil {
+		return e
+	}
+	_, changes := diffColumns(pick(old, staffHistoryColumns), pick(cur, staffHistoryColumns), "")
+	_, carChanges := diffColumns(pick(oldCar, staffCarHistoryColumns), pick(curCar, staffCarHistoryColumns), "car_")
+	e.Changes = append(changes, carChanges...)
+	curPattern, curRecorded, err1 := h.shiftPattern()
+	oldPattern, oldRecorded, err2 := prev.shiftPattern()
+	if err1 == nil && err2 == nil && curRecorded && oldRecorded && shiftPatternKey(curPattern) != shiftPatternKey(oldPattern) {
+		e.Changes = append(e.Changes, "shift_pattern")
+	}
+	sort.Strings(e.Changes)
+	return e
+}
+
+// pick 指定した列だけを取り出す（m が nil なら全列 nil）
+func pick(m map[string]any, cols []string) map[string]any {
+	out := make(map[string]any, len(cols))
+	for _, k := range cols {
+		out[k] = m[k]
+	}
+	return out
+}
+
+// requestFromStaff 行の内容を、編集画面から同じ内容を保存した場合の更新リクエストにする
+// 版へ戻す前に通常の更新と同じ検証をかけるために使う
+func requestFromStaff(s StaffDTO) UpdateStaffDetailRequest {
+	str := func(p *string) *string {
+		v := coalesce(p, "")
+		return &v
+	}
+	req := UpdateStaffDetailRequest{
+		Sfid:          str(toStringPtrFromIntPtr(s.SFID)),
+		LastName:      str(s.LastName),
+		FirstName:     str(s.FirstName),
+		LastNameKana:  str(s.LastNameFurigana),
+		FirstNameKana: str(s.FirstNameFurigana),
+		AreaDivision:  str(s.AreaDivision),
+		PhoneNumber:   str(s.PhoneNumber),
+		MobileEmail:   str(s.MobileEmail),
+		PcEmail:       str(s.PcEmail),
+		Remarks:       str(s.Remarks),
+		BathTowel:     s.BathTowel,
+		Equipment:     s.Equipment,
+	}
+	if st := mapEmploymentStatus(s.Status); st != "" {
+		req.EmploymentStatus = &st
+	}
+	employmentDate := parseDateOnly(s.JoiningDate)
+	req.EmploymentDate = &employmentDate
+	req.RetirementDate = str(retirementDate(s.ResignationDate))
+	employmentType := mapEmploymentType(s.EmploymentType)
+	req.EmploymentType = &employmentType
+	role := s.roleKey()
+	req.Role = &role
+	groupNo := 0
+	if s.GroupNo != nil {
+		groupNo = *s.GroupNo
+	}
+	req.GroupNo = &groupNo
+	if s.StaffCar != nil {
+		req.Car = &UpdateCarRequest{
+			CarType:     s.StaffCar.CarType,
+			Color:       s.StaffCar.Color,
+			Capacity:    s.StaffCar.Capacity,
+			Area:        s.StaffCar.Area,
+			ClassNumber: s.StaffCar.ClassNumber,
+			Character:   s.StaffCar.Character,
+			Number:      s.StaffCar.Number,
+			IsETC:       s.StaffCar.IsETC,
+		}
+	}
+	req.Schedule = map[string]UpdateDay{}
+	for day, d := range staffSchedule(s) {
+		work, start, end := d.Work, d.Start, d.End
+		req.Schedule[day] = UpdateDay{Work: &work, Start: &start, End: &end}
+	}
+	return req
+}
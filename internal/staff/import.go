@@ -0,0 +1,736 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	area "nissyo/internal/area"
+	group "nissyo/internal/group"
+	plate "nissyo/internal/plate"
+	position "nissyo/internal/position"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 一括挿入1回あたりの行数
+const importBatchSize = 50
+
+// 取り込み計画・結果の区分
+const (
+	importActionCreate = "create"
+	importActionUpdate = "update"
+	importActionSkip   = "skip"
+	importActionError  = "error"
+
+	importResultCreated = "created"
+	importResultUpdated = "updated"
+	importResultSkipped = "skipped"
+	importResultFailed  = "failed"
+)
+
+type ImportStaffResponse struct {
+	Mode           string            `json:"mode"` // dryRun / commit
+	Encoding       string            `json:"encoding"`
+	IgnoredColumns []string          `json:"ignoredColumns,omitempty"`
+	Summary        ImportSummary     `json:"summary"`
+	Rows           []ImportRowResult `json:"rows"`
+}
+
+type ImportSummary struct {
+	Total  int `json:"total"`
+	Create int `json:"create"`
+	Update int `json:"update"`
+	Skip   int `json:"skip"`
+	Error  int `json:"error"`
+	// commit 時のみ
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
+
+type ImportRowResult struct {
+	Row     int                   `json:"row"` // CSV 上の行番号（見出しが1行目）
+	Sfid    string                `json:"sfid,omitempty"`
+	Name    string                `json:"name,omitempty"`
+	Action  string                `json:"action"` // create / update / skip / error
+	ID      string                `json:"id,omitempty"`
+	Changes []string              `json:"changes,omitempty"` // update 時に変わる列（staff_car の列は car_ 接頭辞）
+	Errors  []apierror.FieldError `json:"errors,omitempty"`
+	// commit 時のみ
+	Result  string `json:"result,omitempty"` // created / updated / skipped / failed
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// importPlan 1行分の反映内容
+type importPlan struct {
+	result    *ImportRowResult
+	staff     map[string]any // create: 挿入する行、update: 変わる列のみ
+	car       map[string]any // create: 新しい車両、update: 変わる列のみ
+	carID     string         // update で既に車両が紐付いている場合
+	updatedAt string         // update: 計画時点の updated_at（この値のままなら更新する）
+}
+
+// importSelect 差分の比較に使う列（importColumns の列をすべて含める）
+var importSelect = strings.Join([]string{
+	"id", "sfid", "first_name", "last_name", "first_name_furigana", "last_name_furigana",
+	"area_division", "group_no", "status", "employment_type", "job_description", "position_id",
+	"joining_date", "resignation_date", "phone_number", "mobile_email_address", "pc_email_address",
+	"bath_towel", "equipment", "remarks",
+	"mon_start", "mon_end",
+	"tue_start", "tue_end",
+	"wed_start", "wed_end",
+	"thu_start", "thu_end",
+	"fri_start", "fri_end",
+	"sat_start", "sat_end",
+	"sun_start", "sun_end",
+	"updated_at",
+	"staff_car:vehicle(id,car_type,color,capacity,area,class_number,character,number,is_etc)",
+}, ",")
+
+// ImportStaffHandler スタッフの CSV 一括取り込み（POST /api/staff/import）
+// ボディは CSV そのもの、または multipart/form-data の file。1行目は見出し（staff の列名または和名）
+//
+//	mode=dryRun（既定）  行ごとの検証結果と create / update / skip の計画を返す（DB は変更しない）
+//	mode=commit          計画を反映して行ごとの結果を返す。検証エラーの行が1つでもあれば何もせず 422
+//	encoding=auto（既定）/ utf-8 / shift_jis
+//
+// sfid が一致するスタッフは更新、無ければ作成（sfid 空欄は採番）。空欄のセルは変更しない
+func ImportStaffHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	lang := apierror.Lang(c)
+
+	mode := c.DefaultQuery("mode", "dryRun")
+	if mode != "dryRun" && mode != "commit" {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(lang, "mode", "oneof", "dryRun commit"),
+		})
+		return
+	}
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	data, err := readImportBody(c)
+	if err != nil {
+		if errors.Is(err, errImportTooLarge) {
+			apierror.Respond(c, apierror.CodeImportTooLarge)
+			return
+		}
+		log.Printf("IMP_400: failed to read body: %v", err)
+		apierror.RespondWith(c, apierror.CodeImportInvalidFile, err.Error(), nil)
+		return
+	}
+	encoding, records, err := decodeImportCSV(data, c.Query("encoding"))
+	if err != nil {
+		apierror.RespondWith(c, apierror.CodeImportInvalidFile, err.Error(), nil)
+		return
+	}
+	if len(records) < 2 {
+		apierror.RespondWith(c, apierror.CodeImportInvalidFile, "no data rows", nil)
+		return
+	}
+	if len(records)-1 > importMaxRows {
+		apierror.Respond(c, apierror.CodeImportTooLarge)
+		return
+	}
+	header := records[0]
+	cols, ignored, headerErrs := mapImportHeader(lang, header)
+	if len(headerErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeImportInvalidFile, "", headerErrs)
+		return
+	}
+
+	resp := ImportStaffResponse{Mode: mode, Encoding: encoding, IgnoredColumns: ignored, Rows: []ImportRowResult{}}
+	plans, code := planStaffImport(ctx, client, lang, header, cols, records[1:])
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	for _, p := range plans {
+		resp.Rows = append(resp.Rows, *p.result)
+	}
+	resp.Summary = summarizeImport(resp.Rows)
+
+	if mode == "dryRun" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	if resp.Summary.Error > 0 {
+		apierror.RespondWith(c, apierror.CodeImportRowErrors, "", resp)
+		return
+	}
+
+	applyStaffImport(ctx, client, lang, plans)
+	resp.Rows = resp.Rows[:0]
+	for _, p := range plans {
+		resp.Rows = append(resp.Rows, *p.result)
+	}
+	resp.Summary = summarizeImport(resp.Rows)
+	c.JSON(http.StatusOK, resp)
+}
+
+var errImportTooLarge = errors.New("import file too large")
+
+// readImportBody CSV 本体を読み込む（multipart の場合は file 項目）
+func readImportBody(c *gin.Context) ([]byte, error) {
+	var r io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		if fh.Size > importMaxBytes {
+			return nil, errImportTooLarge
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := io.ReadAll(io.LimitReader(r, importMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > importMaxBytes {
+		return nil, errImportTooLarge
+	}
+	return data, nil
+}
+
+// planStaffImport 各行を検証し、既存スタッフ（sfid で照合）との差分から反映内容を決める
+func planStaffImport(ctx context.Context, client *supa.Client, lang string, header []string, cols []*importColumn, records [][]string) ([]*importPlan, string) {
+	sfidLabel, lastLabel, firstLabel := "sfid", "last_name", "first_name"
+	hasRole, hasArea, hasGroup := false, false, false
+	for i, col := range cols {
+		if col == nil {
+			continue
+		}
+		switch col.name {
+		case "sfid":
+			sfidLabel = header[i]
+		case "last_name":
+			lastLabel = header[i]
+		case "first_name":
+			firstLabel = header[i]
+		case "position":
+			hasRole = true
+		case "area_division":
+			hasArea = true
+		case "group_no":
+			hasGroup = true
+		}
+	}
+	// 役職・地域区分・グループの列がある場合のみ各マスタを読む
+	var positions position.Positions
+	if hasRole {
+		var code string
+		if positions, code = position.List(ctx, client); code != "" {
+			return nil, code
+		}
+	}
+	var areas area.Areas
+	if hasArea {
+		var code string
+		if areas, code = area.List(ctx, client); code != "" {
+			return nil, code
+		}
+	}
+	var groups group.Groups
+	if hasGroup {
+		var code string
+		if groups, code = group.List(ctx, client); code != "" {
+			return nil, code
+		}
+	}
+
+	rows := make([]*importRow, 0, len(records))
+	plans := make([]*importPlan, 0, len(records))
+	seenSfid := map[int]bool{}
+	seenPlate := map[plate.Plate]bool{}
+	var sfids []int
+	for i, rec := range records {
+		if strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue // 空行（Excel の末尾の行など）
+		}
+		r := parseImportRow(lang, i+2, header, cols, rec)
+		p := &importPlan{result: &ImportRowResult{Row: r.line}}
+		if r.req.Sfid != nil {
+			p.result.Sfid = *r.req.Sfid
+		}
+		p.result.Name = strings.TrimSpace(coalesce(r.req.LastName, "") + " " + coalesce(r.req.FirstName, ""))
+		if len(r.errs) == 0 {
+			p.staff = buildStaffPatch(&r.req)
+			for k, v := range r.raw {
+				p.staff[k] = v
+			}
+			if r.role != "" {
+				if pos, ok := positions.Match(r.role); ok {
+					p.staff["position_id"] = pos.ID
+				} else {
+					r.errs = append(r.errs, validate.NewFieldError(lang, r.labels["role"], "oneof", strings.Join(positions.NamesJA(), " ")))
+				}
+			}
+			if r.req.AreaDivision != nil {
+				if a, ok := areas.Match(*r.req.AreaDivision); ok {
+					p.staff["area_division"] = a.Name
+				} else {
+					r.errs = append(r.errs, validate.NewFieldError(lang, r.labels["areaDivision"], "oneof", strings.Join(areas.Names(), " ")))
+				}
+			}
+			if r.group != "" {
+				if g, ok := groups.Match(r.group); ok {
+					p.staff["group_no"] = g.Number
+				} else {
+					r.errs = append(r.errs, validate.NewFieldError(lang, r.labels["groupNo"], "exists", ""))
+				}
+			}
+			if r.req.Car != nil {
+				p.car = buildCarRow(r.req.Car)
+			}
+			if n, ok := p.staff["sfid"].(int); ok {
+				if seenSfid[n] {
+					r.errs = append(r.errs, validate.NewFieldError(lang, sfidLabel, "unique", ""))
+				} else {
+					seenSfid[n] = true
+					sfids = append(sfids, n)
+				}
+				p.result.Sfid = *toStringPtrFromIntPtr(&n)
+			}
+		}
+		rows = append(rows, r)
+		plans = append(plans, p)
+	}
+
+	existing, code := fetchStaffBySfid(ctx, client, sfids)
+	if code != "" {
+		return nil, code
+	}
+
+	for i, p := range plans {
+		r := rows[i]
+		if len(r.errs) > 0 {
+			p.result.Action = importActionError
+			p.result.Errors = r.errs
+			p.staff, p.car = nil, nil
+			continue
+		}
+		var cur map[string]any
+		if n, ok := p.staff["sfid"].(int); ok {
+			cur = existing[n]
+		}
+		// 勤務時間は既存の値と合わせて確認する（退勤の列だけの更新など）
+		var curRow *StaffDTO
+		if cur != nil {
+			curRow = &StaffDTO{}
+			if b, err := json.Marshal(cur); err == nil {
+				_ = json.Unmarshal(b, curRow)
+			}
+		}
+		r.addErrors(validateSchedule(lang, r.req.Schedule, curRow))
+		if len(r.errs) > 0 {
+			p.result.Action = importActionError
+			p.result.Errors = r.errs
+			p.staff, p.car = nil, nil
+			continue
+		}
+		if cur == nil {
+			// 新規作成は氏名が必須（CreateStaffHandler と同じ）
+			if r.req.LastName == nil {
+				r.errs = append(r.errs, validate.NewFieldError(lang, lastLabel, "required", ""))
+			}
+			if r.req.FirstName == nil {
+				r.errs = append(r.errs, validate.NewFieldError(lang, firstLabel, "required", ""))
+			}
+			if len(r.errs) > 0 {
+				p.result.Action = importActionError
+				p.result.Errors = r.errs
+				p.staff, p.car = nil, nil
+				continue
+			}
+			if code := r.checkPlate(ctx, client, lang, nil, p.car, seenPlate); code != "" {
+				return nil, code
+			}
+			if len(r.errs) > 0 {
+				p.result.Action = importActionError
+				p.result.Errors = r.errs
+				p.staff, p.car = nil, nil
+				continue
+			}
+			if _, ok := p.staff["status"]; !ok {
+				p.staff["status"] = true
+			}
+			p.result.Action = importActionCreate
+			continue
+		}
+
+		p.result.ID, _ = cur["id"].(string)
+		p.updatedAt, _ = cur["updated_at"].(string)
+		var changes []string
+		p.staff, changes = diffColumns(cur, p.staff, "")
+		if p.car != nil {
+			curCar, _ := cur["staff_car"].(map[string]any)
+			if curCar != nil {
+				p.carID, _ = curCar["id"].(string)
+			}
+			var carChanges []string
+			p.car, carChanges = diffColumns(curCar, p.car, "car_")
+			changes = append(changes, carChanges...)
+			if code := r.checkPlate(ctx, client, lang, curRow.StaffCar, p.car, seenPlate); code != "" {
+				return nil, code
+			}
+			if len(r.errs) > 0 {
+				p.result.Action = importActionError
+				p.result.Errors = r.errs
+				p.staff, p.car = nil, nil
+				continue
+			}
+		}
+		if len(changes) == 0 {
+			p.result.Action = importActionSkip
+			continue
+		}
+		sort.Strings(changes)
+		p.result.Action = importActionUpdate
+		p.result.Changes = changes
+	}
+	return plans, ""
+}
+
+// checkPlate 行の車両のナンバーが他の車両・ファイル内の前の行と重ならないか確かめる（重複は行のエラーにする）
+// cur は更新する車両（新規は nil）。失敗時はエラーコードを返す（ログ出力済み）
+func (r *importRow) checkPlate(ctx context.Context, client *supa.Client, lang string, cur *StaffCarDTO, car map[string]any, seen map[plate.Plate]bool) string {
+	p, changed := patchedPlate(cur, car)
+	if !changed || !p.Complete() {
+		return ""
+	}
+	label := "car.plate"
+	for _, f := range []string{"car.plate", "car.number", "car.character", "car.area", "car.classNumber"} {
+		if h, ok := r.labels[f]; ok {
+			label = h
+			break
+		}
+	}
+	if seen[p] {
+		r.errs = append(r.errs, validate.NewFieldError(lang, label, "unique", ""))
+		return ""
+	}
+	seen[p] = true
+	excludeID := ""
+	if cur != nil {
+		excludeID = cur.ID
+	}
+	taken, code := plateTaken(ctx, client, p, excludeID)
+	if code != "" {
+		return code
+	}
+	if taken {
+		r.errs = append(r.errs, validate.NewFieldError(lang, label, "unique", ""))
+	}
+	return ""
+}
+
+// fetchStaffBySfid sfid → 現在の行（importSelect の列）
+func fetchStaffBySfid(ctx context.Context, client *supa.Client, sfids []int) (map[int]map[string]any, string) {
+	out := map[int]map[string]any{}
+	const chunk = 200
+	for start := 0; start < len(sfids); start += chunk {
+		end := min(start+chunk, len(sfids))
+		list := make([]string, 0, end-start)
+		for _, n := range sfids[start:end] {
+			list = append(list, strconv.Itoa(n))
+		}
+		q := url.Values{}
+		q.Set("select", importSelect)
+		q.Set("sfid", "in.("+strings.Join(list, ",")+")")
+		body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+		if getErr != nil {
+			log.Printf("DB_001: supabase get error: %v", getErr)
+			return nil, apierror.CodeDBInit
+		}
+		var rows []map[string]any
+		if err := json.Unmarshal(body, &rows); err != nil {
+			log.Printf("DB_002: json decode error: %v", err)
+			return nil, apierror.CodeDBDecode
+		}
+		for _, row := range rows {
+			if n, ok := row["sfid"].(float64); ok {
+				out[int(n)] = row
+			}
+		}
+	}
+	return out, ""
+}
+
+// diffColumns 現在の行と比べて値が変わる列だけを残す（cur が nil なら全列が変更）
+func diffColumns(cur, next map[string]any, prefix string) (map[string]any, []string) {
+	diff := map[string]any{}
+	var changes []string
+	for k, v := range next {
+		if cur != nil && columnValueString(k, cur[k]) == columnValueString(k, v) {
+			continue
+		}
+		diff[k] = v
+		if strings.HasPrefix(k, prefix) {
+			changes = append(changes, k) // car_type はそのまま
+		} else {
+			changes = append(changes, prefix+k)
+		}
+	}
+	return diff, changes
+}
+
+// columnValueString 比較用に値を文字列へそろえる（日付は日付部分、時刻は HH:MM）
+func columnValueString(col string, v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if strings.HasSuffix(col, "_date") {
+			return parseDateOnly(&v)
+		}
+		if strings.HasSuffix(col, "_start") || strings.HasSuffix(col, "_end") {
+			if len(v) >= 5 && v[2] == ':' {
+				return v[:5]
+			}
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func summarizeImport(rows []ImportRowResult) ImportSummary {
+	s := ImportSummary{Total: len(rows)}
+	for _, r := range rows {
+		switch r.Action {
+		case importActionCreate:
+			s.Create++
+		case importActionUpdate:
+			s.Update++
+		case importActionSkip:
+			s.Skip++
+		case importActionError:
+			s.Error++
+		}
+		switch r.Result {
+		case importResultCreated:
+			s.Created++
+		case importResultUpdated:
+			s.Updated++
+		case importResultFailed:
+			s.Failed++
+		}
+	}
+	return s
+}
+
+// applyStaffImport 計画を反映する
+// 車両を伴わず sfid が決まっている新規行は importBatchSize 件ずつまとめて挿入し、
+// 失敗したまとまりは1行ずつ挿入し直して失敗した行を特定する。それ以外は1行ずつ反映する
+// sfid を採番する新規行は最後に作成する（採番した番号がファイル内で指定された sfid と重ならないよう、指定分を先に登録する）
+func applyStaffImport(ctx context.Context, client *supa.Client, lang string, plans []*importPlan) {
+	var batch, allocate []*importPlan
+	flush := func() {
+		if len(batch) > 0 {
+			insertImportBatch(ctx, client, lang, batch)
+			batch = batch[:0]
+		}
+	}
+	for _, p := range plans {
+		switch p.result.Action {
+		case importActionSkip:
+			p.result.Result = importResultSkipped
+		case importActionCreate:
+			_, hasSfid := p.staff["sfid"]
+			switch {
+			case !hasSfid:
+				allocate = append(allocate, p)
+			case p.car == nil:
+				batch = append(batch, p)
+				if len(batch) == importBatchSize {
+					flush()
+				}
+			default:
+				createImportRow(ctx, client, lang, p)
+			}
+		case importActionUpdate:
+			updateImportRow(ctx, client, lang, p)
+		}
+	}
+	flush()
+	for _, p := range allocate {
+		createImportRow(ctx, client, lang, p)
+	}
+}
+
+func (p *importPlan) fail(lang, code string) {
+	p.result.Result = importResultFailed
+	p.result.Code = code
+	p.result.Message = apierror.Lookup(code).Message(lang)
+}
+
+// insertImportBatch 新規行をまとめて挿入する（一括挿入は全件成功か全件失敗）
+func insertImportBatch(ctx context.Context, client *supa.Client, lang string, batch []*importPlan) {
+	// 行ごとに列が違うため columns で列をそろえる（指定のない列は null）
+	colSet := map[string]bool{}
+	rows := make([]map[string]any, 0, len(batch))
+	for _, p := range batch {
+		for k := range p.staff {
+			colSet[k] = true
+		}
+		rows = append(rows, p.staff)
+	}
+	columns := make([]string, 0, len(colSet))
+	for k := range colSet {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+	q := url.Values{}
+	q.Set("columns", strings.Join(columns, ","))
+	q.Set("select", "id,sfid")
+
+	respBody, _, postErr := client.Post(ctx, "/rest/v1/staff", q, rows)
+	if postErr != nil {
+		log.Printf("DB_003: bulk insert staff failed, retrying row by row: %v", postErr)
+		for _, p := range batch {
+			createImportRow(ctx, client, lang, p)
+		}
+		return
+	}
+	var inserted []struct {
+		ID   string `json:"id"`
+		SFID *int   `json:"sfid"`
+	}
+	if err := json.Unmarshal(respBody, &inserted); err != nil {
+		log.Printf("DB_002: json decode error (staff bulk insert): %v", err)
+		for _, p := range batch {
+			p.fail(lang, apierror.CodeDBDecode)
+		}
+		return
+	}
+	idBySfid := map[int]string{}
+	for _, r := range inserted {
+		if r.SFID != nil {
+			idBySfid[*r.SFID] = r.ID
+		}
+	}
+	for _, p := range batch {
+		n, _ := p.staff["sfid"].(int)
+		p.result.ID = idBySfid[n]
+		p.result.Result = importResultCreated
+	}
+}
+
+// createImportRow 新規行を1件作成する（車両の作成と sfid の採番を含む）
+func createImportRow(ctx context.Context, client *supa.Client, lang string, p *importPlan) {
+	var carID string
+	if p.car != nil {
+		id, code := insertStaffCar(ctx, client, p.car)
+		if code != "" {
+			p.fail(lang, code)
+			return
+		}
+		carID = id
+		p.staff["vehicle"] = carID
+	}
+	_, hasSfid := p.staff["sfid"]
+	id, code := insertStaff(ctx, client, p.staff, !hasSfid)
+	if code != "" {
+		if carID != "" {
+			deleteStaffCar(ctx, client, carID)
+		}
+		p.fail(lang, code)
+		return
+	}
+	p.result.ID = id
+	if n, ok := p.staff["sfid"].(int); ok {
+		p.result.Sfid = *toStringPtrFromIntPtr(&n)
+	}
+	p.result.Result = importResultCreated
+}
+
+// updateImportRow 既存行を更新する（計画時点から他の更新があった行は更新せず CONC_412）
+func updateImportRow(ctx context.Context, client *supa.Client, lang string, p *importPlan) {
+	var createdCarID string
+	if len(p.car) > 0 && p.carID == "" {
+		id, code := insertStaffCar(ctx, client, p.car)
+		if code != "" {
+			p.fail(lang, code)
+			return
+		}
+		createdCarID = id
+		p.staff["vehicle"] = id
+	}
+	staffPatch := p.staff
+	if len(staffPatch) == 0 {
+		// 車両のみの変更でも updated_at を進める（UpdateStaffHandler と同じ）
+		staffPatch = map[string]any{"updated_at": "now"}
+	}
+	q := url.Values{}
+	q.Set("id", "eq."+p.result.ID)
+	q.Set("updated_at", "eq."+p.updatedAt)
+	q.Set("select", "id")
+	respBody, status, patchErr := client.Patch(ctx, "/rest/v1/staff", q, staffPatch)
+	code := ""
+	if patchErr != nil && status == http.StatusConflict && isVehicleTaken(respBody) {
+		code = apierror.CodeVehicleAssigned
+	} else if patchErr != nil {
+		log.Printf("DB_003: supabase patch error (import row %d): %v", p.result.Row, patchErr)
+		code = apierror.CodeDBUpdate
+	} else {
+		var rows []map[string]any
+		if err := json.Unmarshal(respBody, &rows); err != nil {
+			log.Printf("DB_002: json decode error: %v", err)
+			code = apierror.CodeDBDecode
+		} else if len(rows) == 0 {
+			code = apierror.CodePreconditionFailed
+		}
+	}
+	if code != "" {
+		if createdCarID != "" {
+			deleteStaffCar(ctx, client, createdCarID)
+		}
+		p.fail(lang, code)
+		return
+	}
+
+	if len(p.car) > 0 && p.carID != "" {
+		qcar := url.Values{}
+		qcar.Set("id", "eq."+p.carID)
+		if _, status, err := client.Patch(ctx, "/rest/v1/staff_car", qcar, p.car); err != nil {
+			if status == http.StatusConflict {
+				p.fail(lang, apierror.CodeDuplicate)
+				return
+			}
+			log.Printf("DB_003: supabase patch car error (import row %d): %v", p.result.Row, err)
+			p.fail(lang, apierror.CodeDBUpdate)
+			return
+		}
+	}
+	p.result.Result = importResultUpdated
+}
@@ -0,0 +1,363 @@
+package staff
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	apierror "nissyo/internal/apierror"
+	plate "nissyo/internal/plate"
+	validate "nissyo/internal/validate"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CSV 取り込みの上限
+const (
+	importMaxBytes = 5 << 20
+	importMaxRows  = 2000
+)
+
+// importRow CSV 1行分を更新リクエストの形にしたもの
+type importRow struct {
+	line   int // CSV 上の行番号（見出しが1行目）
+	req    UpdateStaffDetailRequest
+	raw    map[string]any    // 自由記述のまま保存する列（job_description）
+	role   string            // 役職の表記（キー・日本語名・英語名。planStaffImport で役職マスタと照合する）
+	group  string            // グループの表記（ナンバー・グループ名。planStaffImport でグループマスタと照合する）
+	labels map[string]string // UpdateStaffDetailRequest 上の項目名 → CSV の見出し
+	errs   []apierror.FieldError
+}
+
+func (r *importRow) car() *UpdateCarRequest {
+	if r.req.Car == nil {
+		r.req.Car = &UpdateCarRequest{}
+	}
+	return r.req.Car
+}
+
+func (r *importRow) setTime(day string, start bool, v string) {
+	if r.req.Schedule == nil {
+		r.req.Schedule = map[string]UpdateDay{}
+	}
+	d := r.req.Schedule[day]
+	if start {
+		d.Start = &v
+	} else {
+		d.End = &v
+	}
+	r.req.Schedule[day] = d
+}
+
+// importColumn 取り込める列の定義
+// set は変換に失敗した場合に validate の規則名と引数を返す
+type importColumn struct {
+	name    string   // 正式な見出し（staff の列名。staff_car の列は car_ 接頭辞）
+	aliases []string // 和名の見出し（DB の列コメントに合わせる）
+	field   string   // 検証エラーを見出しへ戻すための UpdateStaffDetailRequest 上の項目名
+	set     func(r *importRow, v string) (rule, param string)
+}
+
+func textColumn(name, field string, get func(r *importRow) **string, aliases ...string) importColumn {
+	return importColumn{name: name, aliases: aliases, field: field, set: func(r *importRow, v string) (string, string) {
+		*get(r) = &v
+		return "", ""
+	}}
+}
+
+func intColumn(name, field string, get func(r *importRow) **int, aliases ...string) importColumn {
+	return importColumn{name: name, aliases: aliases, field: field, set: func(r *importRow, v string) (string, string) {
+		n, err := strconv.Atoi(strings.ReplaceAll(norm.NFKC.String(v), ",", ""))
+		if err != nil {
+			return "invalid_type", "integer"
+		}
+		*get(r) = &n
+		return "", ""
+	}}
+}
+
+// rawColumn 値を変換せずにそのまま保存する列（読み出し時に mapJobTypes などで解釈する）
+func rawColumn(name string, aliases ...string) importColumn {
+	return importColumn{name: name, aliases: aliases, set: func(r *importRow, v string) (string, string) {
+		if utf8.RuneCountInString(v) > 255 {
+			return "max.string", "255"
+		}
+		r.raw[name] = v
+		return "", ""
+	}}
+}
+
+var importColumns = func() []importColumn {
+	cols := []importColumn{
+		textColumn("sfid", "sfid", func(r *importRow) **string { return &r.req.Sfid }, "スタッフID"),
+		textColumn("last_name", "lastName", func(r *importRow) **string { return &r.req.LastName }, "苗字", "姓"),
+		textColumn("first_name", "firstName", func(r *importRow) **string { return &r.req.FirstName }, "名前", "名"),
+		textColumn("last_name_furigana", "lastNameKana", func(r *importRow) **string { return &r.req.LastNameKana }, "苗字ふりがな", "姓ふりがな"),
+		textColumn("first_name_furigana", "firstNameKana", func(r *importRow) **string { return &r.req.FirstNameKana }, "名前ふりがな", "名ふりがな"),
+		textColumn("area_division", "areaDivision", func(r *importRow) **string { return &r.req.AreaDivision }, "地域区分"),
+		{name: "group_no", aliases: []string{"グループ", "group", "グループナンバー"}, field: "groupNo", set: func(r *importRow, v string) (string, string) {
+			r.group = v
+			return "", ""
+		}},
+		{name: "status", aliases: []string{"在職または退職", "在職区分"}, field: "employmentStatus", set: func(r *importRow, v string) (string, string) {
+			b, ok := importBool(v, "在職", "active", "退職", "retired")
+			if !ok {
+				return "oneof", "在職 退職 true false"
+			}
+			s := mapEmploymentStatus(&b)
+			r.req.EmploymentStatus = &s
+			return "", ""
+		}},
+		{name: "joining_date", aliases: []string{"就労日", "入社日"}, field: "employmentDate", set: func(r *importRow, v string) (string, string) {
+			d := importDate(v)
+			r.req.EmploymentDate = &d
+			return "", ""
+		}},
+		{name: "resignation_date", aliases: []string{"退職日"}, field: "retirementDate", set: func(r *importRow, v string) (string, string) {
+			d := importDate(v)
+			r.req.RetirementDate = &d
+			return "", ""
+		}},
+		{name: "position", aliases: []string{"役職"}, field: "role", set: func(r *importRow, v string) (string, string) {
+			r.role = v
+			return "", ""
+		}},
+		{name: "employment_type", aliases: []string{"雇用区分"}, field: "employmentType", set: func(r *importRow, v string) (string, string) {
+			t := mapEmploymentTypeStrict(v)
+			r.req.EmploymentType = &t
+			return "", ""
+		}},
+		rawColumn("job_description", "職務"),
+		textColumn("phone_number", "phoneNumber", func(r *importRow) **string { return &r.req.PhoneNumber }, "電話番号"),
+		textColumn("mobile_email_address", "mobileEmail", func(r *importRow) **string { return &r.req.MobileEmail }, "携帯メールアドレス"),
+		textColumn("pc_email_address", "pcEmail", func(r *importRow) **string { return &r.req.PcEmail }, "PCメールアドレス"),
+		intColumn("bath_towel", "bathTowel", func(r *importRow) **int { return &r.req.BathTowel }, "バスタオル持ち出し基礎数"),
+		intColumn("equipment", "equipment", func(r *importRow) **int { return &r.req.Equipment }, "備品持ち出し基礎数"),
+		textColumn("remarks", "remarks", func(r *importRow) **string { return &r.req.Remarks }, "備考"),
+		textColumn("car_type", "car.carType", func(r *importRow) **string { return &r.car().CarType }, "車種"),
+		textColumn("car_color", "car.color", func(r *importRow) **string { return &r.car().Color }, "色"),
+		intColumn("car_capacity", "car.capacity", func(r *importRow) **int { return &r.car().Capacity }, "定員"),
+		textColumn("car_area", "car.area", func(r *importRow) **string { return &r.car().Area }, "車ナンバー地域"),
+		textColumn("car_class_number", "car.classNumber", func(r *importRow) **string { return &r.car().ClassNumber }, "車ナンバー分類番号", "分類番号"),
+		textColumn("car_character", "car.character", func(r *importRow) **string { return &r.car().Character }, "車ナンバーひらがな"),
+		{name: "car_number", aliases: []string{"車ナンバー"}, field: "car.number", set: func(r *importRow, v string) (string, string) {
+			n, ok := plate.ParseSerial(v)
+			if !ok {
+				return "plate_serial", ""
+			}
+			r.car().Number = &n
+			return "", ""
+		}},
+		textColumn("car_plate", "car.plate", func(r *importRow) **string { return &r.car().Plate }, "ナンバープレート", "ナンバー"),
+		{name: "car_is_etc", aliases: []string{"ETC有無", "ETC"}, field: "car.isETC", set: func(r *importRow, v string) (string, string) {
+			b, ok := importBool(v, "有", "あり", "無", "なし")
+			if !ok {
+				return "oneof", "有 無 true false"
+			}
+			r.car().IsETC = &b
+			return "", ""
+		}},
+	}
+	// 曜日ごとの出勤・退勤時間
+	for i, day := range []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"} {
+		jp := []string{"月", "火", "水", "木", "金", "土", "日"}[i]
+		for _, start := range []bool{true, false} {
+			day, start := day, start
+			suffix, label, key := "_start", "出勤時間", "start"
+			if !start {
+				suffix, label, key = "_end", "退勤時間", "end"
+			}
+			cols = append(cols, importColumn{
+				name:    day + suffix,
+				aliases: []string{jp + "曜" + label},
+				field:   "schedule[" + day + "]." + key,
+				set: func(r *importRow, v string) (string, string) {
+					r.setTime(day, start, importTime(v))
+					return "", ""
+				},
+			})
+		}
+	}
+	return cols
+}()
+
+// importColumnByHeader 正規化した見出し → 列定義
+var importColumnByHeader = func() map[string]*importColumn {
+	m := map[string]*importColumn{}
+	for i := range importColumns {
+		col := &importColumns[i]
+		m[normalizeImportHeader(col.name)] = col
+		for _, a := range col.aliases {
+			m[normalizeImportHeader(a)] = col
+		}
+	}
+	return m
+}()
+
+// normalizeImportHeader 全角・半角、大文字・小文字、空白の違いを無視して見出しを比較する
+func normalizeImportHeader(h string) string {
+	h = strings.ToLower(norm.NFKC.String(strings.TrimSpace(h)))
+	h = strings.NewReplacer(" ", "", "(", "", ")", "").Replace(h)
+	return h
+}
+
+// decodeImportCSV 文字コードを判定して CSV を読み込む（encoding: auto / utf-8 / shift_jis）
+// auto は UTF-8 として正しければ UTF-8、そうでなければ Shift_JIS（Excel・FileMaker の既定）とみなす
+func decodeImportCSV(data []byte, encoding string) (string, [][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch strings.ToLower(encoding) {
+	case "", "auto":
+		if utf8.Valid(data) {
+			encoding = "utf-8"
+		} else {
+			encoding = "shift_jis"
+		}
+	case "utf-8", "utf8":
+		encoding = "utf-8"
+		if !utf8.Valid(data) {
+			return encoding, nil, fmt.Errorf("invalid UTF-8")
+		}
+	case "shift_jis", "sjis", "cp932":
+		encoding = "shift_jis"
+	default:
+		return "", nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if encoding == "shift_jis" {
+		decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+		if err != nil {
+			return encoding, nil, err
+		}
+		data = decoded
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1 // 末尾の空欄を省いた行も受け付ける
+	records, err := r.ReadAll()
+	if err != nil {
+		return encoding, nil, err
+	}
+	return encoding, records, nil
+}
+
+// mapImportHeader 見出し行を列定義に対応付ける
+// 対応しない見出しは ignored に入れて読み飛ばす。同じ列に2つの見出しが対応する場合はエラー
+func mapImportHeader(lang string, header []string) ([]*importColumn, []string, []apierror.FieldError) {
+	cols := make([]*importColumn, len(header))
+	var ignored []string
+	var errs []apierror.FieldError
+	seen := map[string]bool{}
+	for i, h := range header {
+		col, ok := importColumnByHeader[normalizeImportHeader(h)]
+		if !ok {
+			if strings.TrimSpace(h) != "" {
+				ignored = append(ignored, h)
+			}
+			continue
+		}
+		if seen[col.name] {
+			errs = append(errs, validate.NewFieldError(lang, h, "unique", ""))
+			continue
+		}
+		seen[col.name] = true
+		cols[i] = col
+	}
+	return cols, ignored, errs
+}
+
+// parseImportRow 1行分のセルを変換・検証する（空欄のセルは「変更しない」）
+func parseImportRow(lang string, line int, header []string, cols []*importColumn, rec []string) *importRow {
+	r := &importRow{line: line, raw: map[string]any{}, labels: map[string]string{}}
+	for i, col := range cols {
+		if col != nil && col.field != "" {
+			r.labels[col.field] = header[i]
+		}
+	}
+	for i, col := range cols {
+		if col == nil || i >= len(rec) {
+			continue
+		}
+		v := strings.TrimSpace(rec[i])
+		if v == "" {
+			continue
+		}
+		if rule, param := col.set(r, v); rule != "" {
+			r.errs = append(r.errs, validate.NewFieldError(lang, header[i], rule, param))
+		}
+	}
+	fieldErrs, err := validate.Struct(lang, &r.req)
+	if err != nil {
+		r.errs = append(r.errs, validate.NewFieldError(lang, "", "invalid", err.Error()))
+	}
+	r.addErrors(fieldErrs)
+	return r
+}
+
+// addErrors 検証エラーの項目名を CSV の見出しに戻して追加する
+func (r *importRow) addErrors(errs []apierror.FieldError) {
+	for _, fe := range errs {
+		if h, ok := r.labels[fe.Field]; ok {
+			fe.Field = h
+		}
+		r.errs = append(r.errs, fe)
+	}
+}
+
+// importBool true/false/1/0 と、列ごとの和語を真偽値にする
+// words は前半が真、後半が偽を表す語（例: 有, あり, 無, なし）
+func importBool(v string, words ...string) (bool, bool) {
+	v = strings.ToLower(norm.NFKC.String(v))
+	switch v {
+	case "true", "1", "yes", "○", "◯":
+		return true, true
+	case "false", "0", "no", "×":
+		return false, true
+	}
+	for i, w := range words {
+		if v == w {
+			return i < len(words)/2, true
+		}
+	}
+	return false, false
+}
+
+// importDate 2024/1/10 のような表記を YYYY-MM-DD にそろえる（時刻付きは日付部分のみ）
+// 解釈できない場合はそのまま返し、ymd の検証でエラーにする
+func importDate(v string) string {
+	v = norm.NFKC.String(v)
+	if i := strings.IndexAny(v, " T"); i > 0 {
+		v = v[:i]
+	}
+	for _, layout := range []string{"2006-1-2", "2006/1/2", "2006.1.2"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return v
+}
+
+// importTime 9:00 や 09:00:00 を HH:MM にそろえる（解釈できない場合はそのまま返し、hhmm の検証でエラーにする）
+func importTime(v string) string {
+	v = norm.NFKC.String(v)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format("15:04")
+		}
+	}
+	return v
+}
+
+// mapEmploymentTypeStrict 雇用区分の表記をキーにする（未知の値はそのまま返し、oneof の検証でエラーにする）
+func mapEmploymentTypeStrict(v string) string {
+	switch strings.ToLower(norm.NFKC.String(v)) {
+	case "正社員", "社員", "employee":
+		return "employee"
+	case "アルバイト", "パート", "part_time":
+		return "part_time"
+	}
+	return v
+}
@@ -0,0 +1,205 @@
+package staff
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	position "nissyo/internal/position"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 検索語の上限（長すぎる・多すぎる条件で PostgREST のクエリが膨らまないようにする）
+const (
+	ledgerSearchMaxTerms   = 5
+	ledgerSearchMaxTermLen = 50
+)
+
+// applyLedgerFilters 台帳一覧のクエリパラメータを PostgREST の絞り込み条件に変換して q に設定する
+// 絞り込みはすべて DB 側で行う（Go 側で行を捨てると limit と件数がずれるため）
+//
+//	areaDivision         地域区分名の完全一致（複数指定可）
+//	group                グループナンバー（複数指定可）
+//	employmentType       employee / part_time
+//	role                 役職キー（position.key。未設定のスタッフは position.DefaultKey に含める）
+//	jobType              driver / office
+//	status               active / retired / all
+//	hasVehicle           true / false
+//	etc                  true / false（車両ありの中で ETC の有無）
+//	q                    氏名・ふりがな・SFID の部分一致（空白区切りで AND）
+//
+// etc 指定時は車両の埋め込みを inner join にする必要があるため carInner=true を返す
+// positions は role 指定時のみ必要（役職マスタの一覧）
+func applyLedgerFilters(c *gin.Context, q url.Values, positions position.Positions) (carInner bool, fieldErrs []apierror.FieldError) {
+	lang := apierror.Lang(c)
+	// or / and を含む条件はまとめて and=(...) に入れる
+	var conds []string
+
+	if vals := nonEmptyQueryArray(c, "areaDivision"); len(vals) > 0 {
+		q.Set("area_division", eqOrIn(vals))
+	}
+	if vals := nonEmptyQueryArray(c, "group"); len(vals) > 0 {
+		if groupNumbersValid(vals) {
+			q.Set("group_no", eqOrIn(vals))
+		} else {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "group", "invalid_type", "int"))
+		}
+	}
+
+	switch c.Query("employmentType") {
+	case "":
+	case "employee":
+		// mapEmploymentType はアルバイト以外（未設定含む）を正社員とみなす
+		conds = append(conds, "or(employment_type.is.null,employment_type.not.in.(アルバイト,part_time))")
+	case "part_time":
+		q.Set("employment_type", "in.(アルバイト,part_time)")
+	default:
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "employmentType", "oneof", "employee part_time"))
+	}
+
+	if role := c.Query("role"); role != "" {
+		cond, ok := ledgerRoleFilter(role, positions)
+		if ok {
+			conds = append(conds, cond)
+		} else {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "role", "oneof", strings.Join(positions.Keys(), " ")))
+		}
+	}
+
+	switch c.Query("jobType") {
+	case "":
+	case "driver":
+		conds = append(conds, `or(job_description.ilike."*送迎*",job_description.ilike."*driver*")`)
+	case "office":
+		// mapJobTypes は送迎・事務のどちらも含まない場合も office とする
+		conds = append(conds, `or(job_description.is.null,job_description.ilike."*事務*",job_description.ilike."*office*",`+
+			`and(job_description.not.ilike."*送迎*",job_description.not.ilike."*driver*"))`)
+	default:
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "jobType", "oneof", "driver office"))
+	}
+
+	switch c.Query("status") {
+	case "active":
+		q.Set("status", "eq.true")
+	case "retired":
+		q.Set("status", "eq.false")
+	case "", "all":
+	default:
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "status", "oneof", "active retired all"))
+	}
+
+	switch c.Query("hasVehicle") {
+	case "":
+	case "true":
+		q.Set("vehicle", "not.is.null")
+	case "false":
+		q.Set("vehicle", "is.null")
+	default:
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "hasVehicle", "oneof", "true false"))
+	}
+
+	switch c.Query("etc") {
+	case "":
+	case "true":
+		carInner = true
+		q.Set("staff_car.is_etc", "is.true")
+	case "false":
+		carInner = true
+		q.Set("staff_car.is_etc", "not.is.true")
+	default:
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "etc", "oneof", "true false"))
+	}
+
+	if text := strings.TrimSpace(c.Query("q")); text != "" {
+		terms := strings.Fields(text)
+		if len(terms) > ledgerSearchMaxTerms {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "q", "max", strconv.Itoa(ledgerSearchMaxTerms)))
+		} else {
+			for _, t := range terms {
+				if len([]rune(t)) > ledgerSearchMaxTermLen {
+					fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "q", "max.string", strconv.Itoa(ledgerSearchMaxTermLen)))
+					break
+				}
+				conds = append(conds, ledgerSearchTerm(t))
+			}
+		}
+	}
+
+	if len(conds) > 0 {
+		q.Set("and", "("+strings.Join(conds, ",")+")")
+	}
+	return carInner, fieldErrs
+}
+
+// ledgerRoleFilter 役職キーを position_id の条件にする（マスタに無いキーは ok=false）
+func ledgerRoleFilter(role string, positions position.Positions) (string, bool) {
+	p, ok := positions.ByKey(role)
+	if !ok {
+		return "", false
+	}
+	if p.Key == position.DefaultKey {
+		return "or(position_id.is.null,position_id.eq." + p.ID + ")", true
+	}
+	return "position_id.eq." + p.ID, true
+}
+
+// groupNumbersValid グループナンバーの絞り込みがすべて整数か
+func groupNumbersValid(vals []string) bool {
+	for _, v := range vals {
+		if _, err := strconv.Atoi(v); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ledgerSearchTerm 検索語1つ分の条件（氏名・ふりがなの部分一致、数字なら SFID の一致も含める）
+func ledgerSearchTerm(term string) string {
+	pat := pgQuote("*" + supa.EscapeLike(term) + "*")
+	cols := []string{
+		"last_name.ilike." + pat,
+		"first_name.ilike." + pat,
+		"last_name_furigana.ilike." + pat,
+		"first_name_furigana.ilike." + pat,
+	}
+	if n, err := strconv.Atoi(term); err == nil && n >= 0 {
+		cols = append(cols, "sfid.eq."+strconv.Itoa(n))
+	}
+	return "or(" + strings.Join(cols, ",") + ")"
+}
+
+// pgQuote PostgREST の論理演算・in 条件の中で使う値を二重引用符で囲む（カンマや括弧を含んでもよいようにする）
+func pgQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// eqOrIn 値が1つなら eq、複数なら in の条件にする
+func eqOrIn(vals []string) string {
+	if len(vals) == 1 {
+		return "eq." + vals[0]
+	}
+	quoted := make([]string, len(vals))
+	for i, v := range vals {
+		quoted[i] = pgQuote(v)
+	}
+	return "in.(" + strings.Join(quoted, ",") + ")"
+}
+
+// nonEmptyQueryArray 同名パラメータの繰り返しとカンマ区切りの両方を受け付け、空の値を除いて返す
+func nonEmptyQueryArray(c *gin.Context, key string) []string {
+	var out []string
+	for _, raw := range c.QueryArray(key) {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
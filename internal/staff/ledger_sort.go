@@ -0,0 +1,146 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ledgerLimit 台帳の最大件数
+const ledgerLimit = 200
+
+// ledgerPageSize Go で並べ替えるために全件を取得するときの1回の件数
+const ledgerPageSize = 1000
+
+// applyLedgerSort sort パラメータを並び順に変換する（先頭に - を付けると降順）
+//
+//	displayOrder（既定）, sfid, joiningDate, area  PostgREST の order で並べる
+//	kana, role                                      DB では表現できないため取得後に Go で並べ替える（role は役職マスタの序列）
+//
+// Go で並べ替える場合はその関数を返す（同順位は表示順のまま）。条件に合う全件を並べ替えてから先頭を返すこと
+func applyLedgerSort(c *gin.Context, q url.Values) (func([]StaffDTO), *apierror.FieldError) {
+	key := strings.TrimSpace(c.Query("sort"))
+	desc := strings.HasPrefix(key, "-")
+	key = strings.TrimPrefix(key, "-")
+	dir := "asc"
+	if desc {
+		dir = "desc"
+	}
+
+	switch key {
+	case "", "displayOrder":
+		q.Set("order", "display_order."+dir+".nullslast,created_at."+dir)
+	case "sfid":
+		q.Set("order", "sfid."+dir+".nullslast")
+	case "joiningDate":
+		q.Set("order", "joining_date."+dir+".nullslast,display_order.asc")
+	case "area":
+		q.Set("order", "area_division."+dir+".nullslast,display_order.asc")
+	case "kana":
+		q.Set("order", "display_order.asc.nullslast,created_at.asc,id.asc")
+		return func(rows []StaffDTO) {
+			keys := make(map[string][2]string, len(rows))
+			for _, s := range rows {
+				keys[s.ID] = kanaSortKey(coalesce(s.LastNameFurigana, "") + coalesce(s.FirstNameFurigana, ""))
+			}
+			sort.SliceStable(rows, func(i, j int) bool {
+				a, b := keys[rows[i].ID], keys[rows[j].ID]
+				// ふりがな未設定は昇順・降順とも末尾
+				if (a[0] == "") != (b[0] == "") {
+					return b[0] == ""
+				}
+				if a == b {
+					return false
+				}
+				less := a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+				return less != desc
+			})
+		}, nil
+	case "role":
+		q.Set("order", "display_order.asc.nullslast,created_at.asc,id.asc")
+		return func(rows []StaffDTO) {
+			sort.SliceStable(rows, func(i, j int) bool {
+				a, b := rows[i].roleRank(), rows[j].roleRank()
+				if desc {
+					return a > b
+				}
+				return a < b
+			})
+		}, nil
+	default:
+		fe := validate.NewFieldError(apierror.Lang(c), "sort", "oneof", "displayOrder sfid kana joiningDate role area")
+		return nil, &fe
+	}
+	return nil, nil
+}
+
+// getLedgerRows 台帳の行を取得する。失敗時はエラーコードを返す（ログ出力済み）
+func getLedgerRows(ctx context.Context, client *supa.Client, q url.Values) ([]StaffDTO, string) {
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []StaffDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	return rows, ""
+}
+
+// getAllLedgerRows 条件に合う台帳の行をすべて取得する（ledgerPageSize 件ずつ。順序は q の order で固定する）
+func getAllLedgerRows(ctx context.Context, client *supa.Client, q url.Values) ([]StaffDTO, string) {
+	var all []StaffDTO
+	for offset := 0; ; offset += ledgerPageSize {
+		q.Set("limit", strconv.Itoa(ledgerPageSize))
+		q.Set("offset", strconv.Itoa(offset))
+		rows, code := getLedgerRows(ctx, client, q)
+		if code != "" {
+			return nil, code
+		}
+		all = append(all, rows...)
+		if len(rows) < ledgerPageSize {
+			return all, ""
+		}
+	}
+}
+
+// kanaSortKey ふりがなを五十音順で比べるためのキー
+// [0] は清音・大きい仮名にそろえた主キー（が→か、ゃ→や）、[1] は濁点などを残した副キー
+// 半角カナ・カタカナはひらがなに寄せる
+func kanaSortKey(s string) [2]string {
+	s = norm.NFKC.String(strings.TrimSpace(s))
+	var primary, secondary strings.Builder
+	for _, r := range s {
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ'
+		}
+		secondary.WriteRune(r)
+	}
+	for _, r := range norm.NFD.String(secondary.String()) {
+		switch r {
+		case '\u3099', '\u309a', ' ':
+			continue
+		case 'ぁ', 'ぃ', 'ぅ', 'ぇ', 'ぉ', 'っ', 'ゃ', 'ゅ', 'ょ', 'ゎ':
+			r++
+		case 'ゕ':
+			r = 'か'
+		case 'ゖ':
+			r = 'け'
+		}
+		primary.WriteRune(r)
+	}
+	return [2]string{primary.String(), strings.ReplaceAll(secondary.String(), " ", "")}
+}
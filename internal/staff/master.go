@@ -0,0 +1,114 @@
+package staff
+
+import (
+	"context"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	area "nissyo/internal/area"
+	group "nissyo/internal/group"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+)
+
+// 地域区分・グループマスタの埋め込み（staff.area_division / staff.group_no）
+const (
+	areaSelect  = "area:area_division(name,name_en)"
+	groupSelect = "group:group_no(number,name)"
+)
+
+// areaLabel 言語に応じた地域区分名（埋め込みが無い・未設定は nil）
+func (s StaffDTO) areaLabel(lang string) *string {
+	if s.Area == nil {
+		return nil
+	}
+	v := s.Area.Label(lang)
+	return &v
+}
+
+// groupName グループ名（埋め込みが無い・未設定は nil）
+func (s StaffDTO) groupName() *string {
+	if s.Group == nil {
+		return nil
+	}
+	return &s.Group.Name
+}
+
+// masterPatch 役職・地域区分・グループの指定をマスタで確かめて staff の更新にする（作成・更新で共用）
+// マスタに無い値は検証エラー（まとめて返す）。失敗時はエラーコードを返す（ログ出力済み）
+func masterPatch(ctx context.Context, client *supa.Client, lang string, req *UpdateStaffDetailRequest) (map[string]any, []apierror.FieldError, string) {
+	patch := map[string]any{}
+	var fieldErrs []apierror.FieldError
+
+	p, errs, code := rolePatch(ctx, client, lang, req.Role)
+	if code != "" {
+		return nil, nil, code
+	}
+	for k, v := range p {
+		patch[k] = v
+	}
+	fieldErrs = append(fieldErrs, errs...)
+
+	p, errs, code = areaPatch(ctx, client, lang, req.AreaDivision)
+	if code != "" {
+		return nil, nil, code
+	}
+	for k, v := range p {
+		patch[k] = v
+	}
+	fieldErrs = append(fieldErrs, errs...)
+
+	p, errs, code = groupPatch(ctx, client, lang, req.GroupNo)
+	if code != "" {
+		return nil, nil, code
+	}
+	for k, v := range p {
+		patch[k] = v
+	}
+	fieldErrs = append(fieldErrs, errs...)
+
+	return patch, fieldErrs, ""
+}
+
+// areaPatch 地域区分を staff.area_division の更新にする（空文字は未設定に戻す）
+// 表記揺れ（全角半角・英語名）はマスタの名前に揃える。マスタに無い名前は検証エラー
+func areaPatch(ctx context.Context, client *supa.Client, lang string, name *string) (map[string]any, []apierror.FieldError, string) {
+	if name == nil {
+		return nil, nil, ""
+	}
+	if strings.TrimSpace(*name) == "" {
+		return map[string]any{"area_division": nil}, nil, ""
+	}
+	as, code := area.List(ctx, client)
+	if code != "" {
+		return nil, nil, code
+	}
+	a, ok := as.Match(*name)
+	if !ok {
+		return nil, []apierror.FieldError{
+			validate.NewFieldError(lang, "areaDivision", "oneof", strings.Join(as.Names(), " ")),
+		}, ""
+	}
+	return map[string]any{"area_division": a.Name}, nil, ""
+}
+
+// groupPatch グループナンバーを staff.group_no の更新にする（0 は未設定に戻す）
+// マスタに無いナンバーは検証エラー
+func groupPatch(ctx context.Context, client *supa.Client, lang string, number *int) (map[string]any, []apierror.FieldError, string) {
+	if number == nil {
+		return nil, nil, ""
+	}
+	if *number == 0 {
+		return map[string]any{"group_no": nil}, nil, ""
+	}
+	gs, code := group.List(ctx, client)
+	if code != "" {
+		return nil, nil, code
+	}
+	if _, ok := gs.ByNumber(*number); !ok {
+		return nil, []apierror.FieldError{
+			validate.NewFieldError(lang, "groupNo", "exists", ""),
+		}, ""
+	}
+	return map[string]any{"group_no": *number}, nil, ""
+}
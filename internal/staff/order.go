@@ -0,0 +1,100 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReorderStaffRequest 表示順の変更
+// ids を指定順のまま beforeId の直前へ移動する（beforeId 省略時は末尾）。全件を渡せば全体の並べ替えになる
+type ReorderStaffRequest struct {
+	IDs      []string `json:"ids" binding:"required,min=1,max=2000,unique,dive,uuid"`
+	BeforeID *string  `json:"beforeId" binding:"omitempty,eq=|uuid"`
+}
+
+type StaffOrderItem struct {
+	ID           string `json:"id"`
+	DisplayOrder int    `json:"displayOrder"`
+}
+
+type ReorderStaffResponse struct {
+	Order []StaffOrderItem `json:"order"`
+}
+
+// ReorderStaffHandler スタッフ台帳の表示順を変更する（PUT /api/staff/order）
+// 並べ替えは DB 関数 reorder_staff がロックを取って行うため、同時に並べ替えても番号が重複しない
+// 表示順の変更では各スタッフの ETag は変わらない
+func ReorderStaffHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req ReorderStaffRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	var beforeID any
+	if req.BeforeID != nil && strings.TrimSpace(*req.BeforeID) != "" {
+		before := strings.TrimSpace(*req.BeforeID)
+		for _, id := range req.IDs {
+			if strings.EqualFold(id, before) {
+				fieldErrs = append(fieldErrs, validate.NewFieldError(apierror.Lang(c), "beforeId", "invalid", "ids"))
+				break
+			}
+		}
+		beforeID = before
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	body, status, rpcErr := client.RPC(ctx, "reorder_staff", map[string]any{
+		"p_ids":       req.IDs,
+		"p_before_id": beforeID,
+	})
+	if rpcErr != nil {
+		// P0002（存在しない id）は PostgREST が 404 で返す
+		if status == http.StatusNotFound {
+			apierror.Respond(c, apierror.CodeNotFound)
+			return
+		}
+		log.Printf("DB_003: reorder_staff error: %v body=%s", rpcErr, string(body))
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+
+	var rows []struct {
+		ID           string `json:"id"`
+		DisplayOrder *int   `json:"display_order"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (reorder_staff): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	resp := ReorderStaffResponse{Order: make([]StaffOrderItem, 0, len(rows))}
+	for i, r := range rows {
+		resp.Order = append(resp.Order, StaffOrderItem{ID: r.ID, DisplayOrder: displayOrder(r.DisplayOrder, i)})
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,191 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	plate "nissyo/internal/plate"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// plateColumns ナンバーを構成する staff_car の列
+var plateColumns = []string{"area", "class_number", "character", "number"}
+
+// plate 登録されているナンバー（未設定の部分はゼロ値）
+func (car StaffCarDTO) plate() plate.Plate {
+	p := plate.Plate{
+		Region: coalesce(car.Area, ""),
+		Class:  coalesce(car.ClassNumber, ""),
+		Kana:   coalesce(car.Character, ""),
+	}
+	if car.Number != nil {
+		p.Serial = *car.Number
+	}
+	return p
+}
+
+// plateLabel 表示用のナンバー（例: 京都 500 あ 12-34。未登録は nil）
+func (car StaffCarDTO) plateLabel() *string {
+	p := car.plate()
+	if p.IsZero() {
+		return nil
+	}
+	s := p.String()
+	return &s
+}
+
+// normalizePlate plate の指定を各部分に分け、各部分の表記を揃える（検証後に呼ぶ）
+// plate が空文字の場合はナンバーの各部分を未設定に戻す（空文字・0 は buildCarRow などで null にする）
+func (car *UpdateCarRequest) normalizePlate() {
+	if car.Plate != nil {
+		var p plate.Plate
+		if strings.TrimSpace(*car.Plate) != "" {
+			p, _ = plate.Parse(*car.Plate)
+		}
+		car.Area, car.ClassNumber, car.Character, car.Number = &p.Region, &p.Class, &p.Kana, &p.Serial
+		car.Plate = nil
+		return
+	}
+	if car.Area != nil {
+		v := plate.NormalizeRegion(*car.Area)
+		car.Area = &v
+	}
+	if car.ClassNumber != nil {
+		v := plate.NormalizeClass(*car.ClassNumber)
+		car.ClassNumber = &v
+	}
+	if car.Character != nil {
+		v := plate.NormalizeKana(*car.Character)
+		car.Character = &v
+	}
+}
+
+// clearEmptyPlate ナンバーの列の空文字・0 を null にする
+func clearEmptyPlate(row map[string]any) {
+	for _, k := range plateColumns {
+		switch v := row[k].(type) {
+		case string:
+			if v == "" {
+				row[k] = nil
+			}
+		case int:
+			if v == 0 {
+				row[k] = nil
+			}
+		}
+	}
+}
+
+// patchedPlate 更新後のナンバー（cur に staff_car の更新 row を重ねる。cur が nil は新規作成）
+// row にナンバーの列が無い場合は changed=false
+func patchedPlate(cur *StaffCarDTO, row map[string]any) (p plate.Plate, changed bool) {
+	if cur != nil {
+		p = cur.plate()
+	}
+	for _, k := range plateColumns {
+		v, ok := row[k]
+		if !ok {
+			continue
+		}
+		changed = true
+		s, _ := v.(string)
+		switch k {
+		case "area":
+			p.Region = s
+		case "class_number":
+			p.Class = s
+		case "character":
+			p.Kana = s
+		case "number":
+			n, _ := v.(int)
+			p.Serial = n
+		}
+	}
+	return p, changed
+}
+
+// checkPlateAvailable ナンバーが他の車両に登録されていないか確かめる
+// 重複は 409 を応答済みで ok=false。excludeID は更新中の車両（新規は空）
+func checkPlateAvailable(c *gin.Context, ctx context.Context, client *supa.Client, p plate.Plate, excludeID string) bool {
+	taken, code := plateTaken(ctx, client, p, excludeID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return false
+	}
+	if taken {
+		respondPlateDuplicate(c)
+		return false
+	}
+	return true
+}
+
+// plateTaken 同じナンバーの車両が他にあるか（地名・ひらがな・一連指定番号がそろう場合のみ調べる）
+// 分類番号はどちらかが未登録なら一致とみなす。失敗時はエラーコードを返す（ログ出力済み）
+func plateTaken(ctx context.Context, client *supa.Client, p plate.Plate, excludeID string) (bool, string) {
+	if !p.Complete() {
+		return false, ""
+	}
+	q := url.Values{}
+	q.Set("select", "id")
+	q.Set("area", "eq."+p.Region)
+	q.Set("character", "eq."+p.Kana)
+	q.Set("number", "eq."+strconv.Itoa(p.Serial))
+	if p.Class != "" {
+		q.Set("or", "(class_number.is.null,class_number.eq."+p.Class+")")
+	}
+	if excludeID != "" {
+		q.Set("id", "neq."+excludeID)
+	}
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_car", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_car error: %v", getErr)
+		return false, apierror.CodeDBInit
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_car): %v", err)
+		return false, apierror.CodeDBDecode
+	}
+	return len(rows) > 0, ""
+}
+
+// respondPlateDuplicate ナンバーの重複（staff_car_plate_key の一意制約違反を含む）
+func respondPlateDuplicate(c *gin.Context) {
+	apierror.RespondWith(c, apierror.CodeDuplicate, "", []apierror.FieldError{
+		validate.NewFieldError(apierror.Lang(c), "plate", "unique", ""),
+	})
+}
+
+// applyPlateQuery ナンバーの検索語（?plate=）を staff_car の条件にする
+// ナンバーとして読めない検索語は検証エラー
+func applyPlateQuery(lang string, q url.Values, s string) *apierror.FieldError {
+	p := plate.ParseQuery(s)
+	if p.IsZero() {
+		fe := validate.NewFieldError(lang, "plate", "plate", "")
+		return &fe
+	}
+	if p.Region != "" {
+		q.Set("area", "eq."+p.Region)
+	}
+	if p.Class != "" {
+		q.Set("class_number", "eq."+p.Class)
+	}
+	if p.Kana != "" {
+		q.Set("character", "eq."+p.Kana)
+	}
+	if p.Serial > 0 {
+		q.Set("number", "eq."+strconv.Itoa(p.Serial))
+	}
+	return nil
+}
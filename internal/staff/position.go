@@ -0,0 +1,68 @@
+package staff
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	apierror "nissyo/internal/apierror"
+	position "nissyo/internal/position"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+)
+
+// positionSelect 役職マスタの埋め込み（staff.position_id）
+const positionSelect = "position:position_id(id,key,name_ja,name_en,rank)"
+
+// roleKey スタッフの役職キー（未設定は position.DefaultKey）
+func (s StaffDTO) roleKey() string {
+	if s.Position != nil {
+		return s.Position.Key
+	}
+	return position.DefaultKey
+}
+
+// roleRank 役職の序列（小さいほど上位。未設定は最後）
+func (s StaffDTO) roleRank() int {
+	if s.Position != nil {
+		return s.Position.Rank
+	}
+	return math.MaxInt
+}
+
+// attachPosition 役職の埋め込みが無い行（履歴のスナップショット）に役職マスタの内容を入れる
+// 役職マスタから消えた役職は false を返す（行の役職は未設定のまま）
+func (s *StaffDTO) attachPosition(ps position.Positions) bool {
+	if s.PositionID == nil || s.Position != nil {
+		return true
+	}
+	p, ok := ps.ByID(*s.PositionID)
+	if !ok {
+		return false
+	}
+	s.Position = &p
+	return true
+}
+
+// rolePatch 役職キーを staff.position_id の更新にする（空文字は未設定に戻す）
+// 役職マスタに無いキーは検証エラー。role が nil なら何もしない
+func rolePatch(ctx context.Context, client *supa.Client, lang string, role *string) (map[string]any, []apierror.FieldError, string) {
+	if role == nil {
+		return nil, nil, ""
+	}
+	key := strings.TrimSpace(*role)
+	if key == "" {
+		return map[string]any{"position_id": nil}, nil, ""
+	}
+	ps, code := position.List(ctx, client)
+	if code != "" {
+		return nil, nil, code
+	}
+	p, ok := ps.ByKey(key)
+	if !ok {
+		return nil, []apierror.FieldError{
+			validate.NewFieldError(lang, "role", "oneof", strings.Join(ps.Keys(), " ")),
+		}, ""
+	}
+	return map[string]any{"position_id": p.ID}, nil, ""
+}
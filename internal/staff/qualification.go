@@ -0,0 +1,638 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 免許・資格の種類
+const (
+	qualificationDriverLicense  = "driver_license"   // 運転免許
+	qualificationDriverLicense2 = "driver_license_2" // 第二種運転免許
+	qualificationOther          = "other"            // その他の資格
+)
+
+// driverLicenseTypes 送迎に必要な運転免許として扱う種類
+var driverLicenseTypes = []string{qualificationDriverLicense, qualificationDriverLicense2}
+
+// 有効期限の状態（staff_qualification.expiry_status）
+const (
+	expiryValid    = "valid"
+	expiryExpiring = "expiring" // 期限まで QUALIFICATION_ALERT_DAYS 日以内
+	expiryExpired  = "expired"
+)
+
+// defaultQualificationAlertDays 期限間近とみなす日数の既定値
+const defaultQualificationAlertDays = 30
+
+var qualificationSelect = "id,staff_id,type,name,number,issued_on,expires_on,document_ref,expiry_status,flagged_at,created_at,updated_at"
+
+// QualificationDTO staff_qualification の行
+type QualificationDTO struct {
+	ID           string  `json:"id"`
+	StaffID      string  `json:"staff_id,omitempty"`
+	Type         string  `json:"type"`
+	Name         *string `json:"name"`
+	Number       *string `json:"number"`
+	IssuedOn     *string `json:"issued_on"`
+	ExpiresOn    *string `json:"expires_on"`
+	DocumentRef  *string `json:"document_ref"`
+	ExpiryStatus string  `json:"expiry_status"`
+	FlaggedAt    *string `json:"flagged_at"`
+	CreatedAt    *string `json:"created_at,omitempty"`
+	UpdatedAt    *string `json:"updated_at,omitempty"`
+}
+
+// QualificationResponse 免許・資格の API の形
+type QualificationResponse struct {
+	ID           string  `json:"id"`
+	StaffID      string  `json:"staffId"`
+	Type         string  `json:"type"`
+	Name         *string `json:"name,omitempty"`
+	Number       *string `json:"number,omitempty"`
+	IssuedOn     *string `json:"issuedOn,omitempty"`
+	ExpiresOn    *string `json:"expiresOn,omitempty"`
+	DocumentRef  *string `json:"documentRef,omitempty"`
+	ExpiryStatus string  `json:"expiryStatus"`       // valid / expiring / expired（本日時点）
+	DaysLeft     *int    `json:"daysLeft,omitempty"` // 有効期限までの日数（期限切れは負。期限の無い資格は省略）
+	CreatedAt    string  `json:"createdAt,omitempty"`
+	UpdatedAt    string  `json:"updatedAt,omitempty"`
+	Version      string  `json:"version"` // 楽観的排他制御用（ETag と同じ値）
+}
+
+type QualificationListResponse struct {
+	StaffID        string                  `json:"staffId"`
+	Qualifications []QualificationResponse `json:"qualifications"`
+}
+
+// QualificationAlert 期限間近・期限切れの免許・資格（在職中のスタッフのみ）
+type QualificationAlert struct {
+	QualificationResponse
+	Staff VehicleDriver `json:"staff"`
+}
+
+// CreateQualificationRequest 免許・資格の登録
+type CreateQualificationRequest struct {
+	Type        string  `json:"type" binding:"required,oneof=driver_license driver_license_2 other"`
+	Name        *string `json:"name" binding:"omitempty,max=100"` // other は必須
+	Number      *string `json:"number" binding:"omitempty,max=50"`
+	IssuedOn    *string `json:"issuedOn" binding:"omitempty,ymd"`
+	ExpiresOn   *string `json:"expiresOn" binding:"omitempty,ymd"`
+	DocumentRef *string `json:"documentRef" binding:"omitempty,max=1000"` // 写しの保存先（ストレージのパスまたは URL）
+}
+
+// UpdateQualificationRequest 免許・資格の部分更新（指定した項目のみ。空文字で未設定に戻す）
+type UpdateQualificationRequest struct {
+	Type        *string `json:"type" binding:"omitempty,oneof=driver_license driver_license_2 other"`
+	Name        *string `json:"name" binding:"omitempty,max=100"`
+	Number      *string `json:"number" binding:"omitempty,max=50"`
+	IssuedOn    *string `json:"issuedOn" binding:"omitempty,eq=|ymd"`
+	ExpiresOn   *string `json:"expiresOn" binding:"omitempty,eq=|ymd"`
+	DocumentRef *string `json:"documentRef" binding:"omitempty,max=1000"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
+}
+
+// GetQualificationsHandler スタッフの免許・資格の一覧（GET /api/staff/:id/qualifications。種類・有効期限順）
+func GetQualificationsHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	if code := ensureStaffExists(ctx, client, id); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", qualificationSelect)
+	q.Set("staff_id", "eq."+id)
+	q.Set("order", "type.asc,expires_on.desc.nullsfirst,created_at.asc")
+	q.Set("limit", "200")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_qualification", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_qualification error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []QualificationDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_qualification): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	today := dayStart(time.Now().In(jst))
+	out := make([]QualificationResponse, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, buildQualificationResponse(r, today))
+	}
+	c.JSON(http.StatusOK, QualificationListResponse{StaffID: id, Qualifications: out})
+}
+
+// GetQualificationDetailHandler 免許・資格1件（GET /api/staff/:id/qualifications/:qualificationId）
+func GetQualificationDetailHandler(c *gin.Context) {
+	id, qid := c.Param("id"), c.Param("qualificationId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(qid) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	row, found, code := fetchQualification(ctx, client, id, qid)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildQualificationResponse(row, dayStart(time.Now().In(jst)))
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateQualificationHandler 免許・資格を登録する（POST /api/staff/:id/qualifications）
+func CreateQualificationHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req CreateQualificationRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if len(fieldErrs) == 0 {
+		if req.Type == qualificationOther && strings.TrimSpace(coalesce(req.Name, "")) == "" {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "name", "required", ""))
+		}
+		if !datesInOrder(req.IssuedOn, req.ExpiresOn) {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "expiresOn", "date_order", ""))
+		}
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	if code := ensureStaffExists(ctx, client, id); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	row := map[string]any{
+		"staff_id":     id,
+		"type":         req.Type,
+		"name":         emptyToNil(req.Name),
+		"number":       emptyToNil(req.Number),
+		"issued_on":    emptyToNil(req.IssuedOn),
+		"expires_on":   emptyToNil(req.ExpiresOn),
+		"document_ref": emptyToNil(req.DocumentRef),
+	}
+	setExpiryStatus(row, req.ExpiresOn, dayStart(time.Now().In(jst)))
+	q := url.Values{}
+	q.Set("select", qualificationSelect)
+	body, _, postErr := client.Post(ctx, "/rest/v1/staff_qualification", q, row)
+	if postErr != nil {
+		log.Printf("DB_003: supabase insert staff_qualification error: %v", postErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []QualificationDTO
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) == 0 {
+		log.Printf("DB_002: json decode error (staff_qualification insert): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	resp := buildQualificationResponse(rows[0], dayStart(time.Now().In(jst)))
+	etag.Set(c, resp.Version)
+	c.Header("Location", "/api/staff/"+id+"/qualifications/"+resp.ID)
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateQualificationHandler 免許・資格を部分更新する（PATCH /api/staff/:id/qualifications/:qualificationId）
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+// 有効期限を更新すると期限の状態も本日時点で判定し直す（更新後の免許で送迎の割り当てができる）
+func UpdateQualificationHandler(c *gin.Context) {
+	id, qid := c.Param("id"), c.Param("qualificationId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(qid) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req UpdateQualificationRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchQualification(ctx, client, id, qid)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	today := dayStart(time.Now().In(jst))
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, buildQualificationResponse(current, today)) {
+		return
+	}
+
+	// 更新後の値で種類と日付の組み合わせを確かめる
+	lang := apierror.Lang(c)
+	typ, name := current.Type, current.Name
+	if req.Type != nil {
+		typ = *req.Type
+	}
+	if req.Name != nil {
+		name = req.Name
+	}
+	issued, expires := current.IssuedOn, current.ExpiresOn
+	if req.IssuedOn != nil {
+		issued = req.IssuedOn
+	}
+	if req.ExpiresOn != nil {
+		expires = req.ExpiresOn
+	}
+	if typ == qualificationOther && strings.TrimSpace(coalesce(name, "")) == "" {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "name", "required", ""))
+	}
+	if !datesInOrder(issued, expires) {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "expiresOn", "date_order", ""))
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	patch := map[string]any{}
+	if req.Type != nil {
+		patch["type"] = *req.Type
+	}
+	if req.Name != nil {
+		patch["name"] = emptyToNil(req.Name)
+	}
+	if req.Number != nil {
+		patch["number"] = emptyToNil(req.Number)
+	}
+	if req.IssuedOn != nil {
+		patch["issued_on"] = emptyToNil(req.IssuedOn)
+	}
+	if req.ExpiresOn != nil {
+		patch["expires_on"] = emptyToNil(req.ExpiresOn)
+		setExpiryStatus(patch, req.ExpiresOn, today)
+	}
+	if req.DocumentRef != nil {
+		patch["document_ref"] = emptyToNil(req.DocumentRef)
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+qid)
+	q.Set("staff_id", "eq."+id)
+	q.Set("updated_at", "eq."+coalesce(current.UpdatedAt, ""))
+	q.Set("select", qualificationSelect)
+	body, _, patchErr := client.Patch(ctx, "/rest/v1/staff_qualification", q, patch)
+	if patchErr != nil {
+		log.Printf("DB_003: supabase patch staff_qualification error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []QualificationDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(rows) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchQualification(ctx, client, id, qid); code == "" && found {
+			resp := buildQualificationResponse(latest, today)
+			etag.Conflict(c, resp.Version, resp)
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+	resp := buildQualificationResponse(rows[0], today)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteQualificationHandler 免許・資格を削除する（DELETE /api/staff/:id/qualifications/:qualificationId）
+func DeleteQualificationHandler(c *gin.Context) {
+	id, qid := c.Param("id"), c.Param("qualificationId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(qid) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+qid)
+	q.Set("staff_id", "eq."+id)
+	body, _, delErr := client.Delete(ctx, "/rest/v1/staff_qualification", q)
+	if delErr != nil {
+		log.Printf("DB_003: supabase delete staff_qualification error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []QualificationDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_qualification delete): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(rows) == 0 {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetQualificationAlertsHandler 期限間近・期限切れの免許・資格（GET /api/qualifications/alerts。有効期限順）
+// 毎日の確認で付けた状態（expiry_status）で絞る。在職中のスタッフのみ
+//
+//	status=expiring,expired   対象の状態（既定は両方）
+//	type=driver_license       種類で絞る（カンマ区切り）
+func GetQualificationAlertsHandler(c *gin.Context) {
+	lang := apierror.Lang(c)
+	var fieldErrs []apierror.FieldError
+	statuses := splitList(c.Query("status"))
+	if len(statuses) == 0 {
+		statuses = []string{expiryExpiring, expiryExpired}
+	}
+	for _, s := range statuses {
+		if s != expiryExpiring && s != expiryExpired {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "status", "oneof", "expiring expired"))
+			break
+		}
+	}
+	types := splitList(c.Query("type"))
+	for _, t := range types {
+		if t != qualificationDriverLicense && t != qualificationDriverLicense2 && t != qualificationOther {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "type", "oneof", "driver_license driver_license_2 other"))
+			break
+		}
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", qualificationSelect+",staff!inner(id,sfid,last_name,first_name,status)")
+	q.Set("staff.status", "is.true")
+	q.Set("expiry_status", "in.("+strings.Join(statuses, ",")+")")
+	if len(types) > 0 {
+		q.Set("type", "in.("+strings.Join(types, ",")+")")
+	}
+	q.Set("order", "expires_on.asc,created_at.asc")
+	q.Set("limit", "1000")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_qualification", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_qualification error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []struct {
+		QualificationDTO
+		Staff vehicleStaffDTO `json:"staff"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_qualification): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	today := dayStart(time.Now().In(jst))
+	out := make([]QualificationAlert, 0, len(rows))
+	for _, r := range rows {
+		out = append(out, QualificationAlert{
+			QualificationResponse: buildQualificationResponse(r.QualificationDTO, today),
+			Staff: VehicleDriver{
+				ID:   r.Staff.ID,
+				Sfid: coalesce(toStringPtrFromIntPtr(r.Staff.SFID), ""),
+				Name: strings.TrimSpace(coalesce(r.Staff.LastName, "") + " " + coalesce(r.Staff.FirstName, "")),
+			},
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// fetchQualification スタッフの免許・資格1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchQualification(ctx context.Context, client *supa.Client, staffID, id string) (QualificationDTO, bool, string) {
+	q := url.Values{}
+	q.Set("select", qualificationSelect)
+	q.Set("id", "eq."+id)
+	q.Set("staff_id", "eq."+staffID)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_qualification", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_qualification error: %v", getErr)
+		return QualificationDTO{}, false, apierror.CodeDBInit
+	}
+	var rows []QualificationDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_qualification): %v", err)
+		return QualificationDTO{}, false, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return QualificationDTO{}, false, ""
+	}
+	return rows[0], true, ""
+}
+
+// qualificationAlertDays 期限間近とみなす日数（QUALIFICATION_ALERT_DAYS）
+func qualificationAlertDays() int {
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("QUALIFICATION_ALERT_DAYS"))); err == nil && n > 0 {
+		return n
+	}
+	return defaultQualificationAlertDays
+}
+
+// expiryStatus today（JST の日付）時点の有効期限の状態（期限日の当日までは有効。残り alertDays 日以内は期限間近）
+func expiryStatus(expiresOn *string, today time.Time, alertDays int) string {
+	days, ok := daysUntil(expiresOn, today)
+	switch {
+	case !ok:
+		return expiryValid
+	case days < 0:
+		return expiryExpired
+	case days <= alertDays:
+		return expiryExpiring
+	}
+	return expiryValid
+}
+
+// daysUntil today から有効期限までの日数（期限が無い・読めない場合は ok=false）
+func daysUntil(expiresOn *string, today time.Time) (int, bool) {
+	if expiresOn == nil || strings.TrimSpace(*expiresOn) == "" {
+		return 0, false
+	}
+	d, err := time.ParseInLocation("2006-01-02", *expiresOn, jst)
+	if err != nil {
+		return 0, false
+	}
+	return int(d.Sub(today).Hours() / 24), true
+}
+
+// setExpiryStatus 有効期限から期限の状態を row に設定する（作成・更新で共用。毎日の確認と同じ判定）
+func setExpiryStatus(row map[string]any, expiresOn *string, today time.Time) {
+	status := expiryStatus(expiresOn, today, qualificationAlertDays())
+	row["expiry_status"] = status
+	if status == expiryValid {
+		row["flagged_at"] = nil
+	} else {
+		row["flagged_at"] = time.Now().UTC().Format(time.RFC3339)
+	}
+}
+
+// datesInOrder 有効期限が交付日・実施日より前でないか（どちらかが未設定なら確かめない）
+func datesInOrder(issuedOn, expiresOn *string) bool {
+	if issuedOn == nil || expiresOn == nil || *issuedOn == "" || *expiresOn == "" {
+		return true
+	}
+	return *expiresOn >= *issuedOn // YYYY-MM-DD は文字列の順が日付の順
+}
+
+// licenseExpiredOn day（JST の日付）の時点で運転免許がすべて期限切れか（運転免許が未登録なら false）
+// 送迎の割り当てを止める条件（dispatch_assignment_check_license と同じ）
+func (s StaffDTO) licenseExpiredOn(day time.Time) bool {
+	var licensed bool
+	for _, q := range s.Qualifications {
+		if q.Type != qualificationDriverLicense && q.Type != qualificationDriverLicense2 {
+			continue
+		}
+		licensed = true
+		if days, ok := daysUntil(q.ExpiresOn, day); !ok || days >= 0 {
+			return false
+		}
+	}
+	return licensed
+}
+
+func buildQualificationResponse(q QualificationDTO, today time.Time) QualificationResponse {
+	resp := QualificationResponse{
+		ID:           q.ID,
+		StaffID:      q.StaffID,
+		Type:         q.Type,
+		Name:         q.Name,
+		Number:       q.Number,
+		IssuedOn:     q.IssuedOn,
+		ExpiresOn:    q.ExpiresOn,
+		DocumentRef:  q.DocumentRef,
+		ExpiryStatus: expiryStatus(q.ExpiresOn, today, qualificationAlertDays()),
+		CreatedAt:    coalesce(q.CreatedAt, ""),
+		UpdatedAt:    coalesce(q.UpdatedAt, ""),
+		Version:      etag.FromUpdatedAt(q.UpdatedAt),
+	}
+	if days, ok := daysUntil(q.ExpiresOn, today); ok {
+		resp.DaysLeft = &days
+	}
+	return resp
+}
+
+// emptyToNil 空文字（前後の空白のみを含む）は null として保存する
+func emptyToNil(p *string) any {
+	if p == nil || strings.TrimSpace(*p) == "" {
+		return nil
+	}
+	return strings.TrimSpace(*p)
+}
+
+// splitList カンマ区切りのクエリを分ける（空の要素は除く）
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
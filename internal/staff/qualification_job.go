@@ -0,0 +1,104 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	supa "nissyo/internal/supabase"
+)
+
+// defaultQualificationCheckHour 毎日の期限確認を行う時刻（JST の時）の既定値
+const defaultQualificationCheckHour = 6
+
+// StartQualificationExpiryJob 免許・資格の期限の状態を毎日更新する（起動時にも1回行う。ctx の終了で止まる）
+// 期限切れ・期限間近になった行に expiry_status と flagged_at を付け、一覧は GET /api/qualifications/alerts で見る
+func StartQualificationExpiryJob(ctx context.Context) {
+	go func() {
+		runQualificationExpiryCheck(ctx)
+		for {
+			timer := time.NewTimer(time.Until(nextQualificationCheck(time.Now().In(jst))))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+				runQualificationExpiryCheck(ctx)
+			}
+		}
+	}()
+}
+
+// nextQualificationCheck now の次の確認時刻（QUALIFICATION_CHECK_HOUR。0〜23）
+func nextQualificationCheck(now time.Time) time.Time {
+	hour := defaultQualificationCheckHour
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("QUALIFICATION_CHECK_HOUR"))); err == nil && n >= 0 && n < 24 {
+		hour = n
+	}
+	next := dayStart(now).Add(time.Duration(hour) * time.Hour)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runQualificationExpiryCheck 本日時点の期限の状態に揃える（状態が変わる行のみ更新する）
+func runQualificationExpiryCheck(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("qualification: failed to init supabase client: %v", err)
+		return
+	}
+	today := dayStart(time.Now().In(jst))
+	limit := today.AddDate(0, 0, qualificationAlertDays()).Format("2006-01-02")
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	expired := url.Values{}
+	expired.Set("expires_on", "lt."+today.Format("2006-01-02"))
+	expired.Set("expiry_status", "neq."+expiryExpired)
+
+	expiring := url.Values{}
+	expiring.Add("expires_on", "gte."+today.Format("2006-01-02"))
+	expiring.Add("expires_on", "lte."+limit)
+	expiring.Set("expiry_status", "neq."+expiryExpiring)
+
+	// 更新・期限の削除で期限間近・期限切れでなくなった行
+	valid := url.Values{}
+	valid.Set("or", "(expires_on.is.null,expires_on.gt."+limit+")")
+	valid.Set("expiry_status", "neq."+expiryValid)
+
+	for _, s := range []struct {
+		status string
+		q      url.Values
+		patch  map[string]any
+	}{
+		{expiryExpired, expired, map[string]any{"expiry_status": expiryExpired, "flagged_at": now}},
+		{expiryExpiring, expiring, map[string]any{"expiry_status": expiryExpiring, "flagged_at": now}},
+		{expiryValid, valid, map[string]any{"expiry_status": expiryValid, "flagged_at": nil}},
+	} {
+		s.q.Set("select", "id")
+		body, _, patchErr := client.Patch(ctx, "/rest/v1/staff_qualification", s.q, s.patch)
+		if patchErr != nil {
+			log.Printf("qualification: failed to flag %s: %v", s.status, patchErr)
+			continue
+		}
+		var rows []struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(body, &rows); err != nil {
+			log.Printf("qualification: json decode error (%s): %v", s.status, err)
+			continue
+		}
+		if len(rows) > 0 {
+			log.Printf("qualification: %d row(s) marked %s as of %s", len(rows), s.status, today.Format("2006-01-02"))
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package staff
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jst 日付の判定（退職日・就労日）は業務時間の基準である日本時間で行う
+var jst = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+type RetireStaffRequest struct {
+	RetirementDate *string `json:"retirementDate" binding:"omitempty,ymd"` // 未指定時は本日。先の日付は指定できない
+	// 割り当て車両を解除するか（既定 true）
+	ReleaseVehicle *bool   `json:"releaseVehicle"`
+	Version        *string `json:"version"`
+}
+
+type ReinstateStaffRequest struct {
+	Version *string `json:"version"`
+}
+
+// RetireStaffHandler 退職処理（在職フラグと退職日を同時に設定し、車両の割り当てを解除する）
+// 在職フラグ・車両はすぐに変わるため、退職日は本日以前に限る（先の日付は 400）
+// 退職日より後は勤務表に表示されない
+func RetireStaffHandler(c *gin.Context) {
+	var req RetireStaffRequest
+	today := time.Now().In(jst).Format("2006-01-02")
+	retirementDate := func() string {
+		if req.RetirementDate != nil && strings.TrimSpace(*req.RetirementDate) != "" {
+			return strings.TrimSpace(*req.RetirementDate)
+		}
+		return today
+	}
+	changeEmploymentStatus(c, &req, func(lang string) []apierror.FieldError {
+		// YYYY-MM-DD は文字列の比較で日付の前後が分かる
+		if retirementDate() > today {
+			return []apierror.FieldError{validate.NewFieldError(lang, "retirementDate", "not_future", "")}
+		}
+		return nil
+	}, func(current StaffDTO) map[string]any {
+		patch := map[string]any{
+			"status":           false,
+			"resignation_date": retirementDate(),
+		}
+		if (req.ReleaseVehicle == nil || *req.ReleaseVehicle) && current.StaffCar != nil {
+			patch["vehicle"] = nil
+		}
+		return patch
+	}, func() *string { return req.Version })
+}
+
+// ReinstateStaffHandler 復職処理（在職に戻し退職日を消す。車両は自動では再割り当てしない）
+func ReinstateStaffHandler(c *gin.Context) {
+	var req ReinstateStaffRequest
+	changeEmploymentStatus(c, &req, nil, func(StaffDTO) map[string]any {
+		return map[string]any{
+			"status":           true,
+			"resignation_date": nil,
+		}
+	}, func() *string { return req.Version })
+}
+
+// changeEmploymentStatus 退職・復職で共通の読み込み・排他確認・更新・応答を行う
+// check はボディの読み込み後の入力確認（不要なら nil）
+func changeEmploymentStatus(c *gin.Context, req any, check func(lang string) []apierror.FieldError, buildPatch func(StaffDTO) map[string]any, version func() *string) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 12*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	// ボディは省略可能（If-Match ヘッダーのみで呼べるようにする）
+	if c.Request.ContentLength != 0 {
+		fieldErrs, err := validate.BindJSON(c, req)
+		if err != nil {
+			log.Printf("VAL_002: invalid body: %v", err)
+			apierror.Respond(c, apierror.CodeValidation)
+			return
+		}
+		if len(fieldErrs) > 0 {
+			apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+			return
+		}
+	}
+	if check != nil {
+		if fieldErrs := check(apierror.Lang(c)); len(fieldErrs) > 0 {
+			apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+			return
+		}
+	}
+
+	current, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if !etag.Check(c, version(), etag.FromUpdatedAt(current.UpdatedAt), buildStaffDetail(current)) {
+		return
+	}
+
+	if _, ok := patchStaffIfUnchanged(c, ctx, client, current, buildPatch(current)); !ok {
+		return
+	}
+
+	updated, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildStaffDetail(updated)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,90 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	accesslog "nissyo/internal/accesslog"
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RevealStaffContactResponse マスクしていない連絡先
+type RevealStaffContactResponse struct {
+	ID          string  `json:"id"`
+	PhoneNumber *string `json:"phoneNumber"`
+	MobileEmail *string `json:"mobileEmail"`
+	PcEmail     *string `json:"pcEmail"`
+}
+
+// RevealStaffContactHandler スタッフのマスク前の電話番号・メールアドレスを返す（POST /api/staff/:id/reveal）
+// 権限の確認と回数制限はルーター側のミドルウェアで行う。表示の記録に失敗した場合は値を返さない
+func RevealStaffContactHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	reason, ok := accesslog.BindReason(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", "id,phone_number,mobile_email_address,pc_email_address")
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []StaffDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(rows) == 0 {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	s := rows[0]
+
+	if err := accesslog.RecordReveal(ctx, client, c, accesslog.Reveal{
+		ResourceType: accesslog.ResourceStaff,
+		ResourceID:   s.ID,
+		Fields:       []string{"phone_number", "mobile_email_address", "pc_email_address"},
+		Reason:       reason,
+	}); err != nil {
+		log.Printf("DB_003: %v", err)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, RevealStaffContactResponse{
+		ID:          s.ID,
+		PhoneNumber: s.PhoneNumber,
+		MobileEmail: s.MobileEmail,
+		PcEmail:     s.PcEmail,
+	})
+}
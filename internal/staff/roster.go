@@ -0,0 +1,328 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RosterShift 勤務1件（出勤日と実際の出勤・退勤日時）
+type RosterShift struct {
+	Date      string `json:"date"` // 出勤日（翌日にまたぐ勤務も出勤日）
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Overnight bool   `json:"overnight"`
+	Extra     bool   `json:"extra"` // 日付指定で追加された勤務
+}
+
+type RosterVehicle struct {
+	ID          string  `json:"id"`
+	CarType     *string `json:"carType,omitempty"`
+	Area        *string `json:"area,omitempty"`
+	ClassNumber *string `json:"classNumber,omitempty"`
+	Character   *string `json:"character,omitempty"`
+	Number      *int    `json:"number,omitempty"`
+	Plate       *string `json:"plate,omitempty"`
+}
+
+type RosterEntry struct {
+	ID           string         `json:"id"`
+	SFID         string         `json:"sfid"`
+	Name         string         `json:"name"`
+	JobTypes     []string       `json:"jobTypes"`
+	Role         string         `json:"role"`
+	AreaDivision *string        `json:"areaDivision,omitempty"`
+	Vehicle      *RosterVehicle `json:"vehicle,omitempty"`
+	Shifts       []RosterShift  `json:"shifts"`
+}
+
+// RosterGroup 区分ごとの勤務者（staffIds は entries の id）
+type RosterGroup struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Count    int      `json:"count"`
+	StaffIDs []string `json:"staffIds"`
+}
+
+type RosterGroups struct {
+	JobType []RosterGroup `json:"jobType"`
+	Area    []RosterGroup `json:"area"`
+	Vehicle []RosterGroup `json:"vehicle"`
+}
+
+type RosterResponse struct {
+	At      string        `json:"at,omitempty"`   // at 指定時: その時点
+	Date    string        `json:"date,omitempty"` // date 指定時: その日の 0:00〜24:00
+	Total   int           `json:"total"`
+	Entries []RosterEntry `json:"entries"`
+	Groups  RosterGroups  `json:"groups"`
+}
+
+// rosterGroupNone 区分が未設定の勤務者のキー
+const rosterGroupNone = "none"
+
+// rosterSelect 勤務表で使う列（勤務パターンを埋め込む）
+var rosterSelect = strings.Join([]string{
+	"id", "sfid", "first_name", "last_name", "area_division", "status",
+	"job_description", "position_id", "joining_date", "resignation_date", "display_order",
+	"staff_car:vehicle(id,car_type,area,class_number,character,number)",
+	"staff_shift_pattern(weekday,start_time,end_time)",
+	"staff_shift_override(date,kind,start_time,end_time)",
+	positionSelect,
+}, ",")
+
+// GetRosterHandler 勤務中のスタッフを返す（GET /api/roster）
+//
+//	at=2026-10-18T23:30   その時点で勤務中のスタッフ（タイムゾーン省略時は JST。省略時は現在）
+//	date=2026-10-18       その日に勤務するスタッフと勤務の一覧（前日から日付をまたいで続く勤務も含む）
+//
+// 週間勤務パターンと日付指定の変更から計算し、退職日より後・入社日より前のスタッフは除く
+// 職種（driver / office）・エリア・車両ごとの区分も返す
+func GetRosterHandler(c *gin.Context) {
+	lang := apierror.Lang(c)
+	atParam := strings.TrimSpace(c.Query("at"))
+	dateParam := strings.TrimSpace(c.Query("date"))
+	if atParam != "" && dateParam != "" {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(lang, "date", "invalid", "at"),
+		})
+		return
+	}
+
+	var from, to time.Time
+	resp := RosterResponse{}
+	switch {
+	case dateParam != "":
+		d, err := time.ParseInLocation("2006-01-02", dateParam, jst)
+		if err != nil {
+			apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+				validate.NewFieldError(lang, "date", "ymd", ""),
+			})
+			return
+		}
+		from, to = d, d.AddDate(0, 0, 1)
+		resp.Date = dateParam
+	default:
+		at := time.Now().In(jst)
+		if atParam != "" {
+			t, ok := parseRosterTime(atParam)
+			if !ok {
+				apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+					validate.NewFieldError(lang, "at", "datetime", ""),
+				})
+				return
+			}
+			at = t
+		}
+		from, to = at, at.Add(time.Nanosecond)
+		resp.At = at.Format(time.RFC3339)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	// 前日に始まり日付をまたぐ勤務も対象にするため、日付指定の変更は前日分から取得する
+	firstDay := dayStart(from).AddDate(0, 0, -1)
+	q := url.Values{}
+	q.Set("select", rosterSelect)
+	q.Add("staff_shift_override.date", "gte."+firstDay.Format("2006-01-02"))
+	q.Add("staff_shift_override.date", "lte."+dayStart(to.Add(-time.Nanosecond)).Format("2006-01-02"))
+	q.Set("order", "display_order.asc.nullslast,sfid.asc")
+	q.Set("limit", "2000")
+
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []StaffDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+
+	resp.Entries = make([]RosterEntry, 0)
+	for _, s := range rows {
+		shifts := s.shiftsBetween(from, to)
+		if len(shifts) == 0 {
+			continue
+		}
+		resp.Entries = append(resp.Entries, rosterEntry(s, shifts))
+	}
+	if resp.Date != "" {
+		sort.SliceStable(resp.Entries, func(i, j int) bool {
+			return resp.Entries[i].Shifts[0].Start < resp.Entries[j].Shifts[0].Start
+		})
+	}
+	resp.Total = len(resp.Entries)
+	resp.Groups = rosterGroups(resp.Entries)
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseRosterTime RFC 3339 または タイムゾーンなしの日時（JST とみなす）を読む
+func parseRosterTime(v string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.In(jst), true
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, v, jst); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dayStart t の日付（JST）の 0:00
+func dayStart(t time.Time) time.Time {
+	t = t.In(jst)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, jst)
+}
+
+// shiftsBetween [from, to) と重なる勤務（出勤日の時点で在籍している日のみ）
+func (s StaffDTO) shiftsBetween(from, to time.Time) []RosterShift {
+	var out []RosterShift
+	for day := dayStart(from).AddDate(0, 0, -1); day.Before(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if !s.employedOn(date) {
+			continue
+		}
+		for _, span := range s.shiftsOn(day) {
+			start, end := span.On(day)
+			if start.Before(to) && end.After(from) {
+				out = append(out, RosterShift{
+					Date:      date,
+					Start:     start.Format(time.RFC3339),
+					End:       end.Format(time.RFC3339),
+					Overnight: span.Overnight(),
+					Extra:     span.Extra,
+				})
+			}
+		}
+	}
+	return out
+}
+
+// employedOn date（YYYY-MM-DD）に在籍しているか
+// 入社日より前は除く。退職日がある場合は退職日まで在籍、ない場合は在職フラグで判断する
+func (s StaffDTO) employedOn(date string) bool {
+	if joined := dateInJST(s.JoiningDate); joined != "" && date < joined {
+		return false
+	}
+	if resigned := dateInJST(s.ResignationDate); resigned != "" {
+		return date <= resigned
+	}
+	return s.Status == nil || *s.Status
+}
+
+// dateInJST DB の日付・日時を JST の YYYY-MM-DD にする
+func dateInJST(v *string) string {
+	if v == nil || *v == "" {
+		return ""
+	}
+	if t, err := time.Parse(time.RFC3339, *v); err == nil {
+		return t.In(jst).Format("2006-01-02")
+	}
+	return parseDateOnly(v)
+}
+
+func rosterEntry(s StaffDTO, shifts []RosterShift) RosterEntry {
+	e := RosterEntry{
+		ID:           s.ID,
+		SFID:         coalesce(toStringPtrFromIntPtr(s.SFID), ""),
+		Name:         strings.TrimSpace(coalesce(s.LastName, "") + " " + coalesce(s.FirstName, "")),
+		JobTypes:     mapJobTypes(s.JobDescription),
+		Role:         s.roleKey(),
+		AreaDivision: s.AreaDivision,
+		Shifts:       shifts,
+	}
+	if s.StaffCar != nil {
+		e.Vehicle = &RosterVehicle{
+			ID:          s.StaffCar.ID,
+			CarType:     s.StaffCar.CarType,
+			Area:        s.StaffCar.Area,
+			ClassNumber: s.StaffCar.ClassNumber,
+			Character:   s.StaffCar.Character,
+			Number:      s.StaffCar.Number,
+			Plate:       s.StaffCar.plateLabel(),
+		}
+	}
+	return e
+}
+
+// rosterGroups 職種・エリア・車両ごとにまとめる（勤務表の並び順のまま。未設定は none として最後）
+func rosterGroups(entries []RosterEntry) RosterGroups {
+	type acc struct {
+		order  []string
+		groups map[string]*RosterGroup
+	}
+	add := func(a *acc, key, label, id string) {
+		g, ok := a.groups[key]
+		if !ok {
+			g = &RosterGroup{Key: key, Label: label, StaffIDs: []string{}}
+			a.groups[key] = g
+			if key != rosterGroupNone {
+				a.order = append(a.order, key)
+			}
+		}
+		g.Count++
+		g.StaffIDs = append(g.StaffIDs, id)
+	}
+	list := func(a *acc) []RosterGroup {
+		out := make([]RosterGroup, 0, len(a.groups))
+		for _, k := range a.order {
+			out = append(out, *a.groups[k])
+		}
+		if g, ok := a.groups[rosterGroupNone]; ok {
+			out = append(out, *g)
+		}
+		return out
+	}
+
+	job := &acc{groups: map[string]*RosterGroup{}}
+	area := &acc{groups: map[string]*RosterGroup{}}
+	vehicle := &acc{groups: map[string]*RosterGroup{}}
+	for _, e := range entries {
+		for _, jt := range e.JobTypes {
+			add(job, jt, jt, e.ID)
+		}
+		if a := strings.TrimSpace(coalesce(e.AreaDivision, "")); a != "" {
+			add(area, a, a, e.ID)
+		} else {
+			add(area, rosterGroupNone, "", e.ID)
+		}
+		if e.Vehicle != nil {
+			add(vehicle, e.Vehicle.ID, vehicleLabel(e.Vehicle), e.ID)
+		} else {
+			add(vehicle, rosterGroupNone, "", e.ID)
+		}
+	}
+	return RosterGroups{JobType: list(job), Area: list(area), Vehicle: list(vehicle)}
+}
+
+// vehicleLabel 車両グループの表示（ナンバー。未登録なら車種）
+func vehicleLabel(v *RosterVehicle) string {
+	if v.Plate == nil {
+		return coalesce(v.CarType, v.ID)
+	}
+	return *v.Plate
+}
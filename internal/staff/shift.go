@@ -0,0 +1,212 @@
+package staff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	validate "nissyo/internal/validate"
+)
+
+const minutesPerDay = 24 * 60
+
+// scheduleDays 勤務時間の曜日キー（time.Weekday の順）
+var scheduleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ShiftSpan 1日分の勤務時間を当日 0:00 からの分で表す
+// 退勤が出勤より前の時刻なら翌日の退勤とみなし、End は 24*60 を超える（例: 20:00〜04:00 → 1200〜1680）
+type ShiftSpan struct {
+	Start int
+	End   int
+	Extra bool // 日付指定で追加された勤務
+}
+
+// Overnight 日付をまたぐ勤務か
+func (s ShiftSpan) Overnight() bool {
+	return s.End > minutesPerDay
+}
+
+// On day（その日の 0:00 を表す時刻）に始まる勤務の出勤・退勤時刻
+func (s ShiftSpan) On(day time.Time) (time.Time, time.Time) {
+	return day.Add(time.Duration(s.Start) * time.Minute), day.Add(time.Duration(s.End) * time.Minute)
+}
+
+// parseClock "HH:MM" または DB の "HH:MM:SS" を 0:00 からの分にする
+func parseClock(v string) (int, bool) {
+	hs, rest, ok := strings.Cut(strings.TrimSpace(v), ":")
+	if !ok || len(hs) != 2 || len(rest) < 2 {
+		return 0, false
+	}
+	ms := rest[:2]
+	if len(rest) > 2 && rest[2] != ':' {
+		return 0, false
+	}
+	h, err1 := strconv.Atoi(hs)
+	m, err2 := strconv.Atoi(ms)
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, false
+	}
+	return h*60 + m, true
+}
+
+// newShiftSpan 出勤・退勤の時刻から勤務時間を作る（どちらかが解釈できない、または同じ時刻なら ok=false）
+func newShiftSpan(start, end string) (ShiftSpan, bool) {
+	from, ok1 := parseClock(start)
+	to, ok2 := parseClock(end)
+	if !ok1 || !ok2 || from == to {
+		return ShiftSpan{}, false
+	}
+	if to < from {
+		to += minutesPerDay
+	}
+	return ShiftSpan{Start: from, End: to}, true
+}
+
+// dayTimes 曜日キーに対応する出勤・退勤の列
+func (s StaffDTO) dayTimes(day string) (start, end *string) {
+	switch day {
+	case "mon":
+		return s.MonStart, s.MonEnd
+	case "tue":
+		return s.TueStart, s.TueEnd
+	case "wed":
+		return s.WedStart, s.WedEnd
+	case "thu":
+		return s.ThuStart, s.ThuEnd
+	case "fri":
+		return s.FriStart, s.FriEnd
+	case "sat":
+		return s.SatStart, s.SatEnd
+	case "sun":
+		return s.SunStart, s.SunEnd
+	}
+	return nil, nil
+}
+
+// ShiftTime 応答用の勤務1件
+type ShiftTime struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Overnight bool   `json:"overnight"` // 退勤が翌日
+}
+
+func (s ShiftSpan) time() ShiftTime {
+	end := s.End % minutesPerDay
+	return ShiftTime{
+		Start:     fmt.Sprintf("%02d:%02d", s.Start/60, s.Start%60),
+		End:       fmt.Sprintf("%02d:%02d", end/60, end%60),
+		Overnight: s.Overnight(),
+	}
+}
+
+// patternOn weekday の曜日の勤務パターン（出勤時刻順。休みなら空）
+// 勤務パターンを埋め込みで取得していない行は曜日別の列（最初の勤務）から作る
+func (s StaffDTO) patternOn(weekday time.Weekday) []ShiftSpan {
+	var spans []ShiftSpan
+	if s.ShiftPatterns == nil {
+		start, end := s.dayTimes(scheduleDays[weekday])
+		if start != nil && end != nil {
+			if span, ok := newShiftSpan(*start, *end); ok {
+				spans = append(spans, span)
+			}
+		}
+		return spans
+	}
+	for _, p := range s.ShiftPatterns {
+		if p.Weekday != int(weekday) {
+			continue
+		}
+		if span, ok := newShiftSpan(p.StartTime, p.EndTime); ok {
+			spans = append(spans, span)
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	return spans
+}
+
+// weeklyShifts 曜日キーごとの勤務パターン（休みの曜日は空の配列）
+func (s StaffDTO) weeklyShifts() map[string][]ShiftTime {
+	out := make(map[string][]ShiftTime, len(scheduleDays))
+	for wd, day := range scheduleDays {
+		spans := s.patternOn(time.Weekday(wd))
+		times := make([]ShiftTime, 0, len(spans))
+		for _, span := range spans {
+			times = append(times, span.time())
+		}
+		out[day] = times
+	}
+	return out
+}
+
+// shiftsOn day（JST の日付）に出勤する勤務
+// 勤務パターンに日付指定の変更（off: 休み / extra: 追加）を重ねる。変更は ShiftOverrides に取得済みのもののみ
+func (s StaffDTO) shiftsOn(day time.Time) []ShiftSpan {
+	date := day.Format("2006-01-02")
+	spans := s.patternOn(day.Weekday())
+	var extras []ShiftSpan
+	for _, o := range s.ShiftOverrides {
+		if o.Date != date {
+			continue
+		}
+		switch o.Kind {
+		case shiftOverrideOff:
+			spans = nil
+		case shiftOverrideExtra:
+			if o.StartTime != nil && o.EndTime != nil {
+				if span, ok := newShiftSpan(*o.StartTime, *o.EndTime); ok {
+					span.Extra = true
+					extras = append(extras, span)
+				}
+			}
+		}
+	}
+	spans = append(spans, extras...)
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+	return spans
+}
+
+// validateSchedule 勤務時間の更新内容を現在値と合わせて確認する
+// 出勤・退勤はそろって指定されていること、同じ時刻でないことを求める（退勤が前の時刻なら翌日退勤）
+// current が nil の場合（新規登録）はリクエストの値だけで判断する
+func validateSchedule(lang string, days map[string]UpdateDay, current *StaffDTO) []apierror.FieldError {
+	var errs []apierror.FieldError
+	for _, day := range scheduleDays {
+		upd, ok := days[day]
+		if !ok || (upd.Work != nil && !*upd.Work) {
+			continue
+		}
+		var start, end *string
+		if current != nil {
+			start, end = current.dayTimes(day)
+		}
+		if upd.Start != nil {
+			start = upd.Start
+		}
+		if upd.End != nil {
+			end = upd.End
+		}
+		startSet := start != nil && *start != ""
+		endSet := end != nil && *end != ""
+		field := "schedule[" + day + "]"
+		switch {
+		case !startSet && !endSet:
+			if upd.Work != nil && *upd.Work {
+				errs = append(errs,
+					validate.NewFieldError(lang, field+".start", "required", ""),
+					validate.NewFieldError(lang, field+".end", "required", ""))
+			}
+		case !startSet:
+			errs = append(errs, validate.NewFieldError(lang, field+".start", "required", ""))
+		case !endSet:
+			errs = append(errs, validate.NewFieldError(lang, field+".end", "required", ""))
+		default:
+			if _, ok := newShiftSpan(*start, *end); !ok {
+				errs = append(errs, validate.NewFieldError(lang, field+".end", "shift_range", ""))
+			}
+		}
+	}
+	return errs
+}
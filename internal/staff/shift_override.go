@@ -0,0 +1,293 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 日付指定の勤務変更の種類
+const (
+	shiftOverrideOff   = "off"   // その日の勤務パターンを休みにする
+	shiftOverrideExtra = "extra" // 勤務を追加する
+)
+
+// ShiftOverrideItem 日付指定の勤務変更（応答用）
+type ShiftOverrideItem struct {
+	ID        string  `json:"id"`
+	Date      string  `json:"date"`
+	Kind      string  `json:"kind"`
+	Start     *string `json:"start,omitempty"`
+	End       *string `json:"end,omitempty"`
+	Overnight bool    `json:"overnight"`
+	Note      *string `json:"note,omitempty"`
+}
+
+type ShiftOverrideListResponse struct {
+	StaffID   string              `json:"staffId"`
+	Overrides []ShiftOverrideItem `json:"overrides"`
+}
+
+// CreateShiftOverrideRequest 日付指定の勤務変更の登録
+// date は出勤日（翌日にまたぐ勤務も出勤日で登録する）。start / end は extra のみ指定する
+type CreateShiftOverrideRequest struct {
+	Date  string  `json:"date" binding:"required,ymd"`
+	Kind  string  `json:"kind" binding:"required,oneof=off extra"`
+	Start *string `json:"start" binding:"omitempty,hhmm"`
+	End   *string `json:"end" binding:"omitempty,hhmm"`
+	Note  *string `json:"note" binding:"omitempty,max=500"`
+}
+
+var shiftOverrideSelect = "id,staff_id,date,kind,start_time,end_time,note,created_at"
+
+// GetShiftOverridesHandler 日付指定の勤務変更の一覧（日付順）
+// from / to（YYYY-MM-DD）で期間を絞る。from の既定は本日
+func GetShiftOverridesHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	lang := apierror.Lang(c)
+	from := strings.TrimSpace(c.Query("from"))
+	if from == "" {
+		from = time.Now().In(jst).Format("2006-01-02")
+	}
+	to := strings.TrimSpace(c.Query("to"))
+	var fieldErrs []apierror.FieldError
+	if _, err := time.Parse("2006-01-02", from); err != nil {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "from", "ymd", ""))
+	}
+	if to != "" {
+		if _, err := time.Parse("2006-01-02", to); err != nil {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "to", "ymd", ""))
+		}
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	if code := ensureStaffExists(ctx, client, id); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", shiftOverrideSelect)
+	q.Set("staff_id", "eq."+id)
+	if to != "" {
+		q.Set("and", "(date.gte."+from+",date.lte."+to+")")
+	} else {
+		q.Set("date", "gte."+from)
+	}
+	q.Set("order", "date,start_time")
+	q.Set("limit", "500")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_shift_override", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []ShiftOverrideDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	c.JSON(http.StatusOK, ShiftOverrideListResponse{StaffID: id, Overrides: shiftOverrideItems(rows)})
+}
+
+// CreateShiftOverrideHandler 日付指定の勤務変更を登録する（POST /api/staff/:id/shift-overrides）
+// 同じ日の off は1件まで（重複は 409）。スタッフの版（ETag）は変わらない
+func CreateShiftOverrideHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req CreateShiftOverrideRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	lang := apierror.Lang(c)
+	if len(fieldErrs) == 0 {
+		start, end := coalesce(req.Start, ""), coalesce(req.End, "")
+		switch req.Kind {
+		case shiftOverrideOff:
+			if start != "" {
+				fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "start", "invalid", "kind=off"))
+			}
+			if end != "" {
+				fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "end", "invalid", "kind=off"))
+			}
+		case shiftOverrideExtra:
+			if start == "" {
+				fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "start", "required", ""))
+			}
+			if end == "" {
+				fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "end", "required", ""))
+			}
+			if start != "" && end != "" {
+				if _, ok := newShiftSpan(start, end); !ok {
+					fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "end", "shift_range", ""))
+				}
+			}
+		}
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	if code := ensureStaffExists(ctx, client, id); code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	row := map[string]any{
+		"staff_id": id,
+		"date":     req.Date,
+		"kind":     req.Kind,
+	}
+	if req.Kind == shiftOverrideExtra {
+		row["start_time"] = *req.Start
+		row["end_time"] = *req.End
+	}
+	if req.Note != nil && strings.TrimSpace(*req.Note) != "" {
+		row["note"] = strings.TrimSpace(*req.Note)
+	}
+	q := url.Values{}
+	q.Set("select", shiftOverrideSelect)
+	body, status, postErr := client.Post(ctx, "/rest/v1/staff_shift_override", q, row)
+	if postErr != nil {
+		if status == http.StatusConflict {
+			apierror.Respond(c, apierror.CodeDuplicate)
+			return
+		}
+		log.Printf("DB_003: supabase insert shift override error: %v", postErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []ShiftOverrideDTO
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) == 0 {
+		log.Printf("DB_002: json decode error (shift override insert): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	item := shiftOverrideItems(rows)[0]
+	c.Header("Location", "/api/staff/"+id+"/shift-overrides/"+item.ID)
+	c.JSON(http.StatusCreated, item)
+}
+
+// DeleteShiftOverrideHandler 日付指定の勤務変更を取り消す（DELETE /api/staff/:id/shift-overrides/:overrideId）
+func DeleteShiftOverrideHandler(c *gin.Context) {
+	id := c.Param("id")
+	overrideID := c.Param("overrideId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(overrideID) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+overrideID)
+	q.Set("staff_id", "eq."+id)
+	body, _, delErr := client.Delete(ctx, "/rest/v1/staff_shift_override", q)
+	if delErr != nil {
+		log.Printf("DB_003: supabase delete shift override error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []ShiftOverrideDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (shift override delete): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(rows) == 0 {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ensureStaffExists スタッフが存在するか確認する（無ければ DB_404）
+func ensureStaffExists(ctx context.Context, client *supa.Client, id string) string {
+	q := url.Values{}
+	q.Set("select", "id")
+	q.Set("id", "eq."+id)
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get error: %v", getErr)
+		return apierror.CodeDBInit
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		return apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return apierror.CodeNotFound
+	}
+	return ""
+}
+
+// shiftOverrideItems DB の行を応答の形にする（時刻は HH:MM）
+func shiftOverrideItems(rows []ShiftOverrideDTO) []ShiftOverrideItem {
+	out := make([]ShiftOverrideItem, 0, len(rows))
+	for _, o := range rows {
+		item := ShiftOverrideItem{ID: o.ID, Date: o.Date, Kind: o.Kind, Note: o.Note}
+		if o.StartTime != nil && o.EndTime != nil {
+			if span, ok := newShiftSpan(*o.StartTime, *o.EndTime); ok {
+				t := span.time()
+				item.Start, item.End, item.Overnight = &t.Start, &t.End, t.Overnight
+			}
+		}
+		out = append(out, item)
+	}
+	return out
+}
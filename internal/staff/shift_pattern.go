@@ -0,0 +1,165 @@
+package staff
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ShiftInput 勤務1件の入力（退勤が出勤より前の時刻なら翌日退勤）
+type ShiftInput struct {
+	Start string `json:"start" binding:"required,hhmm"`
+	End   string `json:"end" binding:"required,hhmm"`
+}
+
+// ReplaceShiftPatternRequest 週間勤務パターンの置き換え
+// pattern に含まれない曜日は休みになる。1曜日あたり6件まで
+type ReplaceShiftPatternRequest struct {
+	Pattern map[string][]ShiftInput `json:"pattern" binding:"required,dive,keys,oneof=mon tue wed thu fri sat sun,endkeys,max=6,dive"`
+	Version *string                 `json:"version"`
+}
+
+// ReplaceShiftPatternHandler 週間勤務パターンを置き換える（PUT /api/staff/:id/shifts）
+// 曜日ごとに複数の勤務を登録できる。staff の曜日別の列は各曜日の最初の勤務にそろえる
+// If-Match（または version）でスタッフの版を指定し、競合した場合は 412 を返す
+func ReplaceShiftPatternHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	var req ReplaceShiftPatternRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) == 0 {
+		fieldErrs = validateShiftPattern(apierror.Lang(c), req.Pattern)
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	current, found, code := fetchStaffDetailRow(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if !etag.Check(c, req.Version, etag.FromUpdatedAt(current.UpdatedAt), buildStaffDetail(current)) {
+		return
+	}
+
+	shifts := make([]map[string]any, 0)
+	for wd, day := range scheduleDays {
+		for _, s := range req.Pattern[day] {
+			shifts = append(shifts, map[string]any{"weekday": wd, "start_time": s.Start, "end_time": s.End})
+		}
+	}
+	body, status, rpcErr := client.RPC(ctx, "replace_staff_shift_pattern", map[string]any{
+		"p_staff_id":   current.ID,
+		"p_updated_at": coalesce(current.UpdatedAt, ""),
+		"p_shifts":     shifts,
+	})
+	if rpcErr != nil {
+		switch status {
+		case http.StatusNotFound:
+			apierror.Respond(c, apierror.CodeNotFound)
+		case http.StatusPreconditionFailed:
+			// 読み込みから更新までの間に他の更新が入った
+			if latest, found, code := fetchStaffDetailRow(ctx, client, current.ID); code == "" && found {
+				etag.Conflict(c, etag.FromUpdatedAt(latest.UpdatedAt), buildStaffDetail(latest))
+				return
+			}
+			etag.Conflict(c, "", nil)
+		default:
+			log.Printf("DB_003: replace_staff_shift_pattern error: %v body=%s", rpcErr, string(body))
+			apierror.Respond(c, apierror.CodeDBUpdate)
+		}
+		return
+	}
+
+	updated, found, code := fetchStaffDetailRow(ctx, client, current.ID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildStaffDetail(updated)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// validateShiftPattern 出勤と退勤が同じ時刻でないこと、同じ曜日の勤務どうし・翌日にまたぐ勤務と翌曜日の勤務が重ならないことを確認する
+func validateShiftPattern(lang string, pattern map[string][]ShiftInput) []apierror.FieldError {
+	var errs []apierror.FieldError
+	type indexedSpan struct {
+		ShiftSpan
+		field string
+	}
+	week := make([][]indexedSpan, len(scheduleDays))
+	for wd, day := range scheduleDays {
+		for i, in := range pattern[day] {
+			field := "pattern[" + day + "][" + strconv.Itoa(i) + "]"
+			span, ok := newShiftSpan(in.Start, in.End)
+			if !ok {
+				errs = append(errs, validate.NewFieldError(lang, field+".end", "shift_range", ""))
+				continue
+			}
+			week[wd] = append(week[wd], indexedSpan{span, field})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	for wd := range week {
+		// 翌曜日の勤務は 24 時間後ろにずらして並べる
+		var spans []indexedSpan
+		spans = append(spans, week[wd]...)
+		for _, next := range week[(wd+1)%len(week)] {
+			next.Start += minutesPerDay
+			next.End += minutesPerDay
+			spans = append(spans, next)
+		}
+		for i, a := range week[wd] {
+			for j, b := range spans {
+				if j <= i {
+					continue // 同じ曜日の組み合わせは1回だけ確認する
+				}
+				if a.Start < b.End && b.Start < a.End {
+					errs = append(errs, validate.NewFieldError(lang, b.field+".start", "shift_overlap", ""))
+				}
+			}
+		}
+	}
+	return errs
+}
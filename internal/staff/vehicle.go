@@ -0,0 +1,563 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vehicleSelect 車両の一覧・詳細で取得する列（staff の埋め込みは割り当て中のスタッフ）
+const vehicleSelect = "id,car_type,color,capacity,area,class_number,character,number,is_etc,created_at,updated_at," +
+	"staff(id,sfid,last_name,first_name,status)"
+
+// vehicleListLimit 車両一覧の最大件数
+const vehicleListLimit = 500
+
+// vehicleRow staff_car の行（割り当て中のスタッフ付き）
+type vehicleRow struct {
+	StaffCarDTO
+	CreatedAt *string           `json:"created_at"`
+	UpdatedAt *string           `json:"updated_at"`
+	Staff     []vehicleStaffDTO `json:"staff"`
+}
+
+// vehicleStaffDTO 車両に紐付くスタッフ（staff.vehicle）
+type vehicleStaffDTO struct {
+	ID        string  `json:"id"`
+	SFID      *int    `json:"sfid"`
+	LastName  *string `json:"last_name"`
+	FirstName *string `json:"first_name"`
+	Status    *bool   `json:"status"`
+}
+
+// VehicleDriver 車両を使っている在職中のスタッフ
+type VehicleDriver struct {
+	ID   string `json:"id"`
+	Sfid string `json:"sfid"`
+	Name string `json:"name"`
+}
+
+// VehicleResponse 車両の API の形
+type VehicleResponse struct {
+	ID          string         `json:"id"`
+	CarType     *string        `json:"carType,omitempty"`
+	Color       *string        `json:"color,omitempty"`
+	Capacity    *int           `json:"capacity,omitempty"`
+	Area        *string        `json:"area,omitempty"`
+	ClassNumber *string        `json:"classNumber,omitempty"`
+	Character   *string        `json:"character,omitempty"`
+	Number      *int           `json:"number,omitempty"`
+	Plate       *string        `json:"plate,omitempty"` // 表示用のナンバー（例: 京都 500 あ 12-34）
+	IsETC       bool           `json:"isETC"`
+	Driver      *VehicleDriver `json:"driver"` // 割り当て中の在職スタッフ（未割り当ては null）
+	CreatedAt   string         `json:"createdAt,omitempty"`
+	UpdatedAt   string         `json:"updatedAt,omitempty"`
+	Version     string         `json:"version"` // 楽観的排他制御用（ETag と同じ値）
+}
+
+// UpdateVehicleRequest 車両の部分更新（指定した項目のみ）
+type UpdateVehicleRequest struct {
+	CarType     *string `json:"carType" binding:"omitempty,max=100"`
+	Color       *string `json:"color" binding:"omitempty,max=255"`
+	Capacity    *int    `json:"capacity" binding:"omitempty,min=1,max=99"`
+	Area        *string `json:"area" binding:"omitempty,plate_region"`
+	ClassNumber *string `json:"classNumber" binding:"omitempty,plate_class"`
+	Character   *string `json:"character" binding:"omitempty,plate_kana"`
+	Number      *int    `json:"number" binding:"omitempty,min=1,max=9999"`
+	Plate       *string `json:"plate" binding:"omitempty,plate"` // area〜number をまとめて指定する（UpdateCarRequest.Plate と同じ）
+	IsETC       *bool   `json:"isETC"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
+}
+
+func (r *UpdateVehicleRequest) car() *UpdateCarRequest {
+	return &UpdateCarRequest{
+		CarType:     r.CarType,
+		Color:       r.Color,
+		Capacity:    r.Capacity,
+		Area:        r.Area,
+		ClassNumber: r.ClassNumber,
+		Character:   r.Character,
+		Number:      r.Number,
+		Plate:       r.Plate,
+		IsETC:       r.IsETC,
+	}
+}
+
+// AssignVehicleRequest 車両をスタッフに割り当てる
+type AssignVehicleRequest struct {
+	StaffID string `json:"staffId" binding:"required,uuid"`
+}
+
+// GetVehicleListHandler 車両の一覧（GET /api/vehicles。ナンバー順、割り当て中のスタッフ付き）
+// ?plate= でナンバーを検索する（「京都 500 あ 12-34」のほか「12-34」「あ 1234」「京都」など一部でもよい）
+func GetVehicleListHandler(c *gin.Context) {
+	q := url.Values{}
+	if s := strings.TrimSpace(c.Query("plate")); s != "" {
+		if fe := applyPlateQuery(apierror.Lang(c), q, s); fe != nil {
+			apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{*fe})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q.Set("select", vehicleSelect)
+	q.Set("staff.status", "is.true")
+	q.Set("order", "area.asc.nullslast,character.asc.nullslast,number.asc.nullslast,created_at.asc")
+	q.Set("limit", strconv.Itoa(vehicleListLimit))
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_car", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_car error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var rows []vehicleRow
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_car): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	out := make([]VehicleResponse, 0, len(rows))
+	for _, v := range rows {
+		out = append(out, buildVehicleResponse(v))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// GetVehicleDetailHandler 車両1件（GET /api/vehicles/:id）
+func GetVehicleDetailHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	respondVehicle(c, ctx, client, id, http.StatusOK)
+}
+
+// CreateVehicleHandler 車両を登録する（POST /api/vehicles。スタッフへの割り当ては assign で行う）
+func CreateVehicleHandler(c *gin.Context) {
+	var req UpdateVehicleRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	row := buildCarRow(req.car())
+	if p, _ := patchedPlate(nil, row); !checkPlateAvailable(c, ctx, client, p, "") {
+		return
+	}
+	id, code := insertStaffCar(ctx, client, row)
+	if code == apierror.CodeDuplicate {
+		respondPlateDuplicate(c)
+		return
+	}
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	c.Header("Location", "/api/vehicles/"+id)
+	respondVehicle(c, ctx, client, id, http.StatusCreated)
+}
+
+// UpdateVehicleHandler 車両を部分更新する（PATCH /api/vehicles/:id）
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+func UpdateVehicleHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req UpdateVehicleRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchVehicle(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, buildVehicleResponse(current)) {
+		return
+	}
+
+	patch := buildCarRow(req.car())
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+	if p, changed := patchedPlate(&current.StaffCarDTO, patch); changed && !checkPlateAvailable(c, ctx, client, p, id) {
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	q.Set("updated_at", "eq."+coalesce(current.UpdatedAt, ""))
+	q.Set("select", "id")
+	respBody, status, patchErr := client.Patch(ctx, "/rest/v1/staff_car", q, patch)
+	if patchErr != nil {
+		if status == http.StatusConflict {
+			respondPlateDuplicate(c)
+			return
+		}
+		log.Printf("DB_003: supabase patch car error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var updated []map[string]any
+	_ = json.Unmarshal(respBody, &updated)
+	if len(updated) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchVehicle(ctx, client, id); code == "" && found {
+			resp := buildVehicleResponse(latest)
+			etag.Conflict(c, resp.Version, resp)
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+	respondVehicle(c, ctx, client, id, http.StatusOK)
+}
+
+// DeleteVehicleHandler 車両を削除する（DELETE /api/vehicles/:id）
+// 車検・保険・整備の記録（staff_car_record）は車両と一緒に削除される
+// 在職中のスタッフに割り当てられている車両は削除できない（409）。退職者の割り当ては外部キーで解除される
+func DeleteVehicleHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchVehicle(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	if current.driver() != nil {
+		apierror.Respond(c, apierror.CodeInUse)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+id)
+	if _, _, delErr := client.Delete(ctx, "/rest/v1/staff_car", q); delErr != nil {
+		log.Printf("DB_003: supabase delete car error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AssignVehicleHandler 車両を在職中のスタッフに割り当てる（POST /api/vehicles/:id/assign）
+// 他の在職スタッフが使っている車両は 409。スタッフが使っていた別の車両は割り当てが外れるだけで残る
+func AssignVehicleHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req AssignVehicleRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	vehicle, found, code := fetchVehicle(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	s, found, code := fetchStaffDetailRow(ctx, client, req.StaffID)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "staffId", "exists", ""),
+		})
+		return
+	}
+	if s.Status == nil || !*s.Status {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "staffId", "active_staff", ""),
+		})
+		return
+	}
+	if s.StaffCar != nil && s.StaffCar.ID == id {
+		// 既に割り当て済み
+		respondVehicle(c, ctx, client, id, http.StatusOK)
+		return
+	}
+	if d := vehicle.driverOtherThan(s.ID); d != nil {
+		apierror.RespondWith(c, apierror.CodeVehicleAssigned, "", d)
+		return
+	}
+
+	if _, ok := patchStaffIfUnchanged(c, ctx, client, s, map[string]any{"vehicle": id}); !ok {
+		return
+	}
+	respondVehicle(c, ctx, client, id, http.StatusOK)
+}
+
+// UnassignVehicleHandler 車両の割り当てを解除する（POST /api/vehicles/:id/unassign）
+// 退職者を含め、この車両を指しているスタッフすべてから外す
+func UnassignVehicleHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	if _, found, code := fetchVehicle(ctx, client, id); code != "" || !found {
+		if code == "" {
+			code = apierror.CodeNotFound
+		}
+		apierror.Respond(c, code)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("vehicle", "eq."+id)
+	if _, _, patchErr := client.Patch(ctx, "/rest/v1/staff", q, map[string]any{"vehicle": nil}); patchErr != nil {
+		log.Printf("DB_003: supabase patch error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	respondVehicle(c, ctx, client, id, http.StatusOK)
+}
+
+// activeVehicleIndex 在職スタッフどうしで同じ車両を使えないようにする部分一意インデックス
+const activeVehicleIndex = "staff_active_vehicle_key"
+
+// isVehicleTaken staff の書き込みが車両の重複（同時の割り当てで先を越された）で失敗したか
+func isVehicleTaken(body []byte) bool {
+	return supa.IsUniqueViolation(body, activeVehicleIndex)
+}
+
+// respondVehicleTaken 書き込み時に検出した車両の重複を 409 で返す（割り当て中のスタッフが分かれば添える）
+func respondVehicleTaken(c *gin.Context, ctx context.Context, client *supa.Client, vehicleID, staffID string) {
+	if v, found, code := fetchVehicle(ctx, client, vehicleID); code == "" && found {
+		if d := v.driverOtherThan(staffID); d != nil {
+			apierror.RespondWith(c, apierror.CodeVehicleAssigned, "", d)
+			return
+		}
+	}
+	apierror.Respond(c, apierror.CodeVehicleAssigned)
+}
+
+// checkVehicleAssignable スタッフの更新・作成で車両を指定した場合に、他の在職スタッフが使っていないか確かめる
+// 使われている場合は 409 を応答済みで ok=false。staffID は作成時は空
+// 同時の割り当ては DB の activeVehicleIndex で防ぎ、書き込み時の違反は respondVehicleTaken で返す
+func checkVehicleAssignable(c *gin.Context, ctx context.Context, client *supa.Client, vehicleID *string, staffID string) bool {
+	if vehicleID == nil || strings.TrimSpace(*vehicleID) == "" {
+		return true
+	}
+	vehicle, found, code := fetchVehicle(ctx, client, strings.TrimSpace(*vehicleID))
+	if code != "" {
+		apierror.Respond(c, code)
+		return false
+	}
+	if !found {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "vehicleId", "exists", ""),
+		})
+		return false
+	}
+	if d := vehicle.driverOtherThan(staffID); d != nil {
+		apierror.RespondWith(c, apierror.CodeVehicleAssigned, "", d)
+		return false
+	}
+	return true
+}
+
+// fetchVehicle 車両1件を割り当て中の在職スタッフ付きで取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchVehicle(ctx context.Context, client *supa.Client, id string) (vehicleRow, bool, string) {
+	q := url.Values{}
+	q.Set("select", vehicleSelect)
+	q.Set("id", "eq."+id)
+	q.Set("staff.status", "is.true")
+	q.Set("limit", "1")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_car", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_car error: %v", getErr)
+		return vehicleRow{}, false, apierror.CodeDBInit
+	}
+	var rows []vehicleRow
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_car): %v", err)
+		return vehicleRow{}, false, apierror.CodeDBDecode
+	}
+	if len(rows) == 0 {
+		return vehicleRow{}, false, ""
+	}
+	return rows[0], true, ""
+}
+
+// respondVehicle 車両1件を取得し直して返す（作成・更新・割り当ての応答）
+func respondVehicle(c *gin.Context, ctx context.Context, client *supa.Client, id string, status int) {
+	v, found, code := fetchVehicle(ctx, client, id)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildVehicleResponse(v)
+	etag.Set(c, resp.Version)
+	c.JSON(status, resp)
+}
+
+// driver 割り当て中の在職スタッフ（複数いる古いデータは最初の1人）
+func (v vehicleRow) driver() *VehicleDriver {
+	return v.driverOtherThan("")
+}
+
+// driverOtherThan staffID 以外で割り当て中の在職スタッフ
+func (v vehicleRow) driverOtherThan(staffID string) *VehicleDriver {
+	for _, s := range v.Staff {
+		if s.ID == staffID || s.Status == nil || !*s.Status {
+			continue
+		}
+		return &VehicleDriver{
+			ID:   s.ID,
+			Sfid: coalesce(toStringPtrFromIntPtr(s.SFID), ""),
+			Name: strings.TrimSpace(coalesce(s.LastName, "") + " " + coalesce(s.FirstName, "")),
+		}
+	}
+	return nil
+}
+
+func buildVehicleResponse(v vehicleRow) VehicleResponse {
+	return VehicleResponse{
+		ID:          v.ID,
+		CarType:     v.CarType,
+		Color:       v.Color,
+		Capacity:    v.Capacity,
+		Area:        v.Area,
+		ClassNumber: v.ClassNumber,
+		Character:   v.Character,
+		Number:      v.Number,
+		Plate:       v.plateLabel(),
+		IsETC:       v.IsETC != nil && *v.IsETC,
+		Driver:      v.driver(),
+		CreatedAt:   coalesce(v.CreatedAt, ""),
+		UpdatedAt:   coalesce(v.UpdatedAt, ""),
+		Version:     etag.FromUpdatedAt(v.UpdatedAt),
+	}
+}
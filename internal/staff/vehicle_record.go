@@ -0,0 +1,720 @@
+package staff
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierror "nissyo/internal/apierror"
+	etag "nissyo/internal/etag"
+	supa "nissyo/internal/supabase"
+	validate "nissyo/internal/validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 車両の記録の種類
+const (
+	recordShaken              = "shaken"               // 車検
+	recordCompulsoryInsurance = "compulsory_insurance" // 自賠責保険
+	recordVoluntaryInsurance  = "voluntary_insurance"  // 任意保険
+	recordOilChange           = "oil_change"           // オイル交換
+	recordAccident            = "accident"             // 事故
+	recordOther               = "other"                // その他の整備
+)
+
+// complianceKinds 有効期限のある記録の種類（種類ごとに期限の最も遅い記録をその車両の現在の車検・保険とする）
+var complianceKinds = []string{recordShaken, recordCompulsoryInsurance, recordVoluntaryInsurance}
+
+// insuranceKinds 保険の種類（切れている車両はドライバー検索で印を付ける）
+var insuranceKinds = []string{recordCompulsoryInsurance, recordVoluntaryInsurance}
+
+// 車検・保険の一覧で期限間近とみなす日数（days 省略時）と上限
+const (
+	vehicleAlertDaysDefault = 30
+	vehicleAlertDaysMax     = 365
+)
+
+var vehicleRecordSelect = "id,car_id,kind,date,expires_on,mileage,cost,provider,reference,note,document_ref,created_at,updated_at"
+
+// VehicleRecordDTO staff_car_record の行
+type VehicleRecordDTO struct {
+	ID          string  `json:"id"`
+	CarID       string  `json:"car_id"`
+	Kind        string  `json:"kind"`
+	Date        *string `json:"date"`
+	ExpiresOn   *string `json:"expires_on"`
+	Mileage     *int    `json:"mileage"`
+	Cost        *int    `json:"cost"`
+	Provider    *string `json:"provider"`
+	Reference   *string `json:"reference"`
+	Note        *string `json:"note"`
+	DocumentRef *string `json:"document_ref"`
+	CreatedAt   *string `json:"created_at,omitempty"`
+	UpdatedAt   *string `json:"updated_at,omitempty"`
+}
+
+// VehicleRecordResponse 車両の記録の API の形
+type VehicleRecordResponse struct {
+	ID          string  `json:"id"`
+	VehicleID   string  `json:"vehicleId"`
+	Kind        string  `json:"kind"`
+	Date        *string `json:"date,omitempty"`
+	ExpiresOn   *string `json:"expiresOn,omitempty"`
+	Mileage     *int    `json:"mileage,omitempty"`
+	Cost        *int    `json:"cost,omitempty"`
+	Provider    *string `json:"provider,omitempty"`
+	Reference   *string `json:"reference,omitempty"`
+	Note        *string `json:"note,omitempty"`
+	DocumentRef *string `json:"documentRef,omitempty"`
+	CreatedAt   string  `json:"createdAt,omitempty"`
+	UpdatedAt   string  `json:"updatedAt,omitempty"`
+	Version     string  `json:"version"` // 楽観的排他制御用（ETag と同じ値）
+}
+
+// VehicleCompliance 車両の現在の車検・保険（種類ごとに有効期限の最も遅い記録）
+type VehicleCompliance struct {
+	Kind      string `json:"kind"`
+	RecordID  string `json:"recordId"`
+	ExpiresOn string `json:"expiresOn"`
+	DaysLeft  int    `json:"daysLeft"` // 期限切れは負
+	Status    string `json:"status"`   // valid / expiring / expired（本日時点）
+}
+
+type VehicleRecordListResponse struct {
+	VehicleID  string                  `json:"vehicleId"`
+	Compliance []VehicleCompliance     `json:"compliance"` // 記録の無い種類は含まない
+	Records    []VehicleRecordResponse `json:"records"`
+}
+
+// VehicleAlert 車検・保険が期限間近・期限切れの車両
+type VehicleAlert struct {
+	VehicleCompliance
+	Vehicle VehicleResponse `json:"vehicle"`
+}
+
+// CreateVehicleRecordRequest 車両の記録の登録（車検・保険は有効期限、オイル交換・事故は日付が必須）
+type CreateVehicleRecordRequest struct {
+	Kind        string  `json:"kind" binding:"required,oneof=shaken compulsory_insurance voluntary_insurance oil_change accident other"`
+	Date        *string `json:"date" binding:"omitempty,ymd"`
+	ExpiresOn   *string `json:"expiresOn" binding:"omitempty,ymd"`
+	Mileage     *int    `json:"mileage" binding:"omitempty,min=0"`
+	Cost        *int    `json:"cost" binding:"omitempty,min=0"`
+	Provider    *string `json:"provider" binding:"omitempty,max=100"`
+	Reference   *string `json:"reference" binding:"omitempty,max=50"`
+	Note        *string `json:"note" binding:"omitempty,max=1000"`
+	DocumentRef *string `json:"documentRef" binding:"omitempty,max=1000"`
+}
+
+// UpdateVehicleRecordRequest 車両の記録の部分更新（指定した項目のみ。空文字で未設定に戻す）
+type UpdateVehicleRecordRequest struct {
+	Kind        *string `json:"kind" binding:"omitempty,oneof=shaken compulsory_insurance voluntary_insurance oil_change accident other"`
+	Date        *string `json:"date" binding:"omitempty,eq=|ymd"`
+	ExpiresOn   *string `json:"expiresOn" binding:"omitempty,eq=|ymd"`
+	Mileage     *int    `json:"mileage" binding:"omitempty,min=0"`
+	Cost        *int    `json:"cost" binding:"omitempty,min=0"`
+	Provider    *string `json:"provider" binding:"omitempty,max=100"`
+	Reference   *string `json:"reference" binding:"omitempty,max=50"`
+	Note        *string `json:"note" binding:"omitempty,max=1000"`
+	DocumentRef *string `json:"documentRef" binding:"omitempty,max=1000"`
+	// If-Match ヘッダーを送れないクライアント向け（GET で返した version をそのまま送る）
+	Version *string `json:"version"`
+}
+
+// GetVehicleRecordsHandler 車両の記録の一覧と現在の車検・保険（GET /api/vehicles/:id/records。日付の新しい順）
+// ?kind=oil_change,accident で種類を絞る（compliance は絞らない）
+func GetVehicleRecordsHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	kinds := splitList(c.Query("kind"))
+	if !validRecordKinds(kinds) {
+		apierror.RespondWith(c, apierror.CodeValidation, "", []apierror.FieldError{
+			validate.NewFieldError(apierror.Lang(c), "kind", "oneof", "shaken compulsory_insurance voluntary_insurance oil_change accident other"),
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	if _, found, code := fetchVehicle(ctx, client, id); code != "" || !found {
+		if code == "" {
+			code = apierror.CodeNotFound
+		}
+		apierror.Respond(c, code)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", vehicleRecordSelect)
+	q.Set("car_id", "eq."+id)
+	q.Set("order", "date.desc.nullslast,created_at.desc")
+	q.Set("limit", "500")
+	rows, code := getVehicleRecords(ctx, client, q)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	today := dayStart(time.Now().In(jst))
+	resp := VehicleRecordListResponse{
+		VehicleID:  id,
+		Compliance: make([]VehicleCompliance, 0, len(complianceKinds)),
+		Records:    make([]VehicleRecordResponse, 0, len(rows)),
+	}
+	current := currentCompliance(rows)[id]
+	for _, k := range complianceKinds {
+		if r, ok := current[k]; ok {
+			resp.Compliance = append(resp.Compliance, buildVehicleCompliance(r, today, vehicleAlertDaysDefault))
+		}
+	}
+	for _, r := range rows {
+		if len(kinds) == 0 || containsString(kinds, r.Kind) {
+			resp.Records = append(resp.Records, buildVehicleRecordResponse(r))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetVehicleRecordDetailHandler 車両の記録1件（GET /api/vehicles/:id/records/:recordId）
+func GetVehicleRecordDetailHandler(c *gin.Context) {
+	id, rid := c.Param("id"), c.Param("recordId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(rid) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	row, found, code := fetchVehicleRecord(ctx, client, id, rid)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	resp := buildVehicleRecordResponse(row)
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateVehicleRecordHandler 車両の記録を登録する（POST /api/vehicles/:id/records）
+// 車検・保険の更新は新しい記録として登録する（前の記録は履歴として残る）
+func CreateVehicleRecordHandler(c *gin.Context) {
+	id := c.Param("id")
+	if strings.TrimSpace(id) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req CreateVehicleRecordRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) == 0 {
+		fieldErrs = checkVehicleRecord(apierror.Lang(c), req.Kind, req.Date, req.ExpiresOn)
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	if _, found, code := fetchVehicle(ctx, client, id); code != "" || !found {
+		if code == "" {
+			code = apierror.CodeNotFound
+		}
+		apierror.Respond(c, code)
+		return
+	}
+
+	row := map[string]any{
+		"car_id":       id,
+		"kind":         req.Kind,
+		"date":         emptyToNil(req.Date),
+		"expires_on":   emptyToNil(req.ExpiresOn),
+		"mileage":      req.Mileage,
+		"cost":         req.Cost,
+		"provider":     emptyToNil(req.Provider),
+		"reference":    emptyToNil(req.Reference),
+		"note":         emptyToNil(req.Note),
+		"document_ref": emptyToNil(req.DocumentRef),
+	}
+	q := url.Values{}
+	q.Set("select", vehicleRecordSelect)
+	body, _, postErr := client.Post(ctx, "/rest/v1/staff_car_record", q, row)
+	if postErr != nil {
+		log.Printf("DB_003: supabase insert staff_car_record error: %v", postErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []VehicleRecordDTO
+	if err := json.Unmarshal(body, &rows); err != nil || len(rows) == 0 {
+		log.Printf("DB_002: json decode error (staff_car_record insert): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	resp := buildVehicleRecordResponse(rows[0])
+	etag.Set(c, resp.Version)
+	c.Header("Location", "/api/vehicles/"+id+"/records/"+resp.ID)
+	c.JSON(http.StatusCreated, resp)
+}
+
+// UpdateVehicleRecordHandler 車両の記録を部分更新する（PATCH /api/vehicles/:id/records/:recordId）
+// If-Match（または version）で取得時のバージョンを指定し、他の更新と競合した場合は 412 を返す
+func UpdateVehicleRecordHandler(c *gin.Context) {
+	id, rid := c.Param("id"), c.Param("recordId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(rid) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	var req UpdateVehicleRecordRequest
+	fieldErrs, err := validate.BindJSON(c, &req)
+	if err != nil {
+		log.Printf("VAL_002: invalid body: %v", err)
+		apierror.Respond(c, apierror.CodeValidation)
+		return
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	current, found, code := fetchVehicleRecord(ctx, client, id, rid)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+	if !found {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	currentVersion := etag.FromUpdatedAt(current.UpdatedAt)
+	if !etag.Check(c, req.Version, currentVersion, buildVehicleRecordResponse(current)) {
+		return
+	}
+
+	// 更新後の値で種類と日付の組み合わせを確かめる
+	kind, date, expires := current.Kind, current.Date, current.ExpiresOn
+	if req.Kind != nil {
+		kind = *req.Kind
+	}
+	if req.Date != nil {
+		date = req.Date
+	}
+	if req.ExpiresOn != nil {
+		expires = req.ExpiresOn
+	}
+	if fieldErrs := checkVehicleRecord(apierror.Lang(c), kind, date, expires); len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	patch := map[string]any{}
+	if req.Kind != nil {
+		patch["kind"] = *req.Kind
+	}
+	if req.Date != nil {
+		patch["date"] = emptyToNil(req.Date)
+	}
+	if req.ExpiresOn != nil {
+		patch["expires_on"] = emptyToNil(req.ExpiresOn)
+	}
+	if req.Mileage != nil {
+		patch["mileage"] = *req.Mileage
+	}
+	if req.Cost != nil {
+		patch["cost"] = *req.Cost
+	}
+	if req.Provider != nil {
+		patch["provider"] = emptyToNil(req.Provider)
+	}
+	if req.Reference != nil {
+		patch["reference"] = emptyToNil(req.Reference)
+	}
+	if req.Note != nil {
+		patch["note"] = emptyToNil(req.Note)
+	}
+	if req.DocumentRef != nil {
+		patch["document_ref"] = emptyToNil(req.DocumentRef)
+	}
+	if len(patch) == 0 {
+		c.JSON(http.StatusOK, gin.H{"updated": 0, "message": "no changes", "version": currentVersion})
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+rid)
+	q.Set("car_id", "eq."+id)
+	q.Set("updated_at", "eq."+coalesce(current.UpdatedAt, ""))
+	q.Set("select", vehicleRecordSelect)
+	body, _, patchErr := client.Patch(ctx, "/rest/v1/staff_car_record", q, patch)
+	if patchErr != nil {
+		log.Printf("DB_003: supabase patch staff_car_record error: %v", patchErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []VehicleRecordDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error: %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(rows) == 0 {
+		// 読み込みから更新までの間に他の更新が入った
+		if latest, found, code := fetchVehicleRecord(ctx, client, id, rid); code == "" && found {
+			resp := buildVehicleRecordResponse(latest)
+			etag.Conflict(c, resp.Version, resp)
+			return
+		}
+		etag.Conflict(c, "", nil)
+		return
+	}
+	resp := buildVehicleRecordResponse(rows[0])
+	etag.Set(c, resp.Version)
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteVehicleRecordHandler 車両の記録を削除する（DELETE /api/vehicles/:id/records/:recordId）
+func DeleteVehicleRecordHandler(c *gin.Context) {
+	id, rid := c.Param("id"), c.Param("recordId")
+	if strings.TrimSpace(id) == "" || strings.TrimSpace(rid) == "" {
+		apierror.Respond(c, apierror.CodeMissingID)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("id", "eq."+rid)
+	q.Set("car_id", "eq."+id)
+	body, _, delErr := client.Delete(ctx, "/rest/v1/staff_car_record", q)
+	if delErr != nil {
+		log.Printf("DB_003: supabase delete staff_car_record error: %v", delErr)
+		apierror.Respond(c, apierror.CodeDBUpdate)
+		return
+	}
+	var rows []VehicleRecordDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_car_record delete): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	if len(rows) == 0 {
+		apierror.Respond(c, apierror.CodeNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetVehicleAlertsHandler 車検・保険が期限間近・期限切れの車両（GET /api/vehicles/alerts。有効期限順）
+// 各車両の種類ごとに有効期限の最も遅い記録で判定する（更新済みの車両は含まない）
+//
+//	days=30                       残り days 日以内を期限間近とする（既定 30、最大 365）
+//	kind=shaken,voluntary_insurance 種類で絞る（既定は車検・自賠責保険・任意保険）
+func GetVehicleAlertsHandler(c *gin.Context) {
+	lang := apierror.Lang(c)
+	var fieldErrs []apierror.FieldError
+	days := vehicleAlertDaysDefault
+	if v := strings.TrimSpace(c.Query("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		switch {
+		case err != nil || n < 0:
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "days", "min", "0"))
+		case n > vehicleAlertDaysMax:
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "days", "max", strconv.Itoa(vehicleAlertDaysMax)))
+		default:
+			days = n
+		}
+	}
+	kinds := splitList(c.Query("kind"))
+	for _, k := range kinds {
+		if !containsString(complianceKinds, k) {
+			fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "kind", "oneof", strings.Join(complianceKinds, " ")))
+			break
+		}
+	}
+	if len(kinds) == 0 {
+		kinds = complianceKinds
+	}
+	if len(fieldErrs) > 0 {
+		apierror.RespondWith(c, apierror.CodeValidation, "", fieldErrs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	client, err := supa.NewClientFromEnv()
+	if err != nil {
+		log.Printf("DB_001: failed to init supabase client: %v", err)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+
+	q := url.Values{}
+	q.Set("select", "id,car_id,kind,expires_on")
+	q.Set("kind", "in.("+strings.Join(kinds, ",")+")")
+	q.Set("expires_on", "not.is.null")
+	q.Set("limit", "10000")
+	rows, code := getVehicleRecords(ctx, client, q)
+	if code != "" {
+		apierror.Respond(c, code)
+		return
+	}
+
+	today := dayStart(time.Now().In(jst))
+	var items []VehicleCompliance
+	var carIDs []string
+	for carID, current := range currentCompliance(rows) {
+		var hit bool
+		for _, r := range current {
+			item := buildVehicleCompliance(r, today, days)
+			if item.Status != expiryValid {
+				items = append(items, item)
+				hit = true
+			}
+		}
+		if hit {
+			carIDs = append(carIDs, carID)
+		}
+	}
+	out := make([]VehicleAlert, 0, len(items))
+	if len(items) == 0 {
+		c.JSON(http.StatusOK, out)
+		return
+	}
+
+	vq := url.Values{}
+	vq.Set("select", vehicleSelect)
+	vq.Set("id", "in.("+strings.Join(carIDs, ",")+")")
+	vq.Set("staff.status", "is.true")
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_car", vq)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_car error: %v", getErr)
+		apierror.Respond(c, apierror.CodeDBInit)
+		return
+	}
+	var vehicles []vehicleRow
+	if err := json.Unmarshal(body, &vehicles); err != nil {
+		log.Printf("DB_002: json decode error (staff_car): %v", err)
+		apierror.Respond(c, apierror.CodeDBDecode)
+		return
+	}
+	byID := make(map[string]VehicleResponse, len(vehicles))
+	for _, v := range vehicles {
+		byID[v.ID] = buildVehicleResponse(v)
+	}
+	carOf := make(map[string]string, len(rows))
+	for _, r := range rows {
+		carOf[r.ID] = r.CarID
+	}
+	for _, item := range items {
+		v, ok := byID[carOf[item.RecordID]]
+		if !ok {
+			continue
+		}
+		out = append(out, VehicleAlert{VehicleCompliance: item, Vehicle: v})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].DaysLeft != out[j].DaysLeft {
+			return out[i].DaysLeft < out[j].DaysLeft
+		}
+		return out[i].Vehicle.ID < out[j].Vehicle.ID
+	})
+	c.JSON(http.StatusOK, out)
+}
+
+// lapsedVehicles day（JST の日付）の時点で車検・保険が切れている車両と、その種類
+// 記録の無い種類は切れているとみなさない。失敗時はエラーコードを返す（ログ出力済み）
+func lapsedVehicles(ctx context.Context, client *supa.Client, carIDs []string, day time.Time) (map[string][]string, string) {
+	lapsed := make(map[string][]string)
+	if len(carIDs) == 0 {
+		return lapsed, ""
+	}
+	q := url.Values{}
+	q.Set("select", "id,car_id,kind,expires_on")
+	q.Set("car_id", "in.("+strings.Join(carIDs, ",")+")")
+	q.Set("kind", "in.("+strings.Join(complianceKinds, ",")+")")
+	q.Set("expires_on", "not.is.null")
+	q.Set("limit", "10000")
+	rows, code := getVehicleRecords(ctx, client, q)
+	if code != "" {
+		return nil, code
+	}
+	for carID, current := range currentCompliance(rows) {
+		for _, k := range complianceKinds {
+			r, ok := current[k]
+			if !ok {
+				continue
+			}
+			if days, ok := daysUntil(r.ExpiresOn, day); ok && days < 0 {
+				lapsed[carID] = append(lapsed[carID], k)
+			}
+		}
+	}
+	return lapsed, ""
+}
+
+// currentCompliance 車両ごと・種類ごとに有効期限の最も遅い記録（有効期限のある記録のみ）
+func currentCompliance(rows []VehicleRecordDTO) map[string]map[string]VehicleRecordDTO {
+	out := make(map[string]map[string]VehicleRecordDTO)
+	for _, r := range rows {
+		if r.ExpiresOn == nil || *r.ExpiresOn == "" || !containsString(complianceKinds, r.Kind) {
+			continue
+		}
+		byKind := out[r.CarID]
+		if byKind == nil {
+			byKind = make(map[string]VehicleRecordDTO)
+			out[r.CarID] = byKind
+		}
+		if cur, ok := byKind[r.Kind]; !ok || *r.ExpiresOn > *cur.ExpiresOn {
+			byKind[r.Kind] = r
+		}
+	}
+	return out
+}
+
+// checkVehicleRecord 種類ごとの必須の日付と日付の前後を確かめる
+func checkVehicleRecord(lang, kind string, date, expiresOn *string) []apierror.FieldError {
+	var fieldErrs []apierror.FieldError
+	switch {
+	case containsString(complianceKinds, kind) && coalesce(expiresOn, "") == "":
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "expiresOn", "required", ""))
+	case (kind == recordOilChange || kind == recordAccident) && coalesce(date, "") == "":
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "date", "required", ""))
+	}
+	if !datesInOrder(date, expiresOn) {
+		fieldErrs = append(fieldErrs, validate.NewFieldError(lang, "expiresOn", "date_order", ""))
+	}
+	return fieldErrs
+}
+
+// getVehicleRecords staff_car_record を取得する。失敗時はエラーコードを返す（ログ出力済み）
+func getVehicleRecords(ctx context.Context, client *supa.Client, q url.Values) ([]VehicleRecordDTO, string) {
+	body, _, getErr := client.Get(ctx, "/rest/v1/staff_car_record", q)
+	if getErr != nil {
+		log.Printf("DB_001: supabase get staff_car_record error: %v", getErr)
+		return nil, apierror.CodeDBInit
+	}
+	var rows []VehicleRecordDTO
+	if err := json.Unmarshal(body, &rows); err != nil {
+		log.Printf("DB_002: json decode error (staff_car_record): %v", err)
+		return nil, apierror.CodeDBDecode
+	}
+	return rows, ""
+}
+
+// fetchVehicleRecord 車両の記録1件を取得する
+// 失敗時はエラーコードを返す（ログ出力済み）。該当なしは found=false
+func fetchVehicleRecord(ctx context.Context, client *supa.Client, carID, id string) (VehicleRecordDTO, bool, string) {
+	q := url.Values{}
+	q.Set("select", vehicleRecordSelect)
+	q.Set("id", "eq."+id)
+	q.Set("car_id", "eq."+carID)
+	q.Set("limit", "1")
+	rows, code := getVehicleRecords(ctx, client, q)
+	if code != "" || len(rows) == 0 {
+		return VehicleRecordDTO{}, false, code
+	}
+	return rows[0], true, ""
+}
+
+func validRecordKinds(kinds []string) bool {
+	for _, k := range kinds {
+		switch k {
+		case recordShaken, recordCompulsoryInsurance, recordVoluntaryInsurance, recordOilChange, recordAccident, recordOther:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func buildVehicleCompliance(r VehicleRecordDTO, today time.Time, alertDays int) VehicleCompliance {
+	days, _ := daysUntil(r.ExpiresOn, today)
+	return VehicleCompliance{
+		Kind:      r.Kind,
+		RecordID:  r.ID,
+		ExpiresOn: coalesce(r.ExpiresOn, ""),
+		DaysLeft:  days,
+		Status:    expiryStatus(r.ExpiresOn, today, alertDays),
+	}
+}
+
+func buildVehicleRecordResponse(r VehicleRecordDTO) VehicleRecordResponse {
+	return VehicleRecordResponse{
+		ID:          r.ID,
+		VehicleID:   r.CarID,
+		Kind:        r.Kind,
+		Date:        r.Date,
+		ExpiresOn:   r.ExpiresOn,
+		Mileage:     r.Mileage,
+		Cost:        r.Cost,
+		Provider:    r.Provider,
+		Reference:   r.Reference,
+		Note:        r.Note,
+		DocumentRef: r.DocumentRef,
+		CreatedAt:   coalesce(r.CreatedAt, ""),
+		UpdatedAt:   coalesce(r.UpdatedAt, ""),
+		Version:     etag.FromUpdatedAt(r.UpdatedAt),
+	}
+}
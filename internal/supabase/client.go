@@ -115,3 +115,67 @@ func (c *Client) Patch(ctx context.Context, path string, query url.Values, paylo
 	}
 	return respBody, resp.StatusCode, nil
 }
+
+func (c *Client) Post(ctx context.Context, path string, query url.Values, payload any) ([]byte, int, error) {
+	return c.send(ctx, http.MethodPost, path, query, payload, "return=representation")
+}
+
+func (c *Client) Delete(ctx context.Context, path string, query url.Values) ([]byte, int, error) {
+	return c.send(ctx, http.MethodDelete, path, query, nil, "return=representation")
+}
+
+// RPC PostgREST 経由で DB 関数（/rest/v1/rpc/<fn>）を呼び出す
+func (c *Client) RPC(ctx context.Context, fn string, payload any) ([]byte, int, error) {
+	return c.send(ctx, http.MethodPost, "/rest/v1/rpc/"+fn, nil, payload, "")
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, payload any, prefer string) ([]byte, int, error) {
+	if c == nil {
+		return nil, 0, errors.New("nil client")
+	}
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	u.Path = fmt.Sprintf("%s%s", u.Path, path)
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+
+	var bodyBytes []byte
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		bodyBytes = b
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("apikey", c.apiKey)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, resp.StatusCode, fmt.Errorf("supabase error status=%d", resp.StatusCode)
+	}
+	return respBody, resp.StatusCode, nil
+}
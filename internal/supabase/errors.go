@@ -0,0 +1,25 @@
+package supabase
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// uniqueViolation PostgreSQL の一意制約違反の SQLSTATE
+const uniqueViolation = "23505"
+
+// apiError PostgREST のエラー応答
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// IsUniqueViolation 応答本文が制約（インデックス）name の一意制約違反か
+// PostgREST は一意制約違反・外部キー違反をどちらも 409 で返すため、制約名で区別する
+func IsUniqueViolation(body []byte, name string) bool {
+	var e apiError
+	if err := json.Unmarshal(body, &e); err != nil {
+		return false
+	}
+	return e.Code == uniqueViolation && strings.Contains(e.Message, `"`+name+`"`)
+}
@@ -0,0 +1,12 @@
+package supabase
+
+import "strings"
+
+// EscapeLike like / ilike の特殊文字（% _ \）を文字どおりに扱うようにする
+// * は PostgREST がワイルドカードに変換するため取り除く
+func EscapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	s = strings.ReplaceAll(s, "_", `\_`)
+	return strings.ReplaceAll(s, "*", "")
+}
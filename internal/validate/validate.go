@@ -0,0 +1,253 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	apierror "nissyo/internal/apierror"
+	plate "nissyo/internal/plate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	sfidPattern  = regexp.MustCompile(`^[0-9]{1,6}$`)
+	hhmmPattern  = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	phonePattern = regexp.MustCompile(`^[0-9+\-() ]{0,20}$`)
+	// loginIDPattern staff_account.login_id の形式（英数字と . _ -、3〜64文字）
+	loginIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{3,64}$`)
+	// masterKeyPattern マスタのキー（役職キーなど）の形式（英小文字で始まる英小文字・数字・_、50文字以内）
+	masterKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+	registerValidationsOnce sync.Once
+)
+
+// registerValidations gin のバリデータにカスタムタグと JSON 名の解決を登録する
+// 空文字はいずれのタグでも「値のクリア」として許可する
+func registerValidations() {
+	registerValidationsOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+		_ = v.RegisterValidation("sfid", func(fl validator.FieldLevel) bool {
+			s := strings.TrimSpace(fl.Field().String())
+			return s == "" || sfidPattern.MatchString(s)
+		})
+		_ = v.RegisterValidation("ymd", func(fl validator.FieldLevel) bool {
+			s := strings.TrimSpace(fl.Field().String())
+			if s == "" {
+				return true
+			}
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		})
+		_ = v.RegisterValidation("hhmm", func(fl validator.FieldLevel) bool {
+			s := fl.Field().String()
+			return s == "" || hhmmPattern.MatchString(s)
+		})
+		_ = v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+			return phonePattern.MatchString(fl.Field().String())
+		})
+		_ = v.RegisterValidation("login_id", func(fl validator.FieldLevel) bool {
+			s := strings.TrimSpace(fl.Field().String())
+			return s == "" || loginIDPattern.MatchString(s)
+		})
+		_ = v.RegisterValidation("master_key", func(fl validator.FieldLevel) bool {
+			s := strings.TrimSpace(fl.Field().String())
+			return s == "" || masterKeyPattern.MatchString(s)
+		})
+		_ = v.RegisterValidation("plate", func(fl validator.FieldLevel) bool {
+			s := strings.TrimSpace(fl.Field().String())
+			if s == "" {
+				return true
+			}
+			_, ok := plate.Parse(s)
+			return ok
+		})
+		_ = v.RegisterValidation("plate_region", func(fl validator.FieldLevel) bool {
+			s := plate.NormalizeRegion(fl.Field().String())
+			return s == "" || plate.ValidRegion(s)
+		})
+		_ = v.RegisterValidation("plate_class", func(fl validator.FieldLevel) bool {
+			s := plate.NormalizeClass(fl.Field().String())
+			return s == "" || plate.ValidClass(s)
+		})
+		_ = v.RegisterValidation("plate_kana", func(fl validator.FieldLevel) bool {
+			s := plate.NormalizeKana(fl.Field().String())
+			return s == "" || plate.ValidKana(s)
+		})
+		_ = v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+			return ValidPassword(fl.Field().String())
+		})
+	})
+}
+
+// ValidPassword パスワードの条件（8文字以上・72バイト以内・英字と数字を含む）
+// 72バイトは bcrypt が扱える上限
+func ValidPassword(s string) bool {
+	if utf8.RuneCountInString(s) < 8 || len(s) > 72 {
+		return false
+	}
+	var letter, digit bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return letter && digit
+}
+
+// fieldRule 検証タグごとのエラーコードと日英メッセージ（%s にはタグのパラメータが入る）
+type fieldRule struct {
+	code string
+	ja   string
+	en   string
+}
+
+var fieldRules = map[string]fieldRule{
+	"required":          {code: "required", ja: "必須項目です", en: "is required"},
+	"max.string":        {code: "too_long", ja: "%s文字以内で入力してください", en: "must be at most %s characters"},
+	"max":               {code: "out_of_range", ja: "%s以下の値を入力してください", en: "must be at most %s"},
+	"min":               {code: "out_of_range", ja: "%s以上の値を入力してください", en: "must be at least %s"},
+	"oneof":             {code: "invalid_choice", ja: "次のいずれかを指定してください: %s", en: "must be one of: %s"},
+	"shift_range":       {code: "invalid_range", ja: "出勤と退勤に同じ時刻は指定できません（退勤が前の時刻なら翌日退勤）", en: "start and end must differ (an end before the start means the next day)"},
+	"shift_overlap":     {code: "overlap", ja: "他の勤務と時間が重なっています", en: "overlaps another shift"},
+	"sfid":              {code: "invalid_format", ja: "6桁以内の数字を入力してください", en: "must be a number of up to 6 digits"},
+	"ymd":               {code: "invalid_format", ja: "YYYY-MM-DD 形式の日付を入力してください", en: "must be a date in YYYY-MM-DD format"},
+	"not_future":        {code: "out_of_range", ja: "本日以前の日付を入力してください（先の日付の退職はその日に処理してください）", en: "must be today or earlier (record a scheduled retirement on that date)"},
+	"date_order":        {code: "invalid_range", ja: "交付日・実施日より前の有効期限は指定できません", en: "must not be before the issue or service date"},
+	"datetime":          {code: "invalid_format", ja: "YYYY-MM-DDTHH:MM 形式の日時を入力してください", en: "must be a date-time in YYYY-MM-DDTHH:MM format"},
+	"hhmm":              {code: "invalid_format", ja: "HH:MM 形式の時刻を入力してください", en: "must be a time in HH:MM format"},
+	"phone":             {code: "invalid_format", ja: "20文字以内の電話番号を入力してください", en: "must be a phone number of up to 20 characters"},
+	"plate":             {code: "invalid_format", ja: "ナンバーは「京都 500 あ 12-34」の形式で入力してください", en: "must be a plate number such as 京都 500 あ 12-34"},
+	"plate_region":      {code: "invalid_format", ja: "地名は漢字・かなの4文字以内で入力してください", en: "must be up to 4 kanji or kana characters"},
+	"plate_class":       {code: "invalid_format", ja: "分類番号は1〜3桁で入力してください（例: 500・33・30A）", en: "must be a class number of 1-3 characters (e.g. 500, 33, 30A)"},
+	"plate_serial":      {code: "invalid_format", ja: "一連指定番号は1〜4桁の数字で入力してください（例: 12-34・・・12）", en: "must be a serial number of 1-4 digits (e.g. 12-34, ・・12)"},
+	"plate_kana":        {code: "invalid_kana", ja: "ナンバーに使えるひらがな1文字を入力してください（お・し・へ・ん は使えません）", en: "must be a single hiragana used on plates (お, し, へ and ん are not used)"},
+	"password":          {code: "weak_password", ja: "英字と数字を含む8文字以上（72バイト以内）のパスワードを入力してください", en: "must be at least 8 characters (up to 72 bytes) and contain letters and digits"},
+	"password_mismatch": {code: "mismatch", ja: "現在のパスワードが正しくありません", en: "does not match the current password"},
+	"password_reuse":    {code: "same_as_current", ja: "現在と異なるパスワードを入力してください", en: "must differ from the current password"},
+	"totp":              {code: "invalid_code", ja: "確認コードが正しくありません", en: "invalid verification code"},
+	"login_id":          {code: "invalid_format", ja: "英数字と . _ - で3〜64文字のIDを入力してください", en: "must be 3-64 characters of letters, digits, '.', '_' or '-'"},
+	"master_key":        {code: "invalid_format", ja: "英小文字で始まる英小文字・数字・_ の50文字以内で入力してください", en: "must be up to 50 lowercase letters, digits or '_' starting with a letter"},
+	"eq=|email":         {code: "invalid_format", ja: "メールアドレスの形式が正しくありません", en: "must be a valid email address"},
+	"eq=|uuid":          {code: "invalid_format", ja: "UUID 形式で指定してください", en: "must be a UUID"},
+	"eq=|url":           {code: "invalid_format", ja: "URL の形式が正しくありません", en: "must be a valid URL"},
+	"eq=|ymd":           {code: "invalid_format", ja: "YYYY-MM-DD 形式の日付を入力してください", en: "must be a date in YYYY-MM-DD format"},
+	"uuid":              {code: "invalid_format", ja: "UUID 形式で指定してください", en: "must be a UUID"},
+	"active_staff":      {code: "inactive", ja: "在職中のスタッフを指定してください", en: "must be an active staff member"},
+	"exists":            {code: "not_found", ja: "登録されていない値です", en: "does not exist"},
+	"unique":            {code: "duplicate", ja: "同じ値が重複しています", en: "must not contain duplicates"},
+	"invalid_type":      {code: "invalid_type", ja: "%s 型で指定してください", en: "must be of type %s"},
+	"unknown_field":     {code: "unknown_field", ja: "未対応の項目です", en: "unknown field"},
+	"invalid":           {code: "invalid", ja: "値が不正です（%s）", en: "failed on %s"},
+}
+
+// NewFieldError ハンドラー側の業務チェック用。rule は fieldRules のキー（未登録なら invalid）
+func NewFieldError(lang, field, rule, param string) apierror.FieldError {
+	r, ok := fieldRules[rule]
+	if !ok {
+		r, param = fieldRules["invalid"], rule
+	}
+	msg := r.ja
+	if lang == apierror.LangEN {
+		msg = r.en
+	}
+	if strings.Contains(msg, "%s") {
+		msg = fmt.Sprintf(msg, param)
+	}
+	return apierror.FieldError{Field: field, Code: r.code, Message: msg}
+}
+
+// BindJSON 未知のフィールドを拒否しつつボディを構造体へ読み込み、binding タグで検証する
+// 戻り値の FieldError が空でない場合はフィールド単位のエラー、error はそれ以外の読み込み失敗
+func BindJSON(c *gin.Context, dst any) ([]apierror.FieldError, error) {
+	registerValidations()
+	lang := apierror.Lang(c)
+
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		if fe, ok := decodeFieldError(lang, err); ok {
+			return []apierror.FieldError{fe}, nil
+		}
+		return nil, err
+	}
+
+	return Struct(lang, dst)
+}
+
+// Struct JSON 以外（CSV など）から組み立てた構造体を binding タグで検証する
+func Struct(lang string, v any) ([]apierror.FieldError, error) {
+	registerValidations()
+	if err := binding.Validator.ValidateStruct(v); err != nil {
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			out := make([]apierror.FieldError, 0, len(verrs))
+			for _, fe := range verrs {
+				out = append(out, toFieldError(lang, fe))
+			}
+			return out, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// decodeFieldError JSON デコードエラーのうちフィールドを特定できるものを FieldError に変換する
+func decodeFieldError(lang string, err error) (apierror.FieldError, bool) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return NewFieldError(lang, typeErr.Field, "invalid_type", typeErr.Type.String()), true
+	}
+	// encoding/json は未知フィールドを専用の型で返さないためメッセージから取り出す
+	const unknownPrefix = "json: unknown field "
+	if msg := err.Error(); strings.HasPrefix(msg, unknownPrefix) {
+		field := strings.Trim(strings.TrimPrefix(msg, unknownPrefix), `"`)
+		return NewFieldError(lang, field, "unknown_field", ""), true
+	}
+	return apierror.FieldError{}, false
+}
+
+func toFieldError(lang string, fe validator.FieldError) apierror.FieldError {
+	field := fe.Namespace()
+	// 先頭の構造体名を取り除き、JSON のパス表記にする（例: car.capacity, schedule[mon].start）
+	if idx := strings.Index(field, "."); idx >= 0 {
+		field = field[idx+1:]
+	}
+	rule := fe.Tag()
+	if rule == "max" && fe.Kind() == reflect.String {
+		rule = "max.string"
+	}
+	return NewFieldError(lang, field, rule, fe.Param())
+}
@@ -1,12 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	apierror "nissyo/internal/apierror"
+	area "nissyo/internal/area"
+	auth "nissyo/internal/auth"
 	config "nissyo/internal/config"
+	group "nissyo/internal/group"
+	idempotency "nissyo/internal/idempotency"
+	position "nissyo/internal/position"
+	ratelimit "nissyo/internal/ratelimit"
+	server "nissyo/internal/server"
 	shop "nissyo/internal/shop"
 	staff "nissyo/internal/staff"
 
@@ -20,7 +30,14 @@ func main() {
 		log.Printf("init: .env load warning: %v", err)
 	}
 
+	srv, err := server.New(server.LoadConfigFromEnv())
+	if err != nil {
+		log.Fatalf("init: server config error: %v", err)
+	}
+
 	router := gin.Default()
+	router.Use(apierror.RequestIDMiddleware())
+	router.Use(srv.Middleware())
 
 	// CORS AllowOrigins を環境変数 CORS_ALLOW_ORIGINS から読み込み（カンマ区切り）
 	originsEnv := os.Getenv("CORS_ALLOW_ORIGINS")
@@ -37,21 +54,131 @@ func main() {
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     allowOrigins,
-		AllowMethods:     []string{"GET", "PATCH", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "PUT", "PATCH", "POST", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", apierror.RequestIDHeader, idempotency.KeyHeader, "If-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Language", "ETag", "Location", apierror.RequestIDHeader, idempotency.ReplayedHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	if strings.TrimSpace(os.Getenv("AUTH_TOKEN_SECRET")) == "" {
+		log.Printf("init: AUTH_TOKEN_SECRET is not set; role-restricted endpoints will return 401")
+	}
+	if strings.TrimSpace(os.Getenv("TOTP_ENCRYPTION_KEY")) == "" {
+		log.Printf("init: TOTP_ENCRYPTION_KEY is not set; two-factor enrollment and verification will fail")
+	}
+
+	// 連絡先の表示（マスク解除）は許可した役割のみ、利用者ごとに1時間あたりの回数を制限する
+	revealRoles := auth.RolesFromEnv("CONTACT_REVEAL_ROLES", auth.RoleAdmin, auth.RoleManager, auth.RoleDispatcher)
+	revealLimit := 30
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("CONTACT_REVEAL_LIMIT_PER_HOUR"))); err == nil && n > 0 {
+		revealLimit = n
+	}
+
+	// ログインは接続元IPごとに1分あたりの回数を制限する（アカウントごとの停止は LOGIN_MAX_FAILURES）
+	loginLimit := 10
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("LOGIN_LIMIT_PER_MINUTE"))); err == nil && n > 0 {
+		loginLimit = n
+	}
+	// 初期パスワードを設定できる役割
+	accountAdminRoles := auth.RolesFromEnv("ACCOUNT_ADMIN_ROLES", auth.RoleAdmin, auth.RoleManager)
+	// スタッフの作成・更新でアカウントの項目（ログインID・権限・状態・調整率）を指定できるのも同じ役割
+	accountAdmin := auth.PermitIf(auth.PermAccountAdmin, accountAdminRoles...)
+	// マスタ（役職など）を変更できる役割
+	masterAdminRoles := auth.RolesFromEnv("MASTER_ADMIN_ROLES", auth.RoleAdmin, auth.RoleManager)
+
+	// 免許・資格の有効期限を毎日確認し、期限間近・期限切れに印を付ける
+	staff.StartQualificationExpiryJob(context.Background())
+
 	api := router.Group("/api")
+	api.Use(auth.Middleware())
+	api.Use(idempotency.Middleware(idempotency.NewStore(context.Background(), idempotency.DefaultTTL)))
 	{
 		api.GET("/staff-ledger", staff.GetStaffLedgerHandler)
+		api.POST("/staff", accountAdmin, staff.CreateStaffHandler)
 		api.GET("/staff/:id", staff.GetStaffDetailHandler)
-		api.PATCH("/staff/:id", staff.UpdateStaffHandler)
+		api.PATCH("/staff/:id", accountAdmin, staff.UpdateStaffHandler)
+		api.PUT("/staff/order", staff.ReorderStaffHandler)
+		api.POST("/staff/import", staff.ImportStaffHandler)
+		api.POST("/staff/:id/retire", staff.RetireStaffHandler)
+		api.POST("/staff/:id/reinstate", staff.ReinstateStaffHandler)
+		api.GET("/staff/:id/history", staff.GetStaffHistoryHandler)
+		api.GET("/staff/:id/history/:version", staff.GetStaffHistoryVersionHandler)
+		api.POST("/staff/:id/revert/:version", staff.RevertStaffHandler)
+		api.PUT("/staff/:id/shifts", staff.ReplaceShiftPatternHandler)
+		api.GET("/staff/:id/shift-overrides", staff.GetShiftOverridesHandler)
+		api.POST("/staff/:id/shift-overrides", staff.CreateShiftOverrideHandler)
+		api.DELETE("/staff/:id/shift-overrides/:overrideId", staff.DeleteShiftOverrideHandler)
+		api.GET("/staff/:id/qualifications", staff.GetQualificationsHandler)
+		api.POST("/staff/:id/qualifications", staff.CreateQualificationHandler)
+		api.GET("/staff/:id/qualifications/:qualificationId", staff.GetQualificationDetailHandler)
+		api.PATCH("/staff/:id/qualifications/:qualificationId", staff.UpdateQualificationHandler)
+		api.DELETE("/staff/:id/qualifications/:qualificationId", staff.DeleteQualificationHandler)
+		api.GET("/qualifications/alerts", staff.GetQualificationAlertsHandler)
+		api.GET("/roster", staff.GetRosterHandler)
+		api.GET("/drivers/available", staff.GetAvailableDriversHandler)
+		api.GET("/vehicles", staff.GetVehicleListHandler)
+		api.POST("/vehicles", staff.CreateVehicleHandler)
+		api.GET("/vehicles/:id", staff.GetVehicleDetailHandler)
+		api.PATCH("/vehicles/:id", staff.UpdateVehicleHandler)
+		api.DELETE("/vehicles/:id", staff.DeleteVehicleHandler)
+		api.POST("/vehicles/:id/assign", staff.AssignVehicleHandler)
+		api.POST("/vehicles/:id/unassign", staff.UnassignVehicleHandler)
+		api.GET("/vehicles/alerts", staff.GetVehicleAlertsHandler)
+		api.GET("/vehicles/:id/records", staff.GetVehicleRecordsHandler)
+		api.POST("/vehicles/:id/records", staff.CreateVehicleRecordHandler)
+		api.GET("/vehicles/:id/records/:recordId", staff.GetVehicleRecordDetailHandler)
+		api.PATCH("/vehicles/:id/records/:recordId", staff.UpdateVehicleRecordHandler)
+		api.DELETE("/vehicles/:id/records/:recordId", staff.DeleteVehicleRecordHandler)
+		api.GET("/positions", position.GetPositionListHandler)
+		api.GET("/positions/:id", position.GetPositionDetailHandler)
+		api.POST("/positions", auth.RequireRole(masterAdminRoles...), position.CreatePositionHandler)
+		api.PATCH("/positions/:id", auth.RequireRole(masterAdminRoles...), position.UpdatePositionHandler)
+		api.DELETE("/positions/:id", auth.RequireRole(masterAdminRoles...), position.DeletePositionHandler)
+		api.GET("/areas", area.GetAreaListHandler)
+		api.GET("/areas/:id", area.GetAreaDetailHandler)
+		api.POST("/areas", auth.RequireRole(masterAdminRoles...), area.CreateAreaHandler)
+		api.PATCH("/areas/:id", auth.RequireRole(masterAdminRoles...), area.UpdateAreaHandler)
+		api.DELETE("/areas/:id", auth.RequireRole(masterAdminRoles...), area.DeleteAreaHandler)
+		api.GET("/groups", group.GetGroupListHandler)
+		api.GET("/groups/:number", group.GetGroupDetailHandler)
+		api.POST("/groups", auth.RequireRole(masterAdminRoles...), group.CreateGroupHandler)
+		api.PATCH("/groups/:number", auth.RequireRole(masterAdminRoles...), group.UpdateGroupHandler)
+		api.DELETE("/groups/:number", auth.RequireRole(masterAdminRoles...), group.DeleteGroupHandler)
 		api.GET("/shops", shop.GetShopListHandler)
 		api.GET("/shops/:id", shop.GetShopDetailHandler)
+		api.PATCH("/shops/:id", shop.UpdateShopHandler)
+		api.GET("/meta/errors", apierror.MetaErrorsHandler)
+	}
+	login := ratelimit.Middleware(ratelimit.NewLimiter(context.Background(), loginLimit, time.Minute), func(c *gin.Context) string {
+		return c.ClientIP()
+	})
+	{
+		api.POST("/auth/login", login, auth.LoginHandler)
+		api.POST("/auth/refresh", login, auth.RefreshHandler)
+		api.POST("/auth/logout", auth.LogoutHandler)
+		api.POST("/auth/password", auth.ChangePasswordHandler)
+		api.PUT("/staff/:id/password", auth.RequireRole(accountAdminRoles...), auth.SetPasswordHandler)
+		api.POST("/auth/totp/verify", login, auth.VerifyTOTPHandler)
+		api.POST("/auth/totp/enroll", auth.EnrollTOTPHandler)
+		api.POST("/auth/totp/confirm", login, auth.ConfirmTOTPHandler)
+		api.POST("/auth/totp/recovery-codes", login, auth.RegenerateRecoveryCodesHandler)
+		api.POST("/auth/totp/disable", login, auth.DisableTOTPHandler)
+		api.DELETE("/staff/:id/totp", auth.RequireRole(accountAdminRoles...), auth.ResetTOTPHandler)
+	}
+	reveal := api.Group("",
+		auth.RequireRole(revealRoles...),
+		ratelimit.Middleware(ratelimit.NewLimiter(context.Background(), revealLimit, time.Hour), func(c *gin.Context) string {
+			p, _ := auth.Current(c)
+			return p.Subject
+		}),
+	)
+	{
+		reveal.POST("/staff/:id/reveal", staff.RevealStaffContactHandler)
+		reveal.POST("/shops/:id/reveal", shop.RevealShopContactHandler)
 	}
 
-	router.Run(":8080")
+	if err := srv.Run(router); err != nil {
+		log.Fatalf("server: %v", err)
+	}
 }
@@ -0,0 +1,9 @@
+-- Enforce unique staff.sfid so concurrent staff creation cannot allocate the same number
+begin;
+
+alter table if exists public.staff
+  add constraint staff_sfid_key unique (sfid);
+
+comment on constraint staff_sfid_key on public.staff is 'スタッフIDの重複防止（採番時の同時実行対策）';
+
+commit;
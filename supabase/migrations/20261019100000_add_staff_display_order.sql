@@ -0,0 +1,120 @@
+-- Persisted display order for the staff ledger and a locked reorder function
+begin;
+
+alter table if exists public.staff
+  add column if not exists display_order integer;
+
+comment on column public.staff.display_order is '台帳の表示順（1 始まり）';
+
+-- 既存行は作成順で採番する
+update public.staff s
+set display_order = o.pos
+from (
+  select id, row_number() over (order by created_at, id) as pos
+  from public.staff
+) o
+where s.id = o.id
+  and s.display_order is null;
+
+create index if not exists staff_display_order_idx on public.staff (display_order);
+
+-- 新規スタッフは末尾に追加する
+create or replace function public.staff_set_display_order()
+returns trigger as $$
+begin
+  if new.display_order is null then
+    select coalesce(max(display_order), 0) + 1 into new.display_order from public.staff;
+  end if;
+  return new;
+end;
+$$ language plpgsql;
+
+create trigger set_display_order
+before insert on public.staff
+for each row
+execute function public.staff_set_display_order();
+
+-- 表示順だけの変更では updated_at（ETag）を変えない（並べ替えで編集中の画面が 412 にならないようにする）
+create or replace function public.staff_set_updated_at()
+returns trigger as $$
+begin
+  if new.display_order is distinct from old.display_order
+     and (to_jsonb(new) - 'display_order') = (to_jsonb(old) - 'display_order') then
+    return new;
+  end if;
+  new.updated_at = now();
+  return new;
+end;
+$$ language plpgsql;
+
+drop trigger if exists set_timestamp on public.staff;
+create trigger set_timestamp
+before update on public.staff
+for each row
+execute function public.staff_set_updated_at();
+
+-- 並べ替え
+-- p_ids を指定順のまま p_before_id の直前（null なら末尾）へ移動し、全体を 1 から振り直す
+-- 全件の並べ替えは全 id を渡せばよい。同時実行は advisory lock で直列化する
+create or replace function public.reorder_staff(p_ids uuid[], p_before_id uuid default null)
+returns table (id uuid, display_order integer)
+language plpgsql
+as $$
+#variable_conflict use_column
+declare
+  v_anchor numeric;
+  v_count integer := coalesce(array_length(p_ids, 1), 0);
+begin
+  perform pg_advisory_xact_lock(hashtext('public.staff.display_order'));
+
+  if v_count = 0 then
+    raise exception 'ids is empty' using errcode = '22023';
+  end if;
+  if (select count(distinct x) from unnest(p_ids) as x) <> v_count then
+    raise exception 'ids contains duplicates' using errcode = '22023';
+  end if;
+  if p_before_id is not null and p_before_id = any(p_ids) then
+    raise exception 'before_id must not be one of ids' using errcode = '22023';
+  end if;
+  if exists (
+    select 1 from unnest(p_ids) as x
+    where not exists (select 1 from public.staff s where s.id = x)
+  ) or (p_before_id is not null and not exists (select 1 from public.staff s where s.id = p_before_id)) then
+    raise exception 'staff not found' using errcode = 'P0002';
+  end if;
+
+  -- 移動しない行の現在の並び
+  drop table if exists _staff_order;
+  create temporary table _staff_order on commit drop as
+  select s.id, row_number() over (order by s.display_order nulls last, s.created_at, s.id)::numeric as pos
+  from public.staff s
+  where s.id <> all(p_ids);
+
+  if p_before_id is null then
+    select coalesce(max(pos), 0) + 1 into v_anchor from _staff_order;
+  else
+    select pos into v_anchor from _staff_order where _staff_order.id = p_before_id;
+  end if;
+
+  -- 移動する行は anchor の直前に指定順で入れる
+  insert into _staff_order (id, pos)
+  select x.id, v_anchor - 1 + x.ord::numeric / (v_count + 1)
+  from unnest(p_ids) with ordinality as x(id, ord);
+
+  update public.staff s
+  set display_order = o.new_order
+  from (
+    select t.id, row_number() over (order by t.pos)::integer as new_order
+    from _staff_order t
+  ) o
+  where s.id = o.id
+    and s.display_order is distinct from o.new_order;
+
+  return query
+  select s.id, s.display_order from public.staff s order by s.display_order;
+end;
+$$;
+
+comment on function public.reorder_staff(uuid[], uuid) is 'スタッフ台帳の表示順を変更する（指定 id を before_id の直前へ移動）';
+
+commit;
@@ -0,0 +1,79 @@
+-- Snapshot staff (and the linked staff_car) on every write for history and revert
+begin;
+
+create table if not exists public.staff_history (
+  id bigint generated always as identity primary key,
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  version integer not null,
+  operation text not null check (operation in ('baseline', 'insert', 'update', 'car_update')),
+  staff jsonb not null,
+  staff_car jsonb,
+  changed_at timestamptz not null default now(),
+  constraint staff_history_staff_version_key unique (staff_id, version)
+);
+
+comment on table public.staff_history is 'スタッフ履歴（staff と紐付く staff_car の書き込みごとのスナップショット）';
+comment on column public.staff_history.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_history.version is 'スタッフごとの版番号（1 始まり）';
+comment on column public.staff_history.operation is '記録の契機（baseline: 導入時点 / insert / update / car_update: 車両の更新）';
+comment on column public.staff_history.staff is '書き込み後の staff 行';
+comment on column public.staff_history.staff_car is '書き込み後に紐付いていた staff_car 行';
+comment on column public.staff_history.changed_at is '記録日時';
+
+create index if not exists staff_history_staff_changed_at_idx on public.staff_history (staff_id, changed_at);
+
+create or replace function public.staff_history_record(p_staff public.staff, p_operation text)
+returns void as $$
+begin
+  insert into public.staff_history (staff_id, version, operation, staff, staff_car)
+  values (
+    p_staff.id,
+    coalesce((select max(h.version) from public.staff_history h where h.staff_id = p_staff.id), 0) + 1,
+    p_operation,
+    to_jsonb(p_staff),
+    (select to_jsonb(c) from public.staff_car c where c.id = p_staff.vehicle)
+  );
+end;
+$$ language plpgsql;
+
+create or replace function public.staff_history_on_staff()
+returns trigger as $$
+begin
+  -- 表示順だけの変更は記録しない
+  if tg_op = 'UPDATE' and (to_jsonb(new) - 'display_order') = (to_jsonb(old) - 'display_order') then
+    return null;
+  end if;
+  perform public.staff_history_record(new, lower(tg_op));
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger record_history
+after insert or update on public.staff
+for each row
+execute function public.staff_history_on_staff();
+
+create or replace function public.staff_history_on_staff_car()
+returns trigger as $$
+declare
+  s public.staff;
+begin
+  for s in select * from public.staff where vehicle = new.id loop
+    perform public.staff_history_record(s, 'car_update');
+  end loop;
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger record_staff_history
+after update on public.staff_car
+for each row
+execute function public.staff_history_on_staff_car();
+
+-- 導入時点の内容を版 1 として記録する
+insert into public.staff_history (staff_id, version, operation, staff, staff_car)
+select s.id, 1, 'baseline', to_jsonb(s), (select to_jsonb(c) from public.staff_car c where c.id = s.vehicle)
+from public.staff s
+where not exists (select 1 from public.staff_history h where h.staff_id = s.id);
+
+commit;
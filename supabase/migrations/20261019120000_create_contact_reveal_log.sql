@@ -0,0 +1,46 @@
+-- Audit log for privileged reveals of masked contact fields (staff / shop)
+begin;
+
+create table if not exists public.contact_reveal_log (
+  id bigint generated always as identity primary key,
+  resource_type text not null check (resource_type in ('staff', 'shop')),
+  resource_id uuid not null,
+  fields text[] not null,
+  reason text not null check (char_length(reason) between 1 and 500),
+  actor_subject text not null,
+  actor_name text,
+  actor_role text not null,
+  request_id text,
+  client_ip text,
+  created_at timestamptz not null default now()
+);
+
+comment on table public.contact_reveal_log is '連絡先の表示ログ（マスク前の電話番号などを表示した記録）';
+comment on column public.contact_reveal_log.resource_type is '対象の種類（staff / shop）';
+comment on column public.contact_reveal_log.resource_id is '対象のID';
+comment on column public.contact_reveal_log.fields is '表示した項目';
+comment on column public.contact_reveal_log.reason is '表示理由';
+comment on column public.contact_reveal_log.actor_subject is '操作者のID';
+comment on column public.contact_reveal_log.actor_name is '操作者名';
+comment on column public.contact_reveal_log.actor_role is '操作者の役割';
+comment on column public.contact_reveal_log.request_id is 'リクエストID（X-Request-ID）';
+comment on column public.contact_reveal_log.client_ip is '接続元IP';
+comment on column public.contact_reveal_log.created_at is '表示日時';
+
+create index if not exists contact_reveal_log_resource_idx on public.contact_reveal_log (resource_type, resource_id, created_at);
+create index if not exists contact_reveal_log_actor_idx on public.contact_reveal_log (actor_subject, created_at);
+
+-- 記録は追記のみ（更新・削除させない）
+create or replace function public.contact_reveal_log_immutable()
+returns trigger as $$
+begin
+  raise exception 'contact_reveal_log is append-only';
+end;
+$$ language plpgsql;
+
+create trigger contact_reveal_log_immutable
+before update or delete on public.contact_reveal_log
+for each row
+execute function public.contact_reveal_log_immutable();
+
+commit;
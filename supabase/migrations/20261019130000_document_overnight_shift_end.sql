@@ -0,0 +1,12 @@
+-- Document that an end time earlier than the start time means the shift ends the next day
+begin;
+
+comment on column public.staff.mon_end   is '月曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff.tue_end   is '火曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff.wed_end   is '水曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff.thu_end   is '木曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff.fri_end   is '金曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff.sat_end   is '土曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff.sun_end   is '日曜退勤時間（出勤時間より前の時刻は翌日の退勤）';
+
+commit;
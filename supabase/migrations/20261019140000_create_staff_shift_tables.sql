@@ -0,0 +1,167 @@
+-- Weekly shift patterns (several shifts per weekday) and per-date overrides for staff
+-- The legacy mon_start..sun_end columns are kept as a mirror of each weekday's first shift
+begin;
+
+create table if not exists public.staff_shift_pattern (
+  id uuid primary key default gen_random_uuid(),
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  weekday smallint not null check (weekday between 0 and 6),
+  start_time time not null,
+  end_time time not null check (end_time <> start_time),
+  created_at timestamptz not null default now(),
+  constraint staff_shift_pattern_staff_weekday_start_key unique (staff_id, weekday, start_time)
+);
+
+comment on table public.staff_shift_pattern is 'スタッフの週間勤務パターン（曜日ごとに複数の勤務を持てる）';
+comment on column public.staff_shift_pattern.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_shift_pattern.weekday is '曜日（0: 日曜 〜 6: 土曜）';
+comment on column public.staff_shift_pattern.start_time is '出勤時間';
+comment on column public.staff_shift_pattern.end_time is '退勤時間（出勤時間より前の時刻は翌日の退勤）';
+
+create table if not exists public.staff_shift_override (
+  id uuid primary key default gen_random_uuid(),
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  date date not null,
+  kind text not null check (kind in ('off', 'extra')),
+  start_time time,
+  end_time time,
+  note text,
+  created_at timestamptz not null default now(),
+  constraint staff_shift_override_times_check check (
+    (kind = 'off' and start_time is null and end_time is null)
+    or (kind = 'extra' and start_time is not null and end_time is not null and end_time <> start_time)
+  )
+);
+
+comment on table public.staff_shift_override is 'スタッフの日付指定の勤務変更（休み・追加勤務）';
+comment on column public.staff_shift_override.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_shift_override.date is '対象日（出勤日。翌日にまたぐ勤務も出勤日で登録する）';
+comment on column public.staff_shift_override.kind is '種類（off: その日の勤務パターンを休みにする / extra: 勤務を追加する）';
+comment on column public.staff_shift_override.start_time is '出勤時間（extra のみ）';
+comment on column public.staff_shift_override.end_time is '退勤時間（extra のみ。出勤時間より前の時刻は翌日の退勤）';
+comment on column public.staff_shift_override.note is '備考（祝日・振替など）';
+
+create index if not exists staff_shift_override_staff_date_idx on public.staff_shift_override (staff_id, date);
+create unique index if not exists staff_shift_override_off_key on public.staff_shift_override (staff_id, date) where kind = 'off';
+
+-- 既存の曜日別の列を勤務パターンへ移す
+insert into public.staff_shift_pattern (staff_id, weekday, start_time, end_time)
+select s.id, d.weekday, d.start_time, d.end_time
+from public.staff s
+cross join lateral (values
+  (0, s.sun_start, s.sun_end),
+  (1, s.mon_start, s.mon_end),
+  (2, s.tue_start, s.tue_end),
+  (3, s.wed_start, s.wed_end),
+  (4, s.thu_start, s.thu_end),
+  (5, s.fri_start, s.fri_end),
+  (6, s.sat_start, s.sat_end)
+) as d(weekday, start_time, end_time)
+where d.start_time is not null
+  and d.end_time is not null
+  and d.start_time <> d.end_time
+on conflict do nothing;
+
+-- 曜日別の列が直接更新された場合（一覧の部分更新・CSV 取り込み・履歴からの復元）は、その曜日の勤務パターンを1件に置き換える
+-- replace_staff_shift_pattern からの列の同期では動かさない
+create or replace function public.staff_sync_shift_pattern()
+returns trigger as $$
+declare
+  d record;
+begin
+  if current_setting('nissyo.shift_pattern_sync', true) = 'off' then
+    return null;
+  end if;
+  for d in
+    select x.weekday, x.new_start, x.new_end
+    from (values
+      (0, new.sun_start, new.sun_end, old.sun_start, old.sun_end),
+      (1, new.mon_start, new.mon_end, old.mon_start, old.mon_end),
+      (2, new.tue_start, new.tue_end, old.tue_start, old.tue_end),
+      (3, new.wed_start, new.wed_end, old.wed_start, old.wed_end),
+      (4, new.thu_start, new.thu_end, old.thu_start, old.thu_end),
+      (5, new.fri_start, new.fri_end, old.fri_start, old.fri_end),
+      (6, new.sat_start, new.sat_end, old.sat_start, old.sat_end)
+    ) as x(weekday, new_start, new_end, old_start, old_end)
+    where tg_op = 'INSERT'
+       or x.new_start is distinct from x.old_start
+       or x.new_end is distinct from x.old_end
+  loop
+    delete from public.staff_shift_pattern p where p.staff_id = new.id and p.weekday = d.weekday;
+    if d.new_start is not null and d.new_end is not null and d.new_start <> d.new_end then
+      insert into public.staff_shift_pattern (staff_id, weekday, start_time, end_time)
+      values (new.id, d.weekday, d.new_start, d.new_end);
+    end if;
+  end loop;
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger sync_shift_pattern
+after insert or update of
+  mon_start, mon_end, tue_start, tue_end, wed_start, wed_end, thu_start, thu_end,
+  fri_start, fri_end, sat_start, sat_end, sun_start, sun_end
+on public.staff
+for each row
+execute function public.staff_sync_shift_pattern();
+
+-- 週間勤務パターンの置き換え
+-- p_shifts は [{"weekday": 1, "start_time": "20:00", "end_time": "04:00"}, ...]（全曜日分。含まれない曜日は休み）
+-- p_updated_at が現在の staff.updated_at と異なる場合は 412、スタッフが無い場合は 404 を返す
+-- staff の曜日別の列を各曜日の最初の勤務にそろえ、updated_at（ETag）を進める
+create or replace function public.replace_staff_shift_pattern(p_staff_id uuid, p_updated_at timestamptz, p_shifts jsonb)
+returns table (updated_at timestamptz)
+language plpgsql
+as $$
+#variable_conflict use_column
+declare
+  v_updated_at timestamptz;
+begin
+  select s.updated_at into v_updated_at from public.staff s where s.id = p_staff_id for update;
+  if not found then
+    raise exception 'staff not found' using errcode = 'P0002';
+  end if;
+  if p_updated_at is not null and v_updated_at is distinct from p_updated_at then
+    raise exception 'staff was modified' using errcode = 'PT412';
+  end if;
+
+  delete from public.staff_shift_pattern p where p.staff_id = p_staff_id;
+  insert into public.staff_shift_pattern (staff_id, weekday, start_time, end_time)
+  select p_staff_id, (x->>'weekday')::smallint, (x->>'start_time')::time, (x->>'end_time')::time
+  from jsonb_array_elements(coalesce(p_shifts, '[]'::jsonb)) as x;
+
+  perform set_config('nissyo.shift_pattern_sync', 'off', true);
+  update public.staff s
+  set sun_start = f.sun_start, sun_end = f.sun_end,
+      mon_start = f.mon_start, mon_end = f.mon_end,
+      tue_start = f.tue_start, tue_end = f.tue_end,
+      wed_start = f.wed_start, wed_end = f.wed_end,
+      thu_start = f.thu_start, thu_end = f.thu_end,
+      fri_start = f.fri_start, fri_end = f.fri_end,
+      sat_start = f.sat_start, sat_end = f.sat_end
+  from (
+    select
+      max(start_time) filter (where weekday = 0 and rn = 1) as sun_start, max(end_time) filter (where weekday = 0 and rn = 1) as sun_end,
+      max(start_time) filter (where weekday = 1 and rn = 1) as mon_start, max(end_time) filter (where weekday = 1 and rn = 1) as mon_end,
+      max(start_time) filter (where weekday = 2 and rn = 1) as tue_start, max(end_time) filter (where weekday = 2 and rn = 1) as tue_end,
+      max(start_time) filter (where weekday = 3 and rn = 1) as wed_start, max(end_time) filter (where weekday = 3 and rn = 1) as wed_end,
+      max(start_time) filter (where weekday = 4 and rn = 1) as thu_start, max(end_time) filter (where weekday = 4 and rn = 1) as thu_end,
+      max(start_time) filter (where weekday = 5 and rn = 1) as fri_start, max(end_time) filter (where weekday = 5 and rn = 1) as fri_end,
+      max(start_time) filter (where weekday = 6 and rn = 1) as sat_start, max(end_time) filter (where weekday = 6 and rn = 1) as sat_end
+    from (
+      select p.weekday, p.start_time, p.end_time,
+             row_number() over (partition by p.weekday order by p.start_time) as rn
+      from public.staff_shift_pattern p
+      where p.staff_id = p_staff_id
+    ) t
+  ) f
+  where s.id = p_staff_id;
+  perform set_config('nissyo.shift_pattern_sync', '', true);
+
+  return query select s.updated_at from public.staff s where s.id = p_staff_id;
+end;
+$$;
+
+comment on function public.replace_staff_shift_pattern(uuid, timestamptz, jsonb) is 'スタッフの週間勤務パターンを置き換える（曜日別の列も最初の勤務にそろえる）';
+
+commit;
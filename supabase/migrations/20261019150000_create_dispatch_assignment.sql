@@ -0,0 +1,38 @@
+-- Dispatch assignments (which driver is booked for a pickup and when)
+-- Used by the driver availability search to exclude drivers who are already booked
+begin;
+
+create table if not exists public.dispatch_assignment (
+  id uuid primary key default gen_random_uuid(),
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  shop_id uuid references public.shop(id) on delete set null,
+  starts_at timestamptz not null,
+  ends_at timestamptz not null,
+  seats integer check (seats is null or seats >= 1),
+  status text not null default 'scheduled' check (status in ('scheduled', 'in_progress', 'done', 'cancelled')),
+  note text,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint dispatch_assignment_range_check check (ends_at > starts_at)
+);
+
+comment on table public.dispatch_assignment is '送迎の割り当て（ドライバーと送迎の時間帯）';
+comment on column public.dispatch_assignment.staff_id is '担当ドライバー（staff.id）';
+comment on column public.dispatch_assignment.shop_id is '送迎先の店舗（shop.id）';
+comment on column public.dispatch_assignment.starts_at is '開始日時';
+comment on column public.dispatch_assignment.ends_at is '終了日時';
+comment on column public.dispatch_assignment.seats is '乗車人数';
+comment on column public.dispatch_assignment.status is '状態（scheduled: 予定 / in_progress: 送迎中 / done: 完了 / cancelled: 取消）';
+comment on column public.dispatch_assignment.note is '備考';
+comment on column public.dispatch_assignment.created_at is '作成日時';
+comment on column public.dispatch_assignment.updated_at is '更新日時';
+
+create index if not exists dispatch_assignment_staff_range_idx on public.dispatch_assignment (staff_id, starts_at, ends_at);
+create index if not exists dispatch_assignment_range_idx on public.dispatch_assignment (starts_at, ends_at) where status in ('scheduled', 'in_progress');
+
+create trigger set_timestamp
+before update on public.dispatch_assignment
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+commit;
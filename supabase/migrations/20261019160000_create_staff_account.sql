@@ -0,0 +1,77 @@
+-- Staff access accounts (login ID, access type, active/suspended state) and pay adjustment rate
+-- Every staff row has exactly one account; new staff get one with default values on insert
+begin;
+
+create table if not exists public.staff_account (
+  staff_id uuid primary key references public.staff(id) on delete cascade,
+  login_id text not null check (login_id ~ '^[A-Za-z0-9._-]{3,64}$'),
+  access_type text not null default 'staff' check (access_type in ('admin', 'manager', 'accounting_manager', 'dispatcher', 'staff')),
+  access_status text not null default 'active' check (access_status in ('active', 'suspended')),
+  adjustment_rate numeric(4, 2) not null default 1.00 check (adjustment_rate between 0 and 9.99),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+comment on table public.staff_account is 'スタッフのアカウント（ログインID・権限・利用状態・給与の調整率）';
+comment on column public.staff_account.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_account.login_id is 'ログインID（大文字小文字を区別せず一意）';
+comment on column public.staff_account.access_type is '権限（admin / manager / accounting_manager / dispatcher / staff）';
+comment on column public.staff_account.access_status is '利用状態（active: 有効 / suspended: 停止）';
+comment on column public.staff_account.adjustment_rate is '給与の調整率（1.00 が標準）';
+comment on column public.staff_account.created_at is '作成日時';
+comment on column public.staff_account.updated_at is '更新日時';
+
+create unique index if not exists staff_account_login_id_key on public.staff_account (lower(login_id));
+
+create trigger set_timestamp
+before update on public.staff_account
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+-- 既定のログインID（staff_ + 3桁の sfid。sfid が無い・使用済みの場合は staff_ + ID の先頭12桁）
+create or replace function public.staff_default_login_id(p_staff_id uuid, p_sfid integer)
+returns text
+language sql
+stable
+as $$
+  select case
+    when p_sfid is not null
+         and not exists (
+           select 1 from public.staff_account a
+           where lower(a.login_id) = lower('staff_' || lpad(p_sfid::text, 3, '0'))
+         )
+      then 'staff_' || lpad(p_sfid::text, 3, '0')
+    else 'staff_' || left(replace(p_staff_id::text, '-', ''), 12)
+  end;
+$$;
+
+-- 既存のスタッフのアカウントを作る（sfid 順に採番）
+do $$
+declare
+  s record;
+begin
+  for s in select id, sfid from public.staff order by sfid nulls last, created_at loop
+    insert into public.staff_account (staff_id, login_id)
+    values (s.id, public.staff_default_login_id(s.id, s.sfid))
+    on conflict do nothing;
+  end loop;
+end;
+$$;
+
+-- スタッフの追加時に既定値のアカウントを作る
+create or replace function public.staff_create_account()
+returns trigger as $$
+begin
+  insert into public.staff_account (staff_id, login_id)
+  values (new.id, public.staff_default_login_id(new.id, new.sfid))
+  on conflict do nothing;
+  return null;
+end;
+$$ language plpgsql;
+
+create trigger create_account
+after insert on public.staff
+for each row
+execute function public.staff_create_account();
+
+commit;
@@ -0,0 +1,57 @@
+-- Password login for staff accounts: bcrypt password hash, lockout counters and refresh-token sessions
+begin;
+
+alter table public.staff_account
+  add column if not exists password_hash text,
+  add column if not exists password_changed_at timestamptz,
+  add column if not exists must_change_password boolean not null default true,
+  add column if not exists failed_login_count integer not null default 0 check (failed_login_count >= 0),
+  add column if not exists locked_until timestamptz,
+  add column if not exists last_login_at timestamptz;
+
+comment on column public.staff_account.password_hash is 'パスワードのハッシュ（bcrypt）。未設定の間はログインできない';
+comment on column public.staff_account.password_changed_at is 'パスワードの設定日時';
+comment on column public.staff_account.must_change_password is '次回ログイン時にパスワードの変更が必要（初期パスワード）';
+comment on column public.staff_account.failed_login_count is '連続したログイン失敗の回数（成功で 0 に戻す）';
+comment on column public.staff_account.locked_until is 'ログインの停止期限（連続して失敗した場合）';
+comment on column public.staff_account.last_login_at is '最終ログイン日時';
+
+create table if not exists public.staff_session (
+  id uuid primary key default gen_random_uuid(),
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  refresh_token_hash text not null,
+  expires_at timestamptz not null,
+  revoked_at timestamptz,
+  user_agent text,
+  client_ip text,
+  created_at timestamptz not null default now(),
+  constraint staff_session_refresh_token_hash_key unique (refresh_token_hash)
+);
+
+comment on table public.staff_session is 'ログインのセッション（リフレッシュトークン）';
+comment on column public.staff_session.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_session.refresh_token_hash is 'リフレッシュトークンの SHA-256（トークン自体は保存しない）';
+comment on column public.staff_session.expires_at is '有効期限';
+comment on column public.staff_session.revoked_at is '無効にした日時（ログアウト・更新・パスワード変更）';
+comment on column public.staff_session.user_agent is 'ログイン時の User-Agent';
+comment on column public.staff_session.client_ip is 'ログイン時の接続元IP';
+comment on column public.staff_session.created_at is '作成日時';
+
+create index if not exists staff_session_staff_idx on public.staff_session (staff_id) where revoked_at is null;
+
+-- ログイン失敗を記録する（同時に失敗しても回数を取りこぼさないよう1文で更新する）
+-- p_max_failures 回連続で失敗したら p_lock_minutes 分ログインを止め、回数を 0 に戻す
+create or replace function public.record_staff_login_failure(p_staff_id uuid, p_max_failures integer, p_lock_minutes integer)
+returns table (failed_login_count integer, locked_until timestamptz)
+language sql
+as $$
+  update public.staff_account a
+  set failed_login_count = case when a.failed_login_count + 1 >= p_max_failures then 0 else a.failed_login_count + 1 end,
+      locked_until = case when a.failed_login_count + 1 >= p_max_failures then now() + make_interval(mins => p_lock_minutes) else a.locked_until end
+  where a.staff_id = p_staff_id
+  returning a.failed_login_count, a.locked_until;
+$$;
+
+comment on function public.record_staff_login_failure(uuid, integer, integer) is 'ログイン失敗を記録し、上限に達したらログインを一定時間止める';
+
+commit;
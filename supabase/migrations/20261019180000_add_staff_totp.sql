@@ -0,0 +1,28 @@
+-- TOTP two-factor authentication for staff accounts and one-time recovery codes
+begin;
+
+alter table public.staff_account
+  add column if not exists totp_secret text,
+  add column if not exists totp_enabled_at timestamptz,
+  add column if not exists totp_last_step bigint;
+
+comment on column public.staff_account.totp_secret is '2段階認証（TOTP）の秘密鍵（AES-GCM で暗号化。登録の確認前も保存する）';
+comment on column public.staff_account.totp_enabled_at is '2段階認証の登録日時（未設定なら2段階認証なし）';
+comment on column public.staff_account.totp_last_step is '最後に使われた確認コードの時刻ステップ（同じコードの再利用を防ぐ）';
+
+create table if not exists public.staff_recovery_code (
+  id uuid primary key default gen_random_uuid(),
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  code_hash text not null,
+  used_at timestamptz,
+  created_at timestamptz not null default now(),
+  constraint staff_recovery_code_staff_hash_key unique (staff_id, code_hash)
+);
+
+comment on table public.staff_recovery_code is '2段階認証のリカバリーコード（認証アプリが使えない場合に1回だけ使える）';
+comment on column public.staff_recovery_code.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_recovery_code.code_hash is 'リカバリーコードの SHA-256（コード自体は保存しない）';
+comment on column public.staff_recovery_code.used_at is '使用日時';
+comment on column public.staff_recovery_code.created_at is '作成日時';
+
+commit;
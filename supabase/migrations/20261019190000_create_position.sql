@@ -0,0 +1,121 @@
+-- Position master (key, Japanese/English label, rank, permissions) referenced by staff.position_id
+-- Replaces the free-text staff.position; existing values are mapped only when they exactly match a position name or key
+begin;
+
+create table if not exists public.position (
+  id uuid primary key default gen_random_uuid(),
+  key text not null check (key ~ '^[a-z][a-z0-9_]{0,49}$'),
+  name_ja text not null check (length(btrim(name_ja)) between 1 and 50),
+  name_en text not null check (length(btrim(name_en)) between 1 and 50),
+  rank integer not null default 0 check (rank >= 0),
+  permissions text[] not null default '{}',
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint position_key_key unique (key)
+);
+
+comment on table public.position is '役職マスタ';
+comment on column public.position.key is '役職キー（API のロール。英小文字・数字・_）';
+comment on column public.position.name_ja is '役職名（日本語）';
+comment on column public.position.name_en is '役職名（英語）';
+comment on column public.position.rank is '序列（小さいほど上位。台帳の役職順の並び替えに使う）';
+comment on column public.position.permissions is 'この役職に与える権限のキー';
+comment on column public.position.created_at is '作成日時';
+comment on column public.position.updated_at is '更新日時';
+
+create index if not exists position_rank_idx on public.position (rank, key);
+
+create trigger set_timestamp
+before update on public.position
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+insert into public.position (key, name_ja, name_en, rank) values
+  ('chairman', '会長', 'Chairman', 10),
+  ('president', '社長', 'President', 20),
+  ('advisor', '顧問', 'Advisor', 30),
+  ('general_manager', '統括部長', 'General Manager', 40),
+  ('admin_manager', '管理部長', 'Administration Manager', 50),
+  ('office_manager', '内勤部長', 'Office Manager', 60),
+  ('female_manager', '女子管理責任', 'Female Staff Manager', 70),
+  ('manager', 'マネージャ', 'Manager', 80),
+  ('pr', 'PR', 'PR', 90),
+  ('office_staff', '内勤', 'Office Staff', 100)
+on conflict (key) do nothing;
+
+alter table public.staff
+  add column if not exists position_id uuid references public.position(id) on delete restrict;
+
+comment on column public.staff.position_id is '役職（position.id。未設定は内勤として扱う）';
+
+create index if not exists staff_position_id_idx on public.staff (position_id);
+
+-- 旧 position の文字列を役職に対応付ける
+-- API が保存していた表記（役職名。未知のロールはキーのまま）と完全一致するものだけを移し、部分一致では推測しない
+create or replace function public.legacy_position_id(p_position text)
+returns uuid
+language sql
+stable
+as $$
+  select p.id
+  from public.position p
+  where btrim(p_position) = p.name_ja
+     or lower(btrim(p_position)) = p.key
+  order by (btrim(p_position) = p.name_ja) desc
+  limit 1;
+$$;
+
+-- 対応付けられなかった値は position_id を未設定のまま残し、確認用に元の値を控える
+create table if not exists public.staff_position_unmapped (
+  staff_id uuid primary key references public.staff(id) on delete cascade,
+  legacy_position text not null,
+  recorded_at timestamptz not null default now()
+);
+
+comment on table public.staff_position_unmapped is '役職マスタへの移行で対応付けられなかった旧役職（確認して役職を設定したら削除する）';
+comment on column public.staff_position_unmapped.legacy_position is '旧 staff.position の値';
+
+-- 移し替えは内容の変更ではないため履歴に残さない（既存の履歴は下で同じ形に書き換える）
+alter table public.staff disable trigger record_history;
+
+update public.staff s
+set position_id = public.legacy_position_id(s.position)
+where nullif(btrim(s.position), '') is not null;
+
+alter table public.staff enable trigger record_history;
+
+insert into public.staff_position_unmapped (staff_id, legacy_position)
+select s.id, s.position
+from public.staff s
+where nullif(btrim(s.position), '') is not null
+  and s.position_id is null
+on conflict (staff_id) do nothing;
+
+do $$
+declare
+  r record;
+begin
+  for r in
+    select legacy_position, count(*) as n
+    from public.staff_position_unmapped
+    group by legacy_position
+    order by legacy_position
+  loop
+    raise notice 'position not mapped: "%" (% staff). See public.staff_position_unmapped', r.legacy_position, r.n;
+  end loop;
+end;
+$$;
+
+-- 履歴のスナップショットも position_id に置き換える（版の比較・復元で同じ列を使うため。対応しない値は null）
+update public.staff_history h
+set staff = (h.staff - 'position') || jsonb_build_object(
+  'position_id',
+  public.legacy_position_id(h.staff ->> 'position')
+)
+where h.staff ? 'position';
+
+drop function public.legacy_position_id(text);
+
+alter table public.staff drop column if exists position;
+
+commit;
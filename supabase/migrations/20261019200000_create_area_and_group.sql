@@ -0,0 +1,159 @@
+-- Area division and group masters referenced by staff.area_division, staff.group_no and shop.group_no
+-- Replaces the free-text staff."group"; existing area and group values are registered in the masters first
+begin;
+
+create table if not exists public.area (
+  id uuid primary key default gen_random_uuid(),
+  name text not null check (length(btrim(name)) between 1 and 50 and name = btrim(name)),
+  name_en text check (name_en is null or length(btrim(name_en)) between 1 and 50),
+  rank integer not null default 0 check (rank >= 0),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint area_name_key unique (name)
+);
+
+comment on table public.area is '地域区分マスタ';
+comment on column public.area.name is '地域区分名（staff.area_division に保存する値）';
+comment on column public.area.name_en is '地域区分名（英語。未設定は name を使う）';
+comment on column public.area.rank is '表示順（小さいほど先）';
+comment on column public.area.created_at is '作成日時';
+comment on column public.area.updated_at is '更新日時';
+
+create index if not exists area_rank_idx on public.area (rank, name);
+
+create trigger set_timestamp
+before update on public.area
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+create table if not exists public."group" (
+  number integer primary key check (number > 0),
+  name text not null check (length(btrim(name)) between 1 and 100),
+  membership_number integer not null default 0 check (membership_number >= 0),
+  initial varchar(1),
+  digits integer check (digits between 1 and 10),
+  expenses_ratio numeric(5,2) check (expenses_ratio between 0 and 100),
+  is_discount_use boolean not null default false,
+  discount_interval integer check (discount_interval >= 1),
+  discount_price integer check (discount_price >= 0),
+  belongs_shop uuid references public.shop(id) on delete set null,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now()
+);
+
+comment on table public."group" is 'グループマスタ';
+comment on column public."group".number is 'グループナンバー';
+comment on column public."group".name is 'グループ名';
+comment on column public."group".membership_number is '会員番号発番（最後に発行した番号）';
+comment on column public."group".initial is '会員番号頭文字';
+comment on column public."group".digits is '会員番号桁数';
+comment on column public."group".expenses_ratio is '雑費割合(%)';
+comment on column public."group".is_discount_use is '回数割引使用';
+comment on column public."group".discount_interval is '割引回数間隔';
+comment on column public."group".discount_price is '割引金額';
+comment on column public."group".belongs_shop is '所属店舗（shop.id）';
+comment on column public."group".created_at is '作成日時';
+comment on column public."group".updated_at is '更新日時';
+
+create trigger set_timestamp
+before update on public."group"
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+-- 既存の値をマスタに登録する
+insert into public.area (name, rank)
+select v.name, (row_number() over (order by v.name) * 10)::integer
+from (
+  select distinct btrim(area_division) as name
+  from public.staff
+  where btrim(coalesce(area_division, '')) <> ''
+) v
+on conflict (name) do nothing;
+
+insert into public."group" (number, name)
+select distinct s.group_no, 'グループ' || s.group_no
+from public.shop s
+where s.group_no > 0
+on conflict (number) do nothing;
+
+-- staff."group" は数字ならグループナンバー、それ以外はグループ名として扱う
+insert into public."group" (number, name)
+select distinct btrim(s."group")::integer, btrim(s."group")
+from public.staff s
+where btrim(coalesce(s."group", '')) ~ '^[0-9]{1,9}$'
+  and btrim(s."group")::integer > 0
+on conflict (number) do nothing;
+
+insert into public."group" (number, name)
+select
+  (select coalesce(max(number), 0) from public."group") + row_number() over (order by v.name),
+  v.name
+from (
+  select distinct btrim(s."group") as name
+  from public.staff s
+  where btrim(coalesce(s."group", '')) <> ''
+    and btrim(s."group") !~ '^[0-9]{1,9}$'
+    and not exists (select 1 from public."group" g where g.name = btrim(s."group"))
+) v;
+
+alter table public.staff
+  add column if not exists group_no integer references public."group"(number) on update cascade on delete restrict;
+
+comment on column public.staff.group_no is 'グループ（group.number）';
+
+create index if not exists staff_group_no_idx on public.staff (group_no);
+
+-- 移し替えは内容の変更ではないため履歴に残さない（既存の履歴は下で同じ形に書き換える）
+alter table public.staff disable trigger record_history;
+
+update public.staff
+set area_division = nullif(btrim(area_division), '')
+where area_division is distinct from nullif(btrim(area_division), '');
+
+update public.staff s
+set group_no = g.number
+from public."group" g
+where btrim(coalesce(s."group", '')) <> ''
+  and (
+    (btrim(s."group") ~ '^[0-9]{1,9}$' and g.number = btrim(s."group")::integer)
+    or (btrim(s."group") !~ '^[0-9]{1,9}$' and g.name = btrim(s."group"))
+  );
+
+alter table public.staff enable trigger record_history;
+
+-- 履歴のスナップショットも group_no に置き換える（版の比較・復元で同じ列を使うため）
+update public.staff_history h
+set staff = (h.staff - 'group') || jsonb_build_object(
+  'group_no',
+  (
+    select g.number
+    from public."group" g
+    where btrim(coalesce(h.staff ->> 'group', '')) <> ''
+      and (
+        (btrim(h.staff ->> 'group') ~ '^[0-9]{1,9}$' and g.number = btrim(h.staff ->> 'group')::integer)
+        or (btrim(h.staff ->> 'group') !~ '^[0-9]{1,9}$' and g.name = btrim(h.staff ->> 'group'))
+      )
+    limit 1
+  )
+)
+where h.staff ? 'group';
+
+alter table public.staff drop column if exists "group";
+
+-- 地域区分は名前で参照する（名前の変更はスタッフにも反映する）
+alter table public.staff
+  add constraint staff_area_division_fkey foreign key (area_division)
+  references public.area(name) on update cascade on delete restrict;
+
+create index if not exists staff_area_division_idx on public.staff (area_division);
+
+-- 0 以下のグループナンバーは未設定として扱っていた
+update public.shop set group_no = null where group_no <= 0;
+
+alter table public.shop
+  add constraint shop_group_no_fkey foreign key (group_no)
+  references public."group"(number) on update cascade on delete restrict;
+
+create index if not exists shop_group_no_idx on public.shop (group_no);
+
+commit;
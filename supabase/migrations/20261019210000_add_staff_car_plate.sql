@@ -0,0 +1,29 @@
+-- License plate on staff_car: add the class number, constrain each plate part and reject duplicate plates
+-- The part checks are "not valid" so existing rows are left as they are; the unique index fails if two vehicles already share a plate
+begin;
+
+alter table public.staff_car
+  add column if not exists class_number varchar(3);
+
+alter table public.staff_car
+  add constraint staff_car_class_number_check
+    check (class_number ~ '^[1-9][0-9ACFHKLMPXY]{0,2}$') not valid,
+  add constraint staff_car_character_check
+    check ("character" ~ '^[あいうえかきくけこさすせそたちつてとなにぬねのはひふほまみむめもやゆよらりるれろわを]$') not valid,
+  add constraint staff_car_number_check
+    check (number between 1 and 9999) not valid;
+
+comment on column public.staff_car.class_number is '車ナンバー分類番号（500・33・30A など）';
+comment on column public.staff_car."character" is '車ナンバーひらがな（お・し・へ・ん は使われない）';
+comment on column public.staff_car.number is '車ナンバー一連指定番号（1〜9999。表示は 12-34・・・12）';
+
+-- 分類番号が未登録の車両は空として比べる（API は未登録をどの分類番号とも重複とみなす）
+create unique index if not exists staff_car_plate_key
+  on public.staff_car (area, coalesce(class_number, ''), "character", number)
+  where area is not null and "character" is not null and number is not null;
+
+comment on index public.staff_car_plate_key is 'ナンバーの重複防止';
+
+create index if not exists staff_car_number_idx on public.staff_car (number);
+
+commit;
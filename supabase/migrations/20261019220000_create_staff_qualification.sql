@@ -0,0 +1,73 @@
+-- Staff qualifications (driver's licenses and other certificates) with expiry tracking
+-- expiry_status is kept up to date by the API's daily check; dispatch assignments are rejected for drivers whose licenses have all expired
+begin;
+
+create table if not exists public.staff_qualification (
+  id uuid primary key default gen_random_uuid(),
+  staff_id uuid not null references public.staff(id) on delete cascade,
+  type text not null check (type in ('driver_license', 'driver_license_2', 'other')),
+  name text check (name is null or length(btrim(name)) between 1 and 100),
+  number text check (number is null or length(number) <= 50),
+  issued_on date,
+  expires_on date,
+  document_ref text check (document_ref is null or length(document_ref) <= 1000),
+  expiry_status text not null default 'valid' check (expiry_status in ('valid', 'expiring', 'expired')),
+  flagged_at timestamptz,
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint staff_qualification_dates_check check (issued_on is null or expires_on is null or expires_on >= issued_on)
+);
+
+comment on table public.staff_qualification is 'スタッフの免許・資格';
+comment on column public.staff_qualification.staff_id is 'スタッフ（staff.id）';
+comment on column public.staff_qualification.type is '種類（driver_license: 運転免許 / driver_license_2: 第二種運転免許 / other: その他の資格）';
+comment on column public.staff_qualification.name is '名称（免許の種類・資格名など）';
+comment on column public.staff_qualification.number is '免許証・資格の番号';
+comment on column public.staff_qualification.issued_on is '交付日';
+comment on column public.staff_qualification.expires_on is '有効期限（期限の無い資格は null）';
+comment on column public.staff_qualification.document_ref is '写しの保存先（ストレージのパスまたは URL）';
+comment on column public.staff_qualification.expiry_status is '期限の状態（valid: 有効 / expiring: 期限間近 / expired: 期限切れ。毎日の確認で更新する）';
+comment on column public.staff_qualification.flagged_at is '期限間近・期限切れになった日時';
+comment on column public.staff_qualification.created_at is '作成日時';
+comment on column public.staff_qualification.updated_at is '更新日時';
+
+create index if not exists staff_qualification_staff_idx on public.staff_qualification (staff_id, type);
+create index if not exists staff_qualification_expires_idx on public.staff_qualification (expires_on) where expires_on is not null;
+
+create trigger set_timestamp
+before update on public.staff_qualification
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+-- 送迎の割り当ては、運転免許が登録されていてすべて期限切れ（送迎日の時点）のドライバーには行えない
+-- 運転免許が未登録のドライバーは対象外（登録が進むまで既存の割り当てを止めないため）
+create or replace function public.dispatch_assignment_check_license()
+returns trigger as $$
+declare
+  day date := (new.starts_at at time zone 'Asia/Tokyo')::date;
+begin
+  if new.status not in ('scheduled', 'in_progress') then
+    return new;
+  end if;
+  if exists (
+       select 1 from public.staff_qualification q
+       where q.staff_id = new.staff_id and q.type in ('driver_license', 'driver_license_2')
+     )
+     and not exists (
+       select 1 from public.staff_qualification q
+       where q.staff_id = new.staff_id and q.type in ('driver_license', 'driver_license_2')
+         and (q.expires_on is null or q.expires_on >= day)
+     ) then
+    raise exception 'driver license of staff % has expired as of %', new.staff_id, day
+      using errcode = 'check_violation', hint = 'renew the license in staff_qualification before assigning';
+  end if;
+  return new;
+end;
+$$ language plpgsql;
+
+create trigger check_license
+before insert or update of staff_id, starts_at, status on public.dispatch_assignment
+for each row
+execute function public.dispatch_assignment_check_license();
+
+commit;
@@ -0,0 +1,50 @@
+-- Vehicle inspection (shaken), insurance and maintenance records per staff_car
+-- Inspection and insurance rows carry an expiry date; the latest row of each kind is the vehicle's current one
+begin;
+
+create table if not exists public.staff_car_record (
+  id uuid primary key default gen_random_uuid(),
+  car_id uuid not null references public.staff_car(id) on delete cascade,
+  kind text not null check (kind in ('shaken', 'compulsory_insurance', 'voluntary_insurance', 'oil_change', 'accident', 'other')),
+  date date,
+  expires_on date,
+  mileage integer check (mileage is null or mileage >= 0),
+  cost integer check (cost is null or cost >= 0),
+  provider text check (provider is null or length(provider) <= 100),
+  reference text check (reference is null or length(reference) <= 50),
+  note text check (note is null or length(note) <= 1000),
+  document_ref text check (document_ref is null or length(document_ref) <= 1000),
+  created_at timestamptz not null default now(),
+  updated_at timestamptz not null default now(),
+  constraint staff_car_record_dates_check check (date is null or expires_on is null or expires_on >= date),
+  -- 車検・保険は有効期限、それ以外は実施日（発生日）が必須
+  constraint staff_car_record_expiry_check check (
+    case when kind in ('shaken', 'compulsory_insurance', 'voluntary_insurance') then expires_on is not null
+         when kind in ('oil_change', 'accident') then date is not null
+         else true end
+  )
+);
+
+comment on table public.staff_car_record is '車両の車検・保険・整備の記録';
+comment on column public.staff_car_record.car_id is '車両（staff_car.id）';
+comment on column public.staff_car_record.kind is '種類（shaken: 車検 / compulsory_insurance: 自賠責保険 / voluntary_insurance: 任意保険 / oil_change: オイル交換 / accident: 事故 / other: その他の整備）';
+comment on column public.staff_car_record.date is '実施日・発生日（保険は契約開始日）';
+comment on column public.staff_car_record.expires_on is '有効期限（車検・保険）';
+comment on column public.staff_car_record.mileage is '走行距離（km）';
+comment on column public.staff_car_record.cost is '費用（円）';
+comment on column public.staff_car_record.provider is '保険会社・整備工場など';
+comment on column public.staff_car_record.reference is '証券番号・整備記録の番号など';
+comment on column public.staff_car_record.note is '内容（事故の状況・整備の内容など）';
+comment on column public.staff_car_record.document_ref is '写しの保存先（ストレージのパスまたは URL）';
+comment on column public.staff_car_record.created_at is '作成日時';
+comment on column public.staff_car_record.updated_at is '更新日時';
+
+create index if not exists staff_car_record_car_idx on public.staff_car_record (car_id, kind, date desc);
+create index if not exists staff_car_record_expires_idx on public.staff_car_record (kind, expires_on) where expires_on is not null;
+
+create trigger set_timestamp
+before update on public.staff_car_record
+for each row
+execute function public.set_current_timestamp_updated_at();
+
+commit;
@@ -0,0 +1,30 @@
+-- One active staff per vehicle, enforced by the database so concurrent assignments cannot both succeed
+begin;
+
+-- 既に重複している車両があれば、どちらを外すかは判断せずに中止する（割り当てを直してから再実行する）
+do $$
+declare
+  v_dup text;
+begin
+  select string_agg(format('%s (staff: %s)', vehicle, ids), ', ')
+  into v_dup
+  from (
+    select vehicle, string_agg(id::text, ' / ' order by id) as ids
+    from public.staff
+    where status and vehicle is not null
+    group by vehicle
+    having count(*) > 1
+  ) d;
+  if v_dup is not null then
+    raise exception 'vehicles assigned to more than one active staff: %', v_dup;
+  end if;
+end;
+$$;
+
+create unique index if not exists staff_active_vehicle_key
+  on public.staff (vehicle)
+  where status and vehicle is not null;
+
+comment on index public.staff_active_vehicle_key is '在職スタッフ間での車両の重複防止（割り当ての同時実行対策）';
+
+commit;
@@ -0,0 +1,40 @@
+-- Record the weekly shift pattern in staff history so versions can be compared and reverted with it
+begin;
+
+alter table if exists public.staff_history
+  add column if not exists shift_pattern jsonb;
+
+comment on column public.staff_history.shift_pattern is '書き込み後の勤務パターン（[{weekday, start_time, end_time}]。記録を始める前の版は null）';
+
+create or replace function public.staff_history_record(p_staff public.staff, p_operation text)
+returns void as $$
+begin
+  insert into public.staff_history (staff_id, version, operation, staff, staff_car, shift_pattern)
+  values (
+    p_staff.id,
+    coalesce((select max(h.version) from public.staff_history h where h.staff_id = p_staff.id), 0) + 1,
+    p_operation,
+    to_jsonb(p_staff),
+    (select to_jsonb(c) from public.staff_car c where c.id = p_staff.vehicle),
+    coalesce((
+      select jsonb_agg(jsonb_build_object('weekday', p.weekday, 'start_time', p.start_time, 'end_time', p.end_time)
+                       order by p.weekday, p.start_time)
+      from public.staff_shift_pattern p
+      where p.staff_id = p_staff.id
+    ), '[]'::jsonb)
+  );
+end;
+$$ language plpgsql;
+
+-- 曜日別の列から勤務パターンへの同期は履歴の記録（record_history）より先に動かす
+-- 同じ契機の after トリガーは名前順に動くため、record_history より前の名前にする
+drop trigger if exists sync_shift_pattern on public.staff;
+create trigger mirror_shift_pattern
+after insert or update of
+  mon_start, mon_end, tue_start, tue_end, wed_start, wed_end, thu_start, thu_end,
+  fri_start, fri_end, sat_start, sat_end, sun_start, sun_end
+on public.staff
+for each row
+execute function public.staff_sync_shift_pattern();
+
+commit;
@@ -0,0 +1,42 @@
+-- Serialize staff history version numbering per staff so concurrent writes cannot pick the same version
+begin;
+
+-- 版番号の重複防止（作成時の定義に含まれていない環境向け）
+do $$
+begin
+  if not exists (
+    select 1 from pg_constraint
+    where conrelid = 'public.staff_history'::regclass
+      and contype = 'u'
+      and conname = 'staff_history_staff_version_key'
+  ) then
+    alter table public.staff_history
+      add constraint staff_history_staff_version_key unique (staff_id, version);
+  end if;
+end;
+$$;
+
+-- 同じスタッフの記録は advisory lock で直列化してから次の版番号を求める（ロックはトランザクションの終わりまで）
+create or replace function public.staff_history_record(p_staff public.staff, p_operation text)
+returns void as $$
+begin
+  perform pg_advisory_xact_lock(hashtext('public.staff_history'), hashtext(p_staff.id::text));
+
+  insert into public.staff_history (staff_id, version, operation, staff, staff_car, shift_pattern)
+  values (
+    p_staff.id,
+    coalesce((select max(h.version) from public.staff_history h where h.staff_id = p_staff.id), 0) + 1,
+    p_operation,
+    to_jsonb(p_staff),
+    (select to_jsonb(c) from public.staff_car c where c.id = p_staff.vehicle),
+    coalesce((
+      select jsonb_agg(jsonb_build_object('weekday', p.weekday, 'start_time', p.start_time, 'end_time', p.end_time)
+                       order by p.weekday, p.start_time)
+      from public.staff_shift_pattern p
+      where p.staff_id = p_staff.id
+    ), '[]'::jsonb)
+  );
+end;
+$$ language plpgsql;
+
+commit;
@@ -0,0 +1,42 @@
+-- Revoke login sessions when a staff account is suspended or the staff retires, so their access tokens stop working
+begin;
+
+-- アカウントの利用停止でセッションを無効にする
+create or replace function public.staff_account_revoke_sessions()
+returns trigger as $$
+begin
+  update public.staff_session
+  set revoked_at = now()
+  where staff_id = new.staff_id
+    and revoked_at is null;
+  return new;
+end;
+$$ language plpgsql;
+
+drop trigger if exists revoke_sessions on public.staff_account;
+create trigger revoke_sessions
+after update of access_status on public.staff_account
+for each row
+when (new.access_status <> 'active' and old.access_status is distinct from new.access_status)
+execute function public.staff_account_revoke_sessions();
+
+-- 退職でセッションを無効にする
+create or replace function public.staff_revoke_sessions()
+returns trigger as $$
+begin
+  update public.staff_session
+  set revoked_at = now()
+  where staff_id = new.id
+    and revoked_at is null;
+  return new;
+end;
+$$ language plpgsql;
+
+drop trigger if exists revoke_sessions on public.staff;
+create trigger revoke_sessions
+after update of status on public.staff
+for each row
+when (new.status is false and old.status is distinct from new.status)
+execute function public.staff_revoke_sessions();
+
+commit;
@@ -0,0 +1,16 @@
+-- Serialize display order numbering for new staff so concurrent inserts cannot take the same position
+begin;
+
+-- 並べ替え（reorder_staff）と同じ advisory lock を取ってから末尾の番号を求める（ロックはトランザクションの終わりまで）
+create or replace function public.staff_set_display_order()
+returns trigger as $$
+begin
+  if new.display_order is null then
+    perform pg_advisory_xact_lock(hashtext('public.staff.display_order'));
+    select coalesce(max(display_order), 0) + 1 into new.display_order from public.staff;
+  end if;
+  return new;
+end;
+$$ language plpgsql;
+
+commit;
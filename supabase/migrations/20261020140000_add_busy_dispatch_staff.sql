@@ -0,0 +1,25 @@
+-- Look up drivers already booked in a time window without listing candidate ids in the request URL
+begin;
+
+-- p_staff_ids のうち [p_from, p_to) と重なる p_statuses の送迎が割り当て済みのスタッフ（重複なし）
+create or replace function public.busy_dispatch_staff(
+  p_staff_ids uuid[],
+  p_from timestamptz,
+  p_to timestamptz,
+  p_statuses text[]
+)
+returns table (staff_id uuid)
+language sql
+stable
+as $$
+  select distinct a.staff_id
+  from public.dispatch_assignment a
+  where a.staff_id = any(p_staff_ids)
+    and a.status = any(p_statuses)
+    and a.starts_at < p_to
+    and a.ends_at > p_from;
+$$;
+
+comment on function public.busy_dispatch_staff(uuid[], timestamptz, timestamptz, text[]) is '指定した時間帯に送迎が割り当て済みのスタッフ';
+
+commit;